@@ -0,0 +1,234 @@
+package main
+
+// Write operations against the XML API. They need a user with the matching
+// privileges (e.g. "fault" for acknowledging faults) and are only executed
+// if explicitly requested by a flag.
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+type (
+	ConfigConfMos struct {
+		XMLName        struct{} `xml:"configConfMos"`
+		Cookie         string   `xml:"cookie,attr"`
+		InHierarchical string   `xml:"inHierarchical,attr"`
+		InConfigs      InConfigs
+	}
+
+	InConfigs struct {
+		XMLName struct{} `xml:"inConfigs"`
+		Pairs   []Pair
+	}
+
+	Pair struct {
+		XMLName   struct{} `xml:"pair"`
+		Key       string   `xml:"key,attr"`
+		FaultInst *FaultInstAck
+	}
+
+	FaultInstAck struct {
+		XMLName struct{} `xml:"faultInst"`
+		Dn      string   `xml:"dn,attr"`
+		Ack     string   `xml:"ack,attr"`
+		Status  string   `xml:"status,attr"`
+	}
+
+	ConfigConfMo struct {
+		XMLName        struct{} `xml:"configConfMo"`
+		Cookie         string   `xml:"cookie,attr"`
+		Dn             string   `xml:"dn,attr"`
+		InHierarchical string   `xml:"inHierarchical,attr"`
+		InConfig       InConfig
+	}
+
+	InConfig struct {
+		XMLName struct{} `xml:"inConfig"`
+		Mo      interface{}
+	}
+
+	EquipmentLocatorLed struct {
+		XMLName    struct{} `xml:"equipmentLocatorLed"`
+		Dn         string   `xml:"dn,attr"`
+		AdminState string   `xml:"adminState,attr"`
+	}
+
+	ComputeAdminPower struct {
+		XMLName    xml.Name
+		Dn         string `xml:"dn,attr"`
+		AdminPower string `xml:"adminPower,attr"`
+	}
+
+	// CIMC tech-support export to a remote server
+	SysdebugTechSupportExport struct {
+		XMLName    struct{} `xml:"sysdebugTechSupportExport"`
+		Dn         string   `xml:"dn,attr"`
+		AdminState string   `xml:"adminState,attr"`
+		Protocol   string   `xml:"protocol,attr"`
+		Hostname   string   `xml:"hostname,attr"`
+		RemoteFile string   `xml:"remoteFile,attr"`
+		User       string   `xml:"user,attr,omitempty"`
+		Pwd        string   `xml:"pwd,attr,omitempty"`
+	}
+
+	// UCS Manager tech-support file on the fabric interconnect
+	SysdebugTechSupport struct {
+		XMLName    struct{} `xml:"sysdebugTechSupport"`
+		Dn         string   `xml:"dn,attr"`
+		AdminState string   `xml:"adminState,attr"`
+		CreationTS string   `xml:"creationTS,attr"`
+		OptionType string   `xml:"optionType,attr"`
+		Status     string   `xml:"status,attr"`
+	}
+)
+
+// configRequest sends a request and returns the response body. Errors
+// reported by the XML API are returned as *ucsxml.APIError.
+func configRequest(client *http.Client, url string, req interface{}) ([]byte, error) {
+	body, err := (&ucsxml.Client{HTTP: client, URL: url}).Do(req)
+	countAPIError(err)
+	return body, err
+}
+
+// faultsToAck returns the dn of all not yet acknowledged faultInst objects
+// with one of the given fault codes
+func faultsToAck(xml_data string, codes []string) ([]string, error) {
+	objects, err := getXmlAttr(xml_data, "faultInst", []string{"ack", "code"})
+	if err != nil {
+		return nil, err
+	}
+	var dns []string
+	for _, obj := range objects {
+		if obj.Attrs["ack"] != "yes" && len(obj.Dn) > 0 && findIndex(obj.Attrs["code"], codes) > -1 {
+			dns = append(dns, obj.Dn)
+		}
+	}
+	return dns, nil
+}
+
+// ackFaults acknowledges the faults with the codes given by flag -auto-ack
+// and returns a line for the plugin output
+func ackFaults(client *http.Client, url, cookie, xml_data string) string {
+	codes := strings.Split(autoAck, ",")
+	dns, err := faultsToAck(xml_data, codes)
+	if err != nil {
+		return fmt.Sprintf("\nauto-ack error: %v", err)
+	}
+	if len(dns) == 0 {
+		return ""
+	}
+
+	xmlConfigConfMos := &ConfigConfMos{Cookie: cookie, InHierarchical: "false"}
+	for _, dn := range dns {
+		xmlConfigConfMos.InConfigs.Pairs = append(xmlConfigConfMos.InConfigs.Pairs,
+			Pair{Key: dn, FaultInst: &FaultInstAck{Dn: dn, Ack: "yes", Status: "modified"}})
+	}
+	_, err = configRequest(client, url, xmlConfigConfMos)
+	for _, dn := range dns {
+		auditLog("fault-ack", dn, err)
+	}
+	if err != nil {
+		return fmt.Sprintf("\nauto-ack error: %v", err)
+	}
+	return fmt.Sprintf("\nauto-ack: %d faults acknowledged: %s", len(dns), strings.Join(dns, " "))
+}
+
+// setLocatorLed sets the locator LED of a rack server or blade. dn is the dn
+// of the server or of its locator LED, e.g. sys/rack-unit-1 or
+// sys/chassis-1/blade-2/locator-led
+func setLocatorLed(client *http.Client, url, cookie, dn, state string) error {
+	if !strings.HasSuffix(dn, "/locator-led") {
+		dn = strings.TrimSuffix(dn, "/") + "/locator-led"
+	}
+	xmlConfigConfMo := &ConfigConfMo{Cookie: cookie, Dn: dn, InHierarchical: "false",
+		InConfig: InConfig{Mo: &EquipmentLocatorLed{Dn: dn, AdminState: state}}}
+	_, err := configRequest(client, url, xmlConfigConfMo)
+	auditLog("led-"+state, dn, err)
+	return err
+}
+
+// computeClass returns the class of a server dn, computeBlade or computeRackUnit
+func computeClass(dn string) string {
+	if strings.Contains(dn, "/blade-") {
+		return "computeBlade"
+	}
+	return "computeRackUnit"
+}
+
+// powerState returns the operPower and adminPower attributes of a server
+func powerState(client *http.Client, url, cookie, dn string) (operPower, adminPower string, err error) {
+	body, err := configRequest(client, url, &ucsxml.ConfigResolveDn{Cookie: cookie, InHierarchical: "false", Dn: dn})
+	if err != nil {
+		return "", "", err
+	}
+
+	objects, err := getXmlAttr(string(body), computeClass(dn), []string{"operPower", "adminPower"})
+	if err != nil {
+		return "", "", err
+	}
+	if len(objects) == 0 {
+		return "", "", fmt.Errorf("no %s object found", computeClass(dn))
+	}
+	return objects[0].Attrs["operPower"], objects[0].Attrs["adminPower"], nil
+}
+
+// setAdminPower sets the adminPower attribute of a server, e.g. to
+// cycle-immediate or hard-reset-immediate
+func setAdminPower(client *http.Client, url, cookie, dn, adminPower string) error {
+	xmlConfigConfMo := &ConfigConfMo{Cookie: cookie, Dn: dn, InHierarchical: "false",
+		InConfig: InConfig{Mo: &ComputeAdminPower{XMLName: xml.Name{Local: computeClass(dn)}, Dn: dn, AdminPower: adminPower}}}
+	_, err := configRequest(client, url, xmlConfigConfMo)
+	auditLog("power-"+adminPower, dn, err)
+	return err
+}
+
+// collectTechSupportData starts a tech-support collection and returns a line
+// with the export status for the plugin output. With flag -techsupport-dest
+// the CIMC exports the file to a remote server, without it UCS Manager
+// creates the file on the fabric interconnect.
+func collectTechSupportData(client *http.Client, url, cookie string) string {
+	var (
+		dn    string
+		class string
+		mo    interface{}
+	)
+	if len(techSupportDest) > 0 {
+		dest, err := neturl.Parse(techSupportDest)
+		if err != nil || len(dest.Scheme) == 0 || len(dest.Host) == 0 {
+			return "\ntech-support error: invalid destination, expected <protocol>://<user>:<password>@<host>/<file>"
+		}
+		pwd, _ := dest.User.Password()
+		dn = "sys/rack-unit-1/tech-support"
+		class = "sysdebugTechSupportExport"
+		mo = &SysdebugTechSupportExport{Dn: dn, AdminState: "enabled", Protocol: dest.Scheme,
+			Hostname: dest.Host, RemoteFile: dest.Path, User: dest.User.Username(), Pwd: pwd}
+	} else {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		dn = "sys/tech-support-files/tech-support-" + ts
+		class = "sysdebugTechSupport"
+		mo = &SysdebugTechSupport{Dn: dn, AdminState: "start", CreationTS: ts, OptionType: "ucsm", Status: "created"}
+	}
+
+	xmlConfigConfMo := &ConfigConfMo{Cookie: cookie, Dn: dn, InHierarchical: "false", InConfig: InConfig{Mo: mo}}
+	body, err := configRequest(client, url, xmlConfigConfMo)
+	auditLog("techsupport", dn, err)
+	if err != nil {
+		return fmt.Sprintf("\ntech-support error: %v", err)
+	}
+
+	// export status as returned by the config request
+	objects, _ := getXmlAttr(string(body), class, []string{"adminState", "operState", "fsmStatus", "fsmDescr"})
+	status := "started"
+	if len(objects) > 0 && len(objects[0].Line()) > 0 {
+		status = objects[0].Line()
+	}
+	return fmt.Sprintf("\ntech-support: %s %s (adminState,operState,fsmStatus,fsmDescr)", dn, status)
+}
@@ -0,0 +1,436 @@
+package main
+
+// Agent mode: a long running process keeping one authenticated session per
+// UCS domain (host and user) and passing XML API requests through it.
+//
+// The agent speaks the XML API itself: POST /nuova?host=<ip_addr>
+// aaaLogin returns the cookie of the pooled session (the agent logs in only
+// if there is no valid session), aaaLogout is answered by the agent without
+// closing the session and all other requests are sent to the UCS domain.
+// Sessions are kept alive with aaaKeepAlive and closed after being idle.
+// Requests to one session are serialized, flag -max-concurrent-scrapes limits
+// the requests processed at the same time and -scrape-timeout the time of a
+// request to the UCS domain, so a slow domain cannot block the agent.
+// GET /metrics returns the internal metrics of the agent, see metrics.go,
+// GET /sessions the pooled sessions, see sessionstats.go,
+// GET /debug/requests the last requests of -request-log, see requestlog.go.
+// Flag -pprof serves the Go profiling endpoints (/debug/pprof/) on a separate
+// TCP address, e.g. to profile the parsing of the responses of big domains:
+// go tool pprof http://127.0.0.1:6060/debug/pprof/profile
+// On Windows the agent can run as a service and log to the event log, see
+// service_windows.go. Stopped as a service the agent logs out its sessions.
+//
+//	$ ./check_cisco_ucs agent -listen /run/check_ucs.sock -M 1.2
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+type (
+	AaaKeepAlive struct {
+		XMLName struct{} `xml:"aaaKeepAlive"`
+		Cookie  string   `xml:"cookie,attr"`
+	}
+
+	agentSession struct {
+		mu            sync.Mutex
+		key           string
+		host          string
+		username      string
+		password      string
+		cookie        string
+		loginResp     []byte // aaaLogin response passed on to the clients
+		refreshPeriod time.Duration
+		since         time.Time // first login
+		created       time.Time // login of the current session
+		refreshed     time.Time
+		lastUsed      time.Time
+		refreshes     int
+		logins        int
+	}
+
+	agent struct {
+		client      *http.Client
+		idleTimeout time.Duration
+		timeout     time.Duration
+		sem         chan struct{}
+		metrics     *agentMetrics
+		requests    *requestLog // nil without -request-log
+
+		mu       sync.Mutex
+		sessions map[string]*agentSession // key: host, user and password hash
+		cookies  map[string]*agentSession // key: cookie
+	}
+)
+
+// sessionExpired is the XML API error code of an invalid or expired cookie
+const sessionExpired = "552"
+
+func apiPost(client *http.Client, host string, body []byte) ([]byte, error) {
+	resp, err := client.Post("https://"+host+"/nuova", "text/xml", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readBody(resp)
+}
+
+// login logs in the session if it has no cookie. s.mu must be held.
+// If the XML API rejects the login its response is returned with the error.
+func (a *agent) login(s *agentSession) ([]byte, error) {
+	if len(s.cookie) > 0 {
+		return nil, nil
+	}
+	buf, _ := ucsxml.BuildRequest(&ucsxml.AaaLogin{InName: s.username, InPassword: s.password})
+	body, err := apiPost(a.client, s.host, buf)
+	if err != nil {
+		return nil, err
+	}
+	xmlAaaLoginResp := &ucsxml.AaaLoginResp{}
+	if err := xml.Unmarshal(body, xmlAaaLoginResp); err != nil {
+		return nil, err
+	}
+	if xmlAaaLoginResp.ErrorCode != 0 {
+		return body, fmt.Errorf("aaaLogin Error: %s (%d)", xmlAaaLoginResp.ErrorDescr, xmlAaaLoginResp.ErrorCode)
+	}
+	period, _ := strconv.Atoi(xmlAaaLoginResp.OutRefreshPeriod)
+	if period <= 0 {
+		period = 600
+	}
+
+	s.cookie = xmlAaaLoginResp.OutCookie
+	s.loginResp = body
+	s.refreshPeriod = time.Duration(period) * time.Second
+	s.created = time.Now()
+	s.refreshed = s.created
+	if s.since.IsZero() {
+		s.since = s.created
+	}
+	s.logins++
+	a.metrics.login(s.host)
+
+	a.mu.Lock()
+	a.cookies[s.cookie] = s
+	a.mu.Unlock()
+	log.Printf("agent: %s@%s logged in, refresh period %s\n", s.username, s.host, s.refreshPeriod)
+	return nil, nil
+}
+
+// drop forgets the cookie of a session. s.mu must be held.
+func (a *agent) drop(s *agentSession) {
+	a.mu.Lock()
+	delete(a.cookies, s.cookie)
+	a.mu.Unlock()
+	s.cookie = ""
+}
+
+// logoutAll logs out all sessions when the agent is stopped
+func (a *agent) logoutAll() {
+	a.mu.Lock()
+	var sessions []*agentSession
+	for _, s := range a.sessions {
+		sessions = append(sessions, s)
+	}
+	a.mu.Unlock()
+
+	for _, s := range sessions {
+		s.mu.Lock()
+		if len(s.cookie) > 0 {
+			log.Printf("agent: %s@%s logging out\n", s.username, s.host)
+			buf, _ := ucsxml.BuildRequest(&ucsxml.AaaLogout{InCookie: s.cookie})
+			apiPost(a.client, s.host, buf)
+			a.drop(s)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (a *agent) session(host, username, password string) *agentSession {
+	hash := sha256.Sum256([]byte(password))
+	key := host + "|" + username + "|" + hex.EncodeToString(hash[:])
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[key]
+	if !ok {
+		s = &agentSession{key: key, host: host, username: username, password: password, lastUsed: time.Now()}
+		a.sessions[key] = s
+	}
+	return s
+}
+
+func (a *agent) handleLogin(host string, attrs map[string]string) ([]byte, error) {
+	s := a.session(host, attrs["inName"], attrs["inPassword"])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if body, err := a.login(s); err != nil {
+		if body != nil {
+			// pass the login error on to the check
+			return body, nil
+		}
+		return nil, err
+	}
+	s.lastUsed = time.Now()
+	return s.loginResp, nil
+}
+
+// handleRequest sends a request with the cookie of the pooled session. If the
+// session has expired it logs in again and retries once.
+func (a *agent) handleRequest(host, method string, body []byte, cookie string) ([]byte, error) {
+	a.mu.Lock()
+	s, ok := a.cookies[cookie]
+	a.mu.Unlock()
+	if !ok {
+		// not a pooled session, pass through
+		return apiPost(a.client, host, body)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	for retry := 0; ; retry++ {
+		req := bytes.Replace(body, []byte(`cookie="`+cookie+`"`), []byte(`cookie="`+s.cookie+`"`), 1)
+		resp, err := apiPost(a.client, s.host, req)
+		if err != nil {
+			return nil, err
+		}
+		_, err = decodeEnvelope(resp, method)
+		if apiErr, ok := err.(*ucsxml.APIError); !ok || apiErr.Code != sessionExpired || retry > 0 {
+			return resp, nil
+		}
+		log.Printf("agent: %s@%s session expired\n", s.username, s.host)
+		a.drop(s)
+		if _, err := a.login(s); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/metrics" {
+		a.mu.Lock()
+		sessions := len(a.cookies)
+		a.mu.Unlock()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		a.metrics.write(w, sessions)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/sessions" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.sessionInfos())
+		return
+	}
+
+	if r.Method == http.MethodGet && r.URL.Path == "/debug/requests" {
+		if a.requests == nil {
+			http.Error(w, "request log disabled, start the agent with -request-log <n>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.requests.requests(r.URL.Query().Get("host")))
+		return
+	}
+
+	host := r.URL.Query().Get("host")
+	if r.Method != http.MethodPost || r.URL.Path != "/nuova" || len(host) == 0 {
+		http.Error(w, "expected POST /nuova?host=<ip_addr>", http.StatusBadRequest)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method, attrs, err := ucsxml.RequestMethod(body)
+	if err != nil {
+		http.Error(w, "invalid XML API request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	debugPrintf(2, "agent: %s %s\n", host, method)
+
+	select {
+	case a.sem <- struct{}{}:
+		defer func() { <-a.sem }()
+	case <-time.After(a.timeout):
+		a.metrics.reject()
+		http.Error(w, "agent busy, too many concurrent requests", http.StatusServiceUnavailable)
+		return
+	}
+	a.metrics.start()
+	start := time.Now()
+
+	var resp []byte
+	switch method {
+	case "aaaLogin":
+		resp, err = a.handleLogin(host, attrs)
+	case "aaaLogout":
+		// keep the pooled session
+		resp = []byte(`<aaaLogout cookie="" response="yes" outStatus="success"> </aaaLogout>`)
+	default:
+		resp, err = a.handleRequest(host, method, body, attrs["cookie"])
+	}
+	a.metrics.done(host, time.Since(start), err)
+	if a.requests != nil {
+		a.requests.add(host, method, body, resp, time.Since(start), err)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(resp)
+}
+
+// keepAlive sends aaaKeepAlive for sessions at half of their refresh period
+// and logs out sessions not used for the idle timeout
+func (a *agent) keepAlive() {
+	for range time.Tick(10 * time.Second) {
+		a.mu.Lock()
+		var sessions []*agentSession
+		for _, s := range a.sessions {
+			sessions = append(sessions, s)
+		}
+		a.mu.Unlock()
+
+		for _, s := range sessions {
+			s.mu.Lock()
+			switch {
+			case len(s.cookie) == 0:
+				if time.Since(s.lastUsed) > a.idleTimeout {
+					a.mu.Lock()
+					delete(a.sessions, s.key)
+					a.mu.Unlock()
+				}
+			case time.Since(s.lastUsed) > a.idleTimeout:
+				log.Printf("agent: %s@%s idle, logging out\n", s.username, s.host)
+				buf, _ := ucsxml.BuildRequest(&ucsxml.AaaLogout{InCookie: s.cookie})
+				apiPost(a.client, s.host, buf)
+				a.drop(s)
+			case time.Since(s.refreshed) > s.refreshPeriod/2:
+				buf, _ := ucsxml.BuildRequest(&AaaKeepAlive{Cookie: s.cookie})
+				resp, err := apiPost(a.client, s.host, buf)
+				if err == nil {
+					_, err = decodeEnvelope(resp, "aaaKeepAlive")
+				}
+				if err != nil {
+					log.Printf("agent: %s@%s keepalive failed: %v\n", s.username, s.host, err)
+					a.drop(s)
+				} else {
+					s.refreshed = time.Now()
+					s.refreshes++
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// agentListen listens on a unix socket if addr is a path, else on TCP
+func agentListen(addr string) (net.Listener, error) {
+	if strings.Contains(addr, "/") {
+		os.Remove(addr)
+		l, err := net.Listen("unix", addr)
+		if err != nil {
+			return nil, err
+		}
+		return l, os.Chmod(addr, 0660)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func runAgent(args []string) int {
+	fs := newFlagSet("agent")
+	listen := fs.String("listen", "/run/check_ucs.sock", "unix socket path or TCP address (e.g. 127.0.0.1:9711) of the agent API")
+	idleTimeout := fs.Duration("idle-timeout", 10*time.Minute, "log out sessions not used for this time")
+	maxConcurrent := fs.Int("max-concurrent-scrapes", 10, "maximum number of requests processed at the same time")
+	timeout := fs.Duration("scrape-timeout", 30*time.Second, "timeout of a request to a UCS domain")
+	serviceName := fs.String("service-name", "check_cisco_ucs", "Windows only: name of the service if started by the service control manager")
+	eventLog := fs.String("event-log", "", "Windows only: log to the Windows event log with this event source")
+	heartbeatInterval := fs.Duration("heartbeat-interval", 0, "submit a passive \"agent alive\" result per UCS domain at this interval, needs -command-file")
+	commandFile := fs.String("command-file", "", "external command file of Nagios for the heartbeat, example: /usr/local/nagios/var/rw/nagios.cmd")
+	heartbeatService := fs.String("heartbeat-service", "Cisco UCS agent", "service description of the heartbeat result")
+	requestLogSize := fs.Int("request-log", 0, "keep the last n requests and responses per UCS domain for GET /debug/requests, 0: off")
+	pprofAddr := fs.String("pprof", "", "serve the Go profiling endpoints /debug/pprof/ on this TCP address, example: 127.0.0.1:6060")
+	if len(parseArgs(fs, args)) > 0 || *maxConcurrent < 1 || *requestLogSize < 0 || (*heartbeatInterval > 0 && len(*commandFile) == 0) {
+		fs.Usage()
+		return 3
+	}
+	if len(*eventLog) > 0 {
+		w, err := openEventLog(*eventLog)
+		if err != nil {
+			fmt.Printf("UNKNOWN - agent: event log %s: %v\n", *eventLog, err)
+			return 3
+		}
+		log.SetOutput(w)
+	}
+
+	l, err := agentListen(*listen)
+	if err != nil {
+		fmt.Printf("UNKNOWN - agent: %v\n", err)
+		return 3
+	}
+	a := &agent{
+		client:      newClient(),
+		idleTimeout: *idleTimeout,
+		timeout:     *timeout,
+		sem:         make(chan struct{}, *maxConcurrent),
+		metrics:     newAgentMetrics(),
+		sessions:    make(map[string]*agentSession),
+		cookies:     make(map[string]*agentSession),
+	}
+	if *requestLogSize > 0 {
+		a.requests = newRequestLog(*requestLogSize)
+	}
+	a.client.Timeout = *timeout
+	go a.keepAlive()
+	if *heartbeatInterval > 0 {
+		go a.heartbeat(*heartbeatInterval, *commandFile, *heartbeatService)
+	}
+
+	if len(*pprofAddr) > 0 {
+		// net/http/pprof registers its handlers on the default mux, the agent API doesn't use it
+		go func() {
+			log.Printf("agent: pprof: %v\n", http.ListenAndServe(*pprofAddr, nil))
+		}()
+	}
+
+	log.Printf("agent: listening on %s\n", *listen)
+	srv := &http.Server{Handler: a}
+	serve := func() error {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			return err
+		}
+		a.logoutAll()
+		return nil
+	}
+	if isWindowsService() {
+		err = runService(*serviceName, serve, func() {
+			srv.Shutdown(context.Background())
+		})
+	} else {
+		err = serve()
+	}
+	if err != nil {
+		fmt.Printf("UNKNOWN - agent: %v\n", err)
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+package main
+
+// Attribute names of the XML API are camelCase and easy to mistype, e.g.
+// operstate instead of operState. A mistyped attribute of -a is a blank column
+// and the expect string silently fails. Flag -ignore-attr-case matches the
+// attributes of -a case-insensitively, the values are reported under the name
+// given by -a. An attribute of -a found in none of the objects of the response
+// is reported in the long output with the closest attribute of the response:
+//
+//	attribute operstate not found in 4 equipmentPsu objects, did you mean operState?
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var ignoreAttrCase bool
+
+// attrIndex returns the index of the attribute name in the requested
+// attributes, case-insensitive with -ignore-attr-case, or -1
+func attrIndex(name string, attributes []string) int {
+	if !ignoreAttrCase {
+		return findIndex(name, attributes)
+	}
+	for i, a := range attributes {
+		if strings.EqualFold(a, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// missingAttributes returns the attributes found in none of the objects
+func missingAttributes(objects []managedObject, attributes []string) []string {
+	var missing []string
+	for _, a := range attributes {
+		found := false
+		for _, obj := range objects {
+			if _, ok := obj.Attrs[a]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, a)
+		}
+	}
+	return missing
+}
+
+// responseAttributes returns the attribute names of the class objects of an
+// XML API response in the order of their first appearance
+func responseAttributes(body []byte, class string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	decoder := ucsxml.NewDecoder(body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF || err != nil {
+			return names
+		}
+		if elmt, ok := token.(xml.StartElement); ok && elmt.Name.Local == class {
+			for _, attr := range elmt.Attr {
+				if !seen[attr.Name.Local] {
+					seen[attr.Name.Local] = true
+					names = append(names, attr.Name.Local)
+				}
+			}
+		}
+	}
+}
+
+// didYouMean returns the candidate closest to name: equal ignoring case or
+// at most 2 edits away, or an empty string
+func didYouMean(name string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if strings.EqualFold(c, name) {
+			return c
+		}
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// missingAttributeNotes returns the long output lines of the attributes of -a
+// found in none of the objects, with a suggestion from the response
+func missingAttributeNotes(objects []managedObject, body []byte, attributes []string) string {
+	if len(objects) == 0 {
+		return ""
+	}
+	missing := missingAttributes(objects, attributes)
+	if len(missing) == 0 {
+		return ""
+	}
+	candidates := responseAttributes(body, class)
+	notes := ""
+	for _, a := range missing {
+		notes += fmt.Sprintf("\nattribute %s not found in %d %s objects", a, len(objects), class)
+		if s := didYouMean(a, candidates); len(s) > 0 {
+			notes += ", did you mean " + s + "?"
+		}
+	}
+	return notes
+}
@@ -0,0 +1,85 @@
+package main
+
+// Audit log of all write operations (fault acknowledgment, locator LED,
+// power actions, tech-support collection): one line per operation appended
+// to the file of flag -audit-log and/or sent to the syslog server of flag
+// -audit-syslog.
+//
+//	2026-10-15T08:21:24+02:00 user=nagios api_user=admin host=10.18.4.7 action=power-cycle target=sys/rack-unit-1 result=ok
+
+import (
+	"fmt"
+	"log"
+	"net"
+	neturl "net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+)
+
+// syslog priority: facility authpriv (10), severity notice (5)
+const auditSyslogPriority = 10*8 + 5
+
+func auditLog(action, target string, err error) {
+	if len(auditFile) == 0 && len(auditSyslog) == 0 {
+		return
+	}
+
+	localUser := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		localUser = u.Username
+	}
+	result := "ok"
+	if err != nil {
+		result = strconv.Quote(err.Error())
+	}
+	now := time.Now()
+	msg := fmt.Sprintf("user=%s api_user=%s host=%s action=%s target=%s result=%s",
+		localUser, username, ipAddr, action, target, result)
+
+	if len(auditFile) > 0 {
+		f, err := os.OpenFile(auditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%s %s\n", now.Format(time.RFC3339), msg)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			log.Printf("audit log error: %v\n", err)
+		}
+	}
+
+	if len(auditSyslog) > 0 {
+		if err := sendSyslog(auditSyslog, now, msg); err != nil {
+			log.Printf("audit syslog error: %v\n", err)
+		}
+	}
+}
+
+// sendSyslog sends a RFC 3164 message to udp://<host>:<port> or tcp://<host>:<port>
+func sendSyslog(dest string, t time.Time, msg string) error {
+	u, err := neturl.Parse(dest)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return fmt.Errorf("invalid syslog destination %q, expected udp://<host>:<port> or tcp://<host>:<port>", dest)
+	}
+	addr := u.Host
+	if len(u.Port()) == 0 {
+		addr = net.JoinHostPort(u.Host, "514")
+	}
+	hostname, _ := os.Hostname()
+
+	conn, err := net.DialTimeout(u.Scheme, addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = fmt.Fprintf(conn, "<%d>%s %s check_cisco_ucs[%d]: %s\n",
+		auditSyslogPriority, t.Format(time.Stamp), hostname, os.Getpid(), msg)
+	return err
+}
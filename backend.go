@@ -0,0 +1,294 @@
+package main
+
+// Backends: the protocol used to get the managed objects of a check. The
+// evaluation, thresholds and output only see the normalized objects returned
+// by the backend.
+//
+//	ucs-xml		UCS Manager and CIMC XML API (default)
+//	ucs-central	UCS Central XML API, same methods at /xmlIM/resource-mgr
+//	redfish		Redfish API of CIMC, -t dn -q <path> [-o <array>], attributes
+//			may be paths like Status.Health, basic authentication
+//
+// Intersight is not supported yet, its API needs signed requests with an API
+// key instead of a user and password.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+type (
+	// managedObject is a normalized object of any backend, the requested
+	// attributes are the ones of flag -a
+	managedObject = ucsxml.Object
+
+	Backend interface {
+		// Open connects to the host given by flag -H, Close ends the session
+		Open() error
+		// Objects runs the query of the check flags and returns the objects
+		// and the raw response
+		Objects() ([]managedObject, []byte, error)
+		Close()
+	}
+
+	xmlBackend struct {
+		path   string // URL path of the XML API
+		client *http.Client
+		url    string
+		cookie string
+	}
+
+	redfishBackend struct {
+		client *http.Client
+	}
+)
+
+var backends = map[string]func() Backend{
+	"ucs-xml":     func() Backend { return &xmlBackend{path: "/nuova"} },
+	"ucs-central": func() Backend { return &xmlBackend{path: "/xmlIM/resource-mgr"} },
+	"redfish":     func() Backend { return &redfishBackend{} },
+}
+
+func backendNames() string {
+	var names []string
+	for n := range backends {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// isXmlBackend is true for the backends speaking the XML API, needed by the
+// flags using XML API methods (-validate, -auto-ack, -crawl, ...)
+func isXmlBackend() bool {
+	return backendName == "ucs-xml" || backendName == "ucs-central"
+}
+
+func (b *xmlBackend) Open() error {
+	if b.path == "/nuova" {
+		b.client, b.url = apiClient()
+	} else {
+		b.client, b.url = newClient(), "https://"+ipAddr+b.path
+	}
+	debugPrintf(2, "url: %s\n", b.url)
+	if len(sharedQueryCache) > 0 {
+		// login with the first request, a run answered from the shared query cache doesn't log in
+		return nil
+	}
+	b.connect()
+	return nil
+}
+
+// connect logs in if there is no session yet
+func (b *xmlBackend) connect() {
+	if len(b.cookie) > 0 {
+		return
+	}
+	end := phases.begin("login")
+	b.cookie = login(b.client, b.url)
+	end()
+}
+
+// xmlSession returns the XML API backend logged in, for the requests beside
+// the query (-join, -auto-ack, ...)
+func xmlSession(b Backend) *xmlBackend {
+	xb := b.(*xmlBackend)
+	xb.connect()
+	return xb
+}
+
+func (b *xmlBackend) Close() {
+	if len(b.cookie) == 0 {
+		return
+	}
+	defer phases.begin("logout")()
+	logout(b.client, b.url, b.cookie)
+}
+
+func (b *xmlBackend) Objects() ([]managedObject, []byte, error) {
+	end := phases.begin("query")
+	body, err := b.cachedQuery()
+	end()
+	if err != nil {
+		return nil, body, err
+	}
+	defer phases.begin("parse")()
+	objects, err := getXmlAttr(string(body), class, queryAttributes())
+	return filterObjects(objects), body, err
+}
+
+// api returns the XML API client of the session
+func (b *xmlBackend) api() *ucsxml.Client {
+	return &ucsxml.Client{HTTP: b.client, URL: b.url, Cookie: b.cookie}
+}
+
+// query sends configResolveClass or configResolveDn and returns the response
+func (b *xmlBackend) query() ([]byte, error) {
+	var (
+		body []byte
+		err  error
+	)
+
+	b.connect()
+	switch queryType {
+	case "class":
+		if len(crawl) > 0 {
+			body, err = crawlChassis(b.client, b.url, b.cookie)
+			debugPrintf(3, "chassis crawl respons: %s\n", body)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if len(chunkBy) > 0 {
+			body, err = chunkClass(b.client, b.url, b.cookie)
+			debugPrintf(3, "chunked respons: %s\n", body)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		var filter *ucsxml.InFilter
+		if len(serverFilter()) > 0 {
+			filter = newInFilter(class)
+		}
+		body, err = b.api().ResolveClass(class, hierarchical == "true", filter)
+
+	case "dn":
+		if isDnPattern(dn) {
+			return b.resolveDns()
+		}
+		body, err = b.api().ResolveDn(dn, hierarchical == "true")
+	}
+	countAPIError(err)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (b *redfishBackend) Open() error {
+	b.client = newClient()
+	return nil
+}
+
+func (b *redfishBackend) Close() {}
+
+// get returns the JSON document of a Redfish path
+func (b *redfishBackend) get(path string) (map[string]interface{}, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://"+ipAddr+path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.SetBasicAuth(username, password)
+	req.Header.Set("Accept", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	// a truncated JSON document fails to unmarshal, no check like readBody needed
+	body, err := ioutil.ReadAll(resp.Body)
+	debugPrintf(2, "redfish %s respons: %s\n", path, body)
+	if err != nil {
+		countError(errNet)
+		return nil, body, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			countError(errAuth)
+		} else {
+			countError(errApi)
+		}
+		return nil, body, fmt.Errorf("redfish %s: %s", path, resp.Status)
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(body, &doc); err != nil {
+		countError(errParse)
+		return nil, body, fmt.Errorf("redfish %s: %v", path, err)
+	}
+	return doc, body, nil
+}
+
+// Objects returns the document of -q, the members of a collection or, with
+// -o, the elements of an array of the document, e.g. PowerSupplies
+func (b *redfishBackend) Objects() ([]managedObject, []byte, error) {
+	end := phases.begin("query")
+	doc, body, err := b.get(dn)
+	if err != nil {
+		return nil, body, err
+	}
+
+	var docs []map[string]interface{}
+	switch {
+	case len(class) > 0:
+		items, _ := doc[class].([]interface{})
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				docs = append(docs, m)
+			}
+		}
+	case doc["Members"] != nil:
+		members, _ := doc["Members"].([]interface{})
+		for _, member := range members {
+			m, _ := member.(map[string]interface{})
+			id, _ := m["@odata.id"].(string)
+			memberDoc, _, err := b.get(id)
+			if err != nil {
+				return nil, body, err
+			}
+			docs = append(docs, memberDoc)
+		}
+	default:
+		docs = append(docs, doc)
+	}
+	end()
+	defer phases.begin("parse")()
+
+	attributeArray := strings.Split(attributes, " ")
+	var objects []managedObject
+	for _, d := range docs {
+		id, _ := d["@odata.id"].(string)
+		obj := managedObject{Dn: id, Attrs: make(map[string]string), Keys: attributeArray}
+		for _, a := range attributeArray {
+			if v, ok := redfishValue(d, a); ok {
+				obj.Attrs[a] = v
+			}
+		}
+		objects = append(objects, obj)
+	}
+	return objects, body, nil
+}
+
+// redfishValue returns the value of a property, path elements are separated
+// by dots, e.g. Status.Health
+func redfishValue(doc map[string]interface{}, path string) (string, bool) {
+	var v interface{} = doc
+	for _, p := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		v = m[p]
+	}
+	if v == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%v", v), true
+}
+
+// openBackend opens the backend of flag -backend, on failure the plugin exits
+// with status UNKNOWN
+func openBackend() Backend {
+	b := backends[backendName]()
+	if err := b.Open(); err != nil {
+		exitUnknown(fmt.Sprintf("%s: %v", backendName, err))
+	}
+	return b
+}
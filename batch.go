@@ -0,0 +1,200 @@
+package main
+
+// Batch mode: -H srv:<name> or -H consul:<service>[@<datacenter>] resolves the
+// targets from DNS SRV records or the Consul catalog and runs the check
+// against every target, -parallel at the same time. Newly racked servers
+// registered in DNS or Consul are monitored without editing host lists.
+// Every target is checked by its own process with -output json, so a target
+// failing the login is UNKNOWN without stopping the others.
+//
+//	-H srv:_cimc._tcp.dc1.example.com
+//	-H consul:cimc@dc1	Consul agent CONSUL_HTTP_ADDR (default 127.0.0.1:8500), token CONSUL_HTTP_TOKEN
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// isBatch is true if -H is a srv: or consul: lookup
+func isBatch() bool {
+	return strings.HasPrefix(ipAddr, "srv:") || strings.HasPrefix(ipAddr, "consul:")
+}
+
+// resolveTargets returns the addresses of the srv: or consul: lookup of -H
+func resolveTargets(spec string) ([]string, error) {
+	var (
+		targets []string
+		err     error
+	)
+	if strings.HasPrefix(spec, "srv:") {
+		targets, err = lookupSRV(strings.TrimPrefix(spec, "srv:"))
+	} else {
+		targets, err = lookupConsul(strings.TrimPrefix(spec, "consul:"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// hostPort returns host or host:port if port isn't the https port
+func hostPort(host string, port int) string {
+	if port == 0 || port == 443 {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func lookupSRV(name string) ([]string, error) {
+	_, addrs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, a := range addrs {
+		targets = append(targets, hostPort(strings.TrimSuffix(a.Target, "."), int(a.Port)))
+	}
+	return targets, nil
+}
+
+// lookupConsul queries the catalog of the Consul agent for the nodes of a service
+func lookupConsul(service string) ([]string, error) {
+	addr := os.Getenv("CONSUL_HTTP_ADDR")
+	if len(addr) == 0 {
+		addr = "127.0.0.1:8500"
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	query := ""
+	if i := strings.LastIndex(service, "@"); i >= 0 {
+		query = "?dc=" + url.QueryEscape(service[i+1:])
+		service = service[:i]
+	}
+	req, err := http.NewRequest(http.MethodGet, addr+"/v1/catalog/service/"+url.PathEscape(service)+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); len(token) > 0 {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul catalog service %s: %s", service, resp.Status)
+	}
+	var nodes []struct {
+		Address        string
+		ServiceAddress string
+		ServicePort    int
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, fmt.Errorf("consul catalog service %s: %v", service, err)
+	}
+	var targets []string
+	for _, n := range nodes {
+		host := n.ServiceAddress
+		if len(host) == 0 {
+			host = n.Address
+		}
+		targets = append(targets, hostPort(host, n.ServicePort))
+	}
+	return targets, nil
+}
+
+// batchArgs returns the command line of the check of one target: -H
+// replaced by the target, output format json, -deadline the rest of the budget
+func batchArgs(target string) []string {
+	args := []string{}
+	for i := 1; i < len(os.Args); i++ {
+		a := os.Args[i]
+		name := strings.TrimLeft(a, "-")
+		if strings.HasPrefix(a, "-") && (name == "H" || name == "output" || name == "deadline") {
+			i++ // value in the next argument
+			continue
+		}
+		if strings.HasPrefix(a, "-") && (strings.HasPrefix(name, "H=") || strings.HasPrefix(name, "output=") || strings.HasPrefix(name, "deadline=")) {
+			continue
+		}
+		args = append(args, a)
+	}
+	args = append(args, "-H", target, "-output", "json")
+	if deadline > 0 {
+		args = append(args, "-deadline", remainingBudget().String())
+	}
+	return args
+}
+
+// checkTarget runs the check of one target in a child process
+func checkTarget(target string) *checkResult {
+	out, err := exec.Command(os.Args[0], batchArgs(target)...).Output()
+	res := &checkResult{}
+	if jsonErr := json.Unmarshal(out, res); jsonErr != nil {
+		msg := strings.TrimSpace(string(out))
+		if len(msg) == 0 && err != nil {
+			msg = err.Error()
+		}
+		res = newResult().unknown(msg)
+	}
+	res.Label = target
+	return res
+}
+
+// runBatch checks all targets of the lookup of -H and returns the exit code
+func runBatch() int {
+	targets, err := resolveTargets(ipAddr)
+	if err == nil && len(targets) == 0 {
+		err = fmt.Errorf("no targets found")
+	}
+	if err != nil {
+		fmt.Printf("UNKNOWN - %s: %v\n", ipAddr, err)
+		return 3
+	}
+	debugPrintf(2, "batch targets of %s: %v\n", ipAddr, targets)
+
+	results := make([]*checkResult, len(targets))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		sem <- struct{}{}
+		if skipCheck(t) {
+			results[i] = skippedResult()
+			results[i].Label = t
+			<-sem
+			continue
+		}
+		if res := leaseHeld(t); res != nil {
+			results[i] = res
+			results[i].Label = t
+			<-sem
+			continue
+		}
+		wg.Add(1)
+		go func(i int, t string) {
+			defer wg.Done()
+			results[i] = checkTarget(t)
+			<-sem
+		}(i, t)
+	}
+	wg.Wait()
+	results[len(results)-1].Output += skippedNote()
+
+	renderers[outputFormat].Render(os.Stdout, results)
+	return worstResult(results)
+}
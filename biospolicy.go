@@ -0,0 +1,164 @@
+package main
+
+// BIOS token compliance of flag -expect-file <file>: the BIOS settings of
+// the servers of the query (blades or rack servers) are compared with the
+// expected tokens of the file, drift introduced by manual changes (e.g. C
+// states enabled again) is reported in the added attribute "bios":
+// "compliant" or the differing tokens. The settings are read with one
+// hierarchical configResolveClass request of biosSettings. Template
+// bios-policy: -check bios-policy -expect-file tokens.yaml
+//
+// The file is a subset of YAML, the token classes with their attributes and
+// expected values:
+//
+//	# C states off, SR-IOV and NUMA on
+//	biosVfProcessorCState:
+//	  vpProcessorCState: disabled
+//	biosVfSriovConfig:
+//	  vpSriov: enabled
+//	biosVfNUMAOptimized:
+//	  vpNUMAOptimized: enabled
+//
+// XML API only.
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// biosToken is an expected attribute value of a BIOS token class
+type biosToken struct {
+	class string
+	attr  string
+	value string
+}
+
+var expectFile string
+
+// loadExpectFile reads the expected BIOS tokens
+func loadExpectFile(filename string) ([]biosToken, error) {
+	tokens, err := loadYamlSubset(filename, "class", "attribute")
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s: no BIOS tokens", filename)
+	}
+	return tokens, nil
+}
+
+// loadYamlSubset reads the sections of a file (e.g. classes) with their keys
+// (e.g. attributes) and values, also used by -layout-file
+func loadYamlSubset(filename, section, item string) ([]biosToken, error) {
+	f, err := openConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tokens []biosToken
+		class  string
+	)
+	syntax := fmt.Sprintf("\"<%s>:\" or \"  <%s>: <value>\"", section, item)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		text := scanner.Text()
+		if i := strings.Index(text, " #"); i >= 0 {
+			text = text[:i]
+		}
+		line := strings.TrimSpace(text)
+		if len(line) == 0 || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("%s:%d: expected %s", filename, lineNo, syntax)
+		}
+		key := strings.Trim(strings.TrimSpace(kv[0]), "\"'")
+		value := strings.Trim(strings.TrimSpace(kv[1]), "\"'")
+		indented := strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")
+		switch {
+		case !indented && len(value) == 0:
+			class = key
+		case indented && len(class) > 0 && len(value) > 0:
+			tokens = append(tokens, biosToken{class: class, attr: key, value: value})
+		default:
+			return nil, fmt.Errorf("%s:%d: expected %s below a %s", filename, lineNo, syntax, section)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// biosPolicyAttrs returns the attribute added by -expect-file
+func biosPolicyAttrs() []string {
+	if len(expectFile) == 0 {
+		return nil
+	}
+	return []string{"bios"}
+}
+
+// biosPolicyObjects compares the BIOS tokens of the servers with the expected ones
+func biosPolicyObjects(b Backend, objects []managedObject) error {
+	if len(expectFile) == 0 {
+		return nil
+	}
+	tokens, err := loadExpectFile(expectFile)
+	if err != nil {
+		return err
+	}
+	xb := xmlSession(b)
+	body, err := configRequest(xb.client, xb.url, &ucsxml.ConfigResolveClass{Cookie: xb.cookie, InHierarchical: "true", ClassId: "biosSettings"})
+	if err != nil {
+		return fmt.Errorf("BIOS settings: %v", err)
+	}
+
+	// attributes per token class
+	attrs := make(map[string][]string)
+	var classes []string
+	for _, t := range tokens {
+		if _, ok := attrs[t.class]; !ok {
+			classes = append(classes, t.class)
+		}
+		attrs[t.class] = append(attrs[t.class], t.attr)
+	}
+	settings := make(map[string][]managedObject)
+	for _, c := range classes {
+		if settings[c], err = getXmlAttr(string(body), c, attrs[c]); err != nil {
+			return fmt.Errorf("BIOS settings: %v", err)
+		}
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), biosPolicyAttrs()...)
+		var drift []string
+		for _, t := range tokens {
+			var setting *managedObject
+			for j := range settings[t.class] {
+				if strings.HasPrefix(settings[t.class][j].Dn, obj.Dn+"/") {
+					setting = &settings[t.class][j]
+					break
+				}
+			}
+			switch {
+			case setting == nil:
+				drift = append(drift, t.class+" missing")
+			case setting.Attrs[t.attr] != t.value:
+				drift = append(drift, fmt.Sprintf("%s %s (expected %s)", t.attr, setting.Attrs[t.attr], t.value))
+			}
+		}
+		if len(drift) == 0 {
+			obj.Attrs["bios"] = "compliant"
+		} else {
+			// no commas, they separate the attributes of the line
+			obj.Attrs["bios"] = "drift: " + strings.Join(drift, "; ")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadExpectFile(t *testing.T) {
+	tests := []struct {
+		data string
+		want []biosToken // nil: error
+	}{
+		{"---\n# baseline\nbiosVfProcessorCState:\n  vpProcessorCState: disabled # C states off\nbiosVfSriovConfig:\n\tvpSriov: \"enabled\"\n",
+			[]biosToken{{"biosVfProcessorCState", "vpProcessorCState", "disabled"}, {"biosVfSriovConfig", "vpSriov", "enabled"}}},
+		{"  vpSriov: enabled\n", nil},
+		{"biosVfSriovConfig: enabled\n", nil},
+		{"biosVfSriovConfig:\n  vpSriov\n", nil},
+		{"# empty\n", nil},
+	}
+	for i, tt := range tests {
+		file := filepath.Join(t.TempDir(), "tokens.yaml")
+		if err := ioutil.WriteFile(file, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := loadExpectFile(file)
+		switch {
+		case tt.want == nil && err == nil:
+			t.Errorf("%d: no error, %v", i, got)
+		case tt.want != nil && (err != nil || !reflect.DeepEqual(got, tt.want)):
+			t.Errorf("%d: %v, %v, want %v", i, got, err, tt.want)
+		}
+	}
+}
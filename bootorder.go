@@ -0,0 +1,153 @@
+package main
+
+// Boot order verification of flag -boot-order <sequence>: the boot devices
+// of the boot definitions of the query (lsbootDef of service profiles or rack
+// servers, lsbootDevPrecision of CIMC) are sorted by their order attribute and
+// compared with the expected sequence. Drift of the boot order breaks the
+// failover of boot from SAN unnoticed until the next reboot. The query needs
+// the child objects (-s true), template boot-order:
+//
+//	-check boot-order -boot-order san-primary,san-secondary,local-storage
+//
+// A device is named by its class without lsboot, lower case with dashes
+// (lsbootLocalStorage: local-storage, lsbootVirtualMedia: virtual-media,
+// lsbootHdd: hdd), the SAN images of a SAN device are named san-primary and
+// san-secondary, a storage device of older firmware is named after its SAN
+// images or local-storage. Added attributes, after the attributes of -a:
+//
+//	bootOrder	the boot devices in order, separated by >
+//	bootMatch	yes if bootOrder is the expected sequence
+//
+// XML API only.
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var bootOrder string
+
+// bootDevice is a boot device of a boot definition
+type bootDevice struct {
+	order int
+	names []string // the device or its SAN images
+}
+
+// bootOrderAttrs returns the attributes added by -boot-order
+func bootOrderAttrs() []string {
+	if len(bootOrder) == 0 {
+		return nil
+	}
+	return []string{"bootOrder", "bootMatch"}
+}
+
+// bootDeviceName returns the name of a boot device class, e.g. local-storage
+func bootDeviceName(class string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimPrefix(class, "lsboot") {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bootSequences returns the boot devices in order per dn of the boot
+// definitions in a hierarchical response
+func bootSequences(body []byte) map[string][]string {
+	devices := make(map[string][]*bootDevice)
+	var (
+		def    string // dn of the current boot definition
+		depth  int
+		defAt  int // depth of the boot definition
+		device *bootDevice
+	)
+	decoder := ucsxml.NewDecoder(body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF || err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			attrs := make(map[string]string)
+			for _, a := range t.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			switch {
+			case t.Name.Local == "lsbootDef" || t.Name.Local == "lsbootDevPrecision":
+				def, defAt = attrs["dn"], depth
+			case len(def) > 0 && depth == defAt+1:
+				device = nil
+				if order, err := strconv.Atoi(attrs["order"]); err == nil {
+					device = &bootDevice{order: order, names: []string{bootDeviceName(t.Name.Local)}}
+					devices[def] = append(devices[def], device)
+				}
+			case device == nil:
+			case t.Name.Local == "lsbootLocalStorage" && device.names[0] == "storage":
+				device.names = []string{"local-storage"}
+			case strings.HasPrefix(t.Name.Local, "lsbootSan") && (attrs["type"] == "primary" || attrs["type"] == "secondary"):
+				// SAN images below the device, the paths below the images have the same types
+				name := "san-" + attrs["type"]
+				if device.names[0] == "san" || device.names[0] == "storage" {
+					device.names = nil
+				}
+				if findIndex(name, device.names) < 0 {
+					device.names = append(device.names, name)
+				}
+			}
+		case xml.EndElement:
+			if depth == defAt {
+				def, device = "", nil
+			}
+			depth--
+		}
+	}
+
+	sequences := make(map[string][]string)
+	for dn, devs := range devices {
+		sort.SliceStable(devs, func(i, j int) bool { return devs[i].order < devs[j].order })
+		var seq []string
+		for _, d := range devs {
+			names := append([]string{}, d.names...)
+			sort.Strings(names) // san-primary before san-secondary
+			seq = append(seq, names...)
+		}
+		sequences[dn] = seq
+	}
+	return sequences
+}
+
+// bootOrderObjects adds the boot order of the objects and compares it with -boot-order
+func bootOrderObjects(objects []managedObject, body []byte) {
+	if len(bootOrder) == 0 {
+		return
+	}
+	var expected []string
+	for _, s := range strings.Split(bootOrder, ",") {
+		expected = append(expected, strings.TrimSpace(s))
+	}
+	sequences := bootSequences(body)
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), bootOrderAttrs()...)
+		seq := sequences[obj.Dn]
+		obj.Attrs["bootOrder"] = strings.Join(seq, ">")
+		match := "no"
+		if strings.Join(seq, ",") == strings.Join(expected, ",") {
+			match = "yes"
+		}
+		obj.Attrs["bootMatch"] = match
+	}
+}
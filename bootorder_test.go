@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBootSequences(t *testing.T) {
+	body := `<configResolveClass response="yes" classId="lsbootDef"><outConfigs>` +
+		// UCS Manager, SAN images with paths
+		`<lsbootDef dn="org-root/ls-esx01/boot-policy"><lsbootStorage order="3"><lsbootLocalStorage/></lsbootStorage>` +
+		`<lsbootSan order="1"><lsbootSanCatSanImage type="secondary"><lsbootSanCatSanImagePath type="primary"/></lsbootSanCatSanImage>` +
+		`<lsbootSanCatSanImage type="primary"><lsbootSanCatSanImagePath type="primary"/><lsbootSanCatSanImagePath type="secondary"/></lsbootSanCatSanImage></lsbootSan>` +
+		`<lsbootVirtualMedia order="2" access="read-only"/></lsbootDef>` +
+		// older firmware, SAN image below a storage device
+		`<lsbootDef dn="org-root/ls-esx02/boot-policy"><lsbootStorage order="1"><lsbootSanImage type="primary"/></lsbootStorage></lsbootDef>` +
+		// CIMC
+		`<lsbootDevPrecision dn="sys/rack-unit-1/boot-precision"><lsbootPxe order="2"/><lsbootHdd order="1"/></lsbootDevPrecision>` +
+		`</outConfigs></configResolveClass>`
+	want := map[string][]string{
+		"org-root/ls-esx01/boot-policy":  {"san-primary", "san-secondary", "virtual-media", "local-storage"},
+		"org-root/ls-esx02/boot-policy":  {"san-primary"},
+		"sys/rack-unit-1/boot-precision": {"hdd", "pxe"},
+	}
+	if got := bootSequences([]byte(body)); !reflect.DeepEqual(got, want) {
+		t.Errorf("%v, want %v", got, want)
+	}
+}
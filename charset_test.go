@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+func TestGetXmlAttrCharset(t *testing.T) {
+	// declared ISO-8859-1
+	objects, err := getXmlAttr(string(readTestdata(t, "cimc-4.0-configResolveClass-faultInst-latin1.xml")), "faultInst", []string{"code", "descr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Line() != "F0181,Laufwerk 1 prüfen: Temperatur 45°C" {
+		t.Errorf("unexpected objects %+v", objects)
+	}
+
+	// not declared, converted by ucsxml.ReadBody
+	body, _ := ucsxml.ToUTF8([]byte(`<configResolveClass><outConfigs><faultInst code="F0181" descr="pr` + "\xfc" + `fen"/><faultInst code="F0182"/></outConfigs></configResolveClass>`))
+	objects, err = getXmlAttr(string(body), "faultInst", []string{"code"})
+	if err != nil || len(objects) != 2 {
+		t.Errorf("%d objects, error %v, want 2 objects", len(objects), err)
+	}
+
+	// decode errors are returned instead of a too small number of objects
+	invalid := `<configResolveClass><outConfigs><faultInst code="F0181" descr="pr` + "\xfc" + `fen"/><faultInst code="F0182"/></outConfigs></configResolveClass>`
+	if _, err := getXmlAttr(invalid, "faultInst", []string{"code"}); err == nil {
+		t.Errorf("invalid UTF-8: no error")
+	}
+}
@@ -70,31 +70,401 @@
 //  Version 0.9 (11.06.2019)
 //		repair of flag -z function *OK if zero instances* if combined with flag -f
 //
+//  Version 0.10 (15.10.2026)
+//		flag -require quorum evaluation for redundant objects added, example: -require "2 of 4"
+//			CRIT if less than 2 objects are ok, WARN if less than 4 objects are ok
+//		flag -suppress-if added, regex matched against the whole result set, OK if found
+//			example: -suppress-if "maintenance" suppresses all alerts while any object reports maintenance
+//		profile mode added: flag -config runs the checks of a config file with one login,
+//			flag -profile selects profiles, a profile can depend on other profiles (key *depends*)
+//			and is reported as DEPENDENT/OK if one of them is not OK, see profile.go
+//		flag -state-file added, objects (e.g. faultInst) not found in the previous run are tagged NEW,
+//			flag -alert-only-new: only NEW objects not matching the expect string are faults
+//		flag -auto-ack added, acknowledges faults with the given codes after reporting them (needs fault privileges)
+//		subcommand *led* added to switch the locator LED of a rack server or blade, see subcommands.go
+//			example: check_cisco_ucs led on -H 10.18.4.7 -u admin -p pls_change -dn sys/rack-unit-1
+//		subcommand *power* added to show the power state of a rack server or blade, power cycle or reset it
+//			example: check_cisco_ucs power cycle -H 10.18.4.7 -u admin -p pls_change -dn sys/rack-unit-1 -yes
+//		flag -collect-techsupport-on-crit added, starts a tech-support collection (sysdebug) if the check is CRIT,
+//			CIMC needs an export destination -techsupport-dest, UCS Manager keeps the file on the fabric interconnect
+//		subcommand *agent* added, keeps one XML API session per UCS domain alive (aaaKeepAlive) and
+//			passes the requests of the checks through it, see agent.go
+//		flag -via-agent added, the check uses the session of the agent, direct requests if the agent is not running
+//		flags -user-agent and -header added, identify the plugin in web session lists and audit logs
+//			or route requests through reverse proxies
+//		flags -audit-log and -audit-syslog added, audit log of all write operations (ack, led, power, tech-support)
+//			with local user, XML API user, host, action, target and result, see audit.go
+//		flag -validate added, checks the structure of the configResolve response (outConfigs wrapper, classId,
+//			attribute presence statistics) and returns UNKNOWN on anomalies instead of a misleading "0 of 0 ok"
+//		truncated responses (connection dropped while reading the body) are detected by Content-Length
+//			and the end of the XML document and return UNKNOWN instead of a too small number of objects
+//		agent flags -max-concurrent-scrapes and -scrape-timeout added, GET /metrics of the agent
+//			returns its own health (request duration, re-logins, errors) in Prometheus text format
+//		flags -crawl and -parallel added, -crawl chassis splits a domain-wide class query into one
+//			hierarchical query per chassis, sent concurrently and merged, see crawl.go
+//		flag -chunk-by added, splits a class query into one query per chassis or rack server with a wcard
+//			filter on the dn, bounds the response size on very large domains
+//		flag -session-cache added, keeps the session cookie and the TLS session tickets in a file per host and user,
+//			repeated runs skip the login and the full TLS handshake, see session.go
+//		flag -output added, output formats nagios (default), json, csv, zabbix (zabbix_sender input),
+//			checkmk (local check), influx (line protocol) and prometheus (text format), see output.go
+//		flag -backend added, the objects are read by a backend: ucs-xml (default), ucs-central or redfish,
+//			see backend.go
+//		objects are kept as attribute maps instead of a fixed size list of values, attributes missing in
+//			an object are empty instead of the value of the previous object, no limit of 10 attributes
+//		requests are built with self-closing tags without regex post-processing, responses are checked against
+//			the envelope of the request method, XML API errors (e.g. 552 session expired) return UNKNOWN, see request.go
+//		limits for responses (256 MB), XML nesting (256), attributes per element (1024), attribute length (64 kB)
+//			and objects (1000000), responses of buggy or compromised devices exceeding them return UNKNOWN
+//		responses with Latin-1 characters (declared ISO-8859-1 or not UTF-8 at all) are converted to UTF-8,
+//			XML decode errors return UNKNOWN instead of a too small number of objects, see charset.go
+//		decode errors of all token loops are reported with the byte offset, -auto-ack doesn't acknowledge
+//			faults of a corrupt response
+//		flag -label added, prefixes the status line with the name of the UCS domain, the machine readable
+//			output formats have a label (default: host) to tell apart the results of several domains
+//		flag -trace added, appends the timeline of the phases dns, connect, tls, login, query, parse, evaluate
+//			and logout to the long output, shows if a slow check waits for the network, UCSM or the parser, see trace.go
+//		flag -check added, built-in check templates (psu, fan, faults, disks, ...), see templates.go,
+//			flag -explain and subcommand *examples* print the class, attributes, expect string and states of the templates
+//		subcommand *completion* added, prints the bash, zsh or fish completion script of flags, subcommands,
+//			check templates and UCS class names, see completion.go
+//		the agent runs as a Windows service (service control handler, stop logs out the sessions) without
+//			a wrapper like NSSM, agent flag -event-log logs to the Windows event log, see service_windows.go
+//		agent flags -heartbeat-interval, -command-file and -heartbeat-service added, passive "agent alive" result
+//			per UCS domain, Nagios freshness checking detects a dead agent instead of stale hardware states
+//		flag -soft-during-upgrade added, a CRIT check returns WARN while the FSM of UCS Manager, a fabric interconnect
+//			or an IO module shows a running firmware upgrade, see upgrade.go
+//		flags -w and -c added, numeric thresholds of attributes: WARN or CRIT if the value is above,
+//			example: -a "dn ambientTempAvg" -e . -w ambientTempAvg=30 -c ambientTempAvg=35
+//		flag -hysteresis added, an object returns from WARN or CRIT only after its value dropped the margin
+//			below the threshold (state kept in the state file), no notification churn at the boundary, see thresholds.go
+//		an object matching the expect string several times counts once, no more "56 of 2 ok"
+//		flags -samples, -sample-interval and -sample-aggregate added, several readings within one run, the numeric
+//			values are evaluated as average or median, example: -samples 3 -sample-interval 5s, see samples.go
+//		flag -derive added, attributes computed per object from numeric attributes, usable in the output, thresholds
+//			and perfdata, example: -derive "efficiency=outputPower/inputPower*100", see derive.go
+//		flag -perfdata added, performance data of numeric attributes per object with the thresholds of -w and -c,
+//			labels <dn>:<attribute>, prefixed by -label, see perfdata.go
+//		flag -join added, joins the objects of a second class by dn prefix (nearest child or parent), e.g. the state
+//			of a power supply with the counters of its stats object, see join.go
+//		flags -shared-query-cache and -shared-query-ttl added, the checks of an Icinga2 apply rule (one service per object)
+//			share the response of one query within the ttl and don't log in, see querycache.go
+//		subcommand *session stats* added, shows the sessions of the session cache and the agent with age, refreshes,
+//			logins and the login rate per UCS domain, see sessionstats.go
+//		flag -session-max-age added, a session of -session-cache is logged out at the end of the run once it is
+//			older, bounds the lifetime of the sessions kept between runs
+//		flag -show-session added, appends the privileges, refresh period, domains and version of the aaaLogin response
+//			to the long output, shows why a restricted monitoring role gets permission errors, see showsession.go
+//		flag -H accepts several comma separated addresses of the system (e.g. OOB and inband management), the first
+//			reachable one is used and reported in the long output, see failover.go
+//		flag -fallback-fi added, the physical addresses of the fabric interconnects are tried if the cluster VIP of UCS Manager
+//			is unreachable (e.g. election in progress), the long output reports the unreachable VIP
+//		batch mode added: -H srv:<name> or -H consul:<service>[@<dc>] resolves the targets by DNS SRV records or the
+//			Consul catalog and checks all of them, newly racked servers are monitored without editing host lists, see batch.go
+//		dn queries (-t dn) with braces are expanded into one configResolveDns request, e.g. -q "sys/chassis-{1..8}/psu-{1..4}",
+//			dns not found are listed as unresolved, see dnexpand.go
+//		flag -perfdata-label added, template of the perfdata labels built from the dn components, e.g. {path}_{attr}
+//			labels sys/chassis-3/psu-2 chassis3_psu2_outputPower, repeated labels get a suffix _2, _3, ...
+//		golden file tests of captured responses of the tested firmware versions (testdata/golden), responses declaring
+//			ISO-8859-1 are no longer decoded twice (ü shown as Ã¼)
+//		agent flag -pprof added, Go profiling endpoints of the agent, benchmarks of parsing hierarchical responses
+//			of 10MB and more (go test -bench .), check latency of big domains within the Nagios timeout
+//		flag -ignore-attr-case added, attributes of -a not found in any object are reported in the long output
+//			with the closest attribute of the response ("did you mean operState?")
+//		invalid regexes of -e, -suppress-if and the wcard filter of -f are UNKNOWN with the position of the error
+//			and a hint instead of a panic, the property filter -f is checked before the request
+//		wcard values of -f are checked against the POSIX regex syntax of UCS, \d, \w, \s, (?:...) and lazy repeats are
+//			translated, values of -f may contain colons, flag -client-filter added, filters the objects with a Go regex
+//		flag -max-instances added, a query returning more objects is UNKNOWN with hints how to narrow it
+//		subcommand *classes* added, the classes of the check templates with description, attributes and expect string,
+//			templates temperature, firmware and pools added
+//		agent flag -request-log added, the last requests and responses per UCS domain in memory (GET /debug/requests),
+//			passwords and cookies masked, to investigate intermittent parsing problems after the fact
+//		flag -probe added, TCP probe of the address in an attribute of every object, template fi-mgmt checks the
+//			management interfaces of both fabric interconnects (state and OOB address reachable), WARN if one is down
+//		flag -imbalance added, deviation of an attribute from the average of its chassis, template psu-imbalance
+//			alerts on power supplies of a chassis with unbalanced input current (failing power supply, miswired PDU)
+//		flag -host-firmware added, running firmware of every object compared with the host firmware package of its server,
+//			template vic checks the state and temperature of the adapters and their firmware package
+//		templates tpm and secure-boot added, TPM present, enabled and activated, UEFI secure boot enabled
+//		flag -expect-file added, BIOS tokens of the servers compared with a baseline file, template bios-policy
+//			reports drift introduced by manual changes (e.g. C states, SR-IOV, NUMA)
+//		flag -boot-order added, boot devices of the boot definitions compared with the expected sequence, template boot-order
+//			verifies boot from SAN (primary and secondary image) before the local disk
+//		templates vmedia and vmedia-cimc added, vMedia mappings mounted (e.g. ISO of an OS deployment), CRIT if a
+//			mapping failed, -probe accepts shares and URLs (remote share of a CIMC mapping reachable)
+//		flag -hfp added, running firmware of the servers compared with the host firmware package of their service
+//			profiles, template hfp-compliance lists the blades pending activation
+//		template controller-cache added, write back cache, patrol read, battery and foreign configuration of the
+//			storage controllers, degraded caching is detected before a disk fails
+//		flag -layout-file added, disk states compared with the expected layout of the server model (RAID, JBOD),
+//			template disk-layout detects disks that dropped out of a RAID group and report Good as JBOD
+//		template disk-attention added, disks with foreign configuration, unconfigured bad or blocked, states that
+//			don't always raise a fault
+//		template virtual-drives reports the size, strip size and hot spares of the controller as perfdata, WARN
+//			without hot spare, flag -hot-spares added, lower limit thresholds (-w hotSpares=1:), perfdata units
+//		flag -consistent added, attributes compared with the other objects of the chassis or server, template
+//			psu-consistency detects mixed power supply models and firmware
+//		flag -deadline added, no further profiles, batch targets or chunks after the execution budget, the results
+//			so far are reported with the skipped sub-checks before the Nagios timeout kills the plugin
+//		flag -touch-file added, dead man's switch for an external watchdog, written after every successful run
+//		flag -dialect added, perfdata placement and output length of Nagios Core, Naemon or Icinga 2
+//		error counters errors_auth, errors_net, errors_api and errors_parse always reported as perfdata, also
+//			for errors the check recovered from, see errors.go
+//		flags -sample and -limit added, a subset of the objects rotating by dn is evaluated per run
+//		flag -rotate-group added, round robin over groups of chassis and rack servers for gigantic domains
+//		thresholds of -w and -c accept the Nagios range syntax: ~:30, 10:30 and @10:30
+//		-output json (and the other formats) also for a failed login or invalid flags, an UNKNOWN result
+//		subcommand *faults* added, prints the active faults, with -follow the new, changed and cleared faults
+//		output format table added, the objects as aligned table colored by state, default on a terminal
+//		the certificate of the UCS Manager or CIMC is verified (incompatible: self-signed certificates need flag -k),
+//			flag -cafile added to verify it against an internal CA
+//		composite filters and(...), or(...) and not(...) of property filters for flag -f
+//		-state-file accepts a Redis server or SQLite database shared by HA pollers, e.g. redis://redis.example.com/2
+//		flags -lease, -lease-ttl and -lease-owner added, a UCS domain is polled by one node of a HA pair or of
+//			several batch nodes at a time, lock files on shared storage or Redis
+//		flags -ufile and -pfile and environment variables CHECK_UCS_USERNAME and CHECK_UCS_PASSWORD added,
+//			the credentials don't have to be on the command line, -E masks CHECK_UCS_PASSWORD
+//		flag -daemon added, a Prometheus exporter serving the results of the check or profiles on /metrics,
+//			-output prometheus reports numeric attributes (ucs_object_value) and the perfdata (ucs_perfdata)
+//		flag -config-sig and subcommand *sign-config* added, config files are verified with an Ed25519 signature
+//		FIPS mode added, flag -fips or build tag fips, TLS 1.2 with FIPS 140 approved cipher suites, refuses -k
+//		flag -ciphers added, cipher suites or presets (modern, intermediate, legacy-cimc) for old CIMC firmware
+//		flags -crit-sev and -warn-sev added, map the fault severities to CRIT and WARN instead of a regex expect string,
+//			see severity.go
+//		flags -ssh-jump and -ssh-key added, tunnel the requests through an SSH jump host (build tag sshjump),
+//			see sshjump.go
+//		flag -timeout added, requests still running at the timeout are canceled, UNKNOWN instead of a hanging plugin
+//		flags -hmac-file and -hmac-header added, HMAC-SHA256 signature header of every request for API gateways
+//		connection, login, API and parse errors and invalid flags are always "UNKNOWN - ..." with exit code 3,
+//			CRIT (2) only if the objects don't match the expect string, no more "CRIT: ..." with exit code 3
+//		flag -descr-normalize added, strip or transliterate localized descr attributes, template faults strips them
+//		package ucsxml: the XML API client (login, filters, configResolveClass/Dn, attribute extraction) split
+//			from the plugin for other tools, go.mod added, the plugin is a thin CLI on top of it
+//		flag -score added, health score 0-100 per UCS domain (fault severities, failed objects) as perfdata
+//		flag -sla and subcommand *report sla* added, the outages of the objects are recorded in the state (-state-file)
+//			and summed up to the availability per object over a period, see sla.go
+//		tests of complete runs against a mock XML API server (httptest) answering with the responses of testdata:
+//			login, cookie, filter in the request, query by class and dn, evaluation, logout, refused login
+//		flag -org added, the class queries are limited to a UCS Manager organization and its sub-organizations
+//			(dn filter combined with -f), checks of tenants limited to their service profiles and pools, see org.go
+//		flag -M accepts 1.0 and 1.3 and refuses unknown versions instead of using TLS 1.1, flag -tls-min added,
+//			fix for -M 1.1: "tls: no supported versions satisfy MinVersion and MaxVersion" (Go 1.22 and later)
+//		missing privileges of restricted accounts (XML API error about privileges or permissions, empty response
+//			without the privilege of the class in the session) are UNKNOWN "insufficient privileges for class <class>
+//			(need role <role> or read-only)" instead of "0 of 0 ok", see privileges.go
+//		flags -warn-failed and -crit-failed added, count thresholds of the objects failing the expect string,
+//			e.g. one failed disk of 60 is WARN with -warn-failed 1, see failed.go
+//
 // todo:
 // 	1. better error handling
-// 	2. add performance data support
-// 	3. command line flag to influence TLS cert verification
-//  4. add warning and critical thresholds
-//  5. add "composite filters" to "property filters"
+// 	2. add performance data support (done: -perfdata)
+// 	3. command line flag to influence TLS cert verification (done: -k, -cafile)
+//  4. add warning and critical thresholds (done: -w, -c)
+//  5. add "composite filters" to "property filters" (done: and(), or(), not() of -f)
 //
 // flags:
 // 	-H <ip_addr>		CIMC IP address or Cisco UCS Manager IP address"
+// 						several comma separated addresses are tried in order, example: -H 10.10.1.7,10.20.1.7
+// 						batch mode: srv:<name> or consul:<service>[@<dc>] checks all targets of the lookup, see batch.go
 // 	-t <query_type>		query type 'dn' or 'class'"
 // 	-q <dn_or_class>	XML API object class name, examples: storageVirtualDrive or storageLocalDisk or storageControllerProps
 // 						Distinguished Name (DN) name, examples: "sys/rack-unit-1"
+// 						DN with braces {1..8} or {a,b}: all combinations, example: "sys/chassis-{1..8}/psu-{1..4}"
 // 	-o <object>			if XML API object class name, examples: storageVirtualDrive or storageLocalDisk or storageControllerProp
 // 	-s <hierarchical>	true or false. If true, the inHierarchical argument returns all child objects
 // 	-a <attributes>		space separated list of XML attributes for display in nagios output and match against *expect* string
 // 	-e <expect_string>	expect string, ok if this is found, examples: "Optimal" or "Good" or "Optimal|Good"
 // 	-u <username>		XML API username
 // 	-p <password>		XML API password
+// 	-ufile <file>		file with the XML API username, if -u isn't given, default: environment variable CHECK_UCS_USERNAME
+// 	-pfile <file>		file with the XML API password, if -p isn't given, default: environment variable CHECK_UCS_PASSWORD,
+//				precedence: -u/-p, -ufile/-pfile, environment, see credentials.go
 //	-d <level>			print debug, level: 1 errors only, 2 warnings and 3 informational messages
 //	-E 			print environment variables for debug purpose
 //	-V			print plugin version
 //	-z			true or false. if set to true the check will return OK status if zero instances where found. Default is false.
 //  -F			display only faults in output
-//  -M 			max TLS Version, default: v1.1"
+//  -M 			max TLS version 1.0, 1.1, 1.2 or 1.3, default: 1.1, other values are refused
+//  -tls-min	min TLS version 1.0, 1.1, 1.2 or 1.3, default: 1.2, or -M if it is lower, see tls.go
+//  -k			don't verify the certificate of the UCS Manager or CIMC, e.g. the self-signed factory certificate
+//  -cafile		file with the CA certificates (PEM) the certificate is verified against, default: the system CA certificates,
+//				-H has to match the certificate, see tls.go
+//  -ciphers	comma separated cipher suites of TLS 1.0 to 1.2, Go names or the presets modern, intermediate and
+//				legacy-cimc (RSA key exchange and 3DES of old CIMC firmware), default: Go defaults, see tls.go
+//  -fips		FIPS mode: TLS 1.2 with the FIPS 140 approved cipher suites only (-M is ignored), -k is refused,
+//				always on in the build with tag fips (go build -tags fips), -V reports the crypto mode
 //  -f			property filter <type>:<property>:<value>, works only with query type class (-t class), examples: wcard:dn:^sys/chassis-[1-3].*
+//				or composite filter and(...), or(...), not(...), e.g. "and(wcard:dn:^sys/chassis-1/.*,gt:ambientTempAvg:24)"
+//  -client-filter	the property filter (eq, ne, wcard) is applied to the unfiltered objects instead of being sent to UCS,
+//				wcard values are Go regexes, e.g. -f "wcard:descr:(?i)log capacity", works with query type dn, see filter.go
+//  -org		UCS Manager organization the class queries are limited to, including its sub-organizations, a query type dn
+//				has to be in it, example: -org org-root/org-tenantA, see org.go
+//  -require	quorum "<k> of <n>" for redundant objects (PSUs, fans, uplinks), CRIT if less than k ok, WARN if less than n ok
+//  -warn-failed	WARN if up to this number of objects fail the expect string, CRIT above, default: 0
+//  -crit-failed	CRIT only if more than this number of objects fail the expect string, fewer are WARN (up to -warn-failed, not above it), default: 0,
+//				example: -check disks -warn-failed 1, one failed disk is WARN, two are CRIT, see failed.go
+//  -suppress-if	regex matched against the whole result set (all objects, one per line), if found the check returns OK
+//  -config		config file with check profiles, see profile.go
+//  -profile	comma separated list of profiles to run, default: all profiles of the config file
+//  -state-file	file to keep state between runs, objects not found in the previous run are tagged NEW,
+//				or a shared store redis://[:<password>@]<host>[:<port>][/<db>] or sqlite:<file>, see state.go
+//  -config-sig	Ed25519 public key (PEM), -config, -expect-file and -layout-file are read only with a valid signature
+//				<file>.sig, written by subcommand sign-config or openssl, see configsig.go
+//  -daemon	Prometheus exporter: runs the check or the profiles of -config every -interval and serves the results
+//				on -listen as /metrics (format of -output prometheus and the duration and success of the run), see daemon.go
+//  -listen	TCP address of the /metrics endpoint of -daemon, default: :9710
+//  -interval	interval of the runs of -daemon, default: 1m
+//  -lease	poll the UCS domain only on the node holding its lease, the other nodes return OK: directory of lock
+//				files on shared storage or redis://[:<password>@]<host>[:<port>][/<db>], see lease.go
+//  -lease-ttl	time a lease is held without renewal by the next run, longer than the check interval, default: 5m
+//  -lease-owner	owner of the leases, default: the host name
+//  -alert-only-new	only objects tagged NEW can fail the check, requires -state-file
+//  -auto-ack	comma separated list of fault codes to acknowledge after reporting them, works only with class faultInst
+//				the XML API user needs fault privileges, example: -auto-ack F0461,F0181
+//  -collect-techsupport-on-crit	start a tech-support collection if the check is CRIT and report the export status
+//  -techsupport-dest	CIMC only: export destination <protocol>://<user>:<password>@<host>/<file>, protocol tftp, ftp, sftp, scp or http
+//  -techsupport-holdoff	minimum time between two tech-support collections, needs -state-file, default: 24h
+//  -via-agent	send the requests through the agent listening on this unix socket or TCP address, example: /run/check_ucs.sock
+//				direct requests if the agent is not running
+//  -ssh-jump	tunnel the HTTPS requests through an SSH connection to this jump host (bastion), [<user>@]<host>[:<port>],
+//				the host key has to be in ~/.ssh/known_hosts, build tag sshjump: go build -tags sshjump, see sshjump.go
+//  -ssh-key	private key (without passphrase) of -ssh-jump, default: ~/.ssh/id_ed25519, id_ecdsa or id_rsa,
+//				and the keys of the ssh-agent (SSH_AUTH_SOCK)
+//  -user-agent	User-Agent header of the HTTP requests, default: check_cisco_ucs/<version>
+//  -header		additional HTTP request header <name>=<value>, can be repeated, example: -header X-Monitoring=nagios01
+//  -hmac-file	file with a shared secret (first line), every request gets a HMAC-SHA256 signature header
+//				t=<unix time>,sig=<hex> for an API gateway, see signature.go
+//  -hmac-header	name of the signature header of -hmac-file, default: X-Monitoring-Signature
+//  -audit-log	append a line for every write operation (ack, led, power, tech-support) to this file
+//  -audit-syslog	send a syslog message for every write operation to udp://<host>:<port> or tcp://<host>:<port>
+//  -ignore-attr-case	match the attributes of -a case-insensitively, e.g. operstate finds operState, see attrcase.go
+//  -validate	check the structure of the response (wrapper element, classId, attribute presence), UNKNOWN if anomalies are found
+//  -crawl		crawl strategy of class queries, 'chassis': query the subtree of every chassis instead of the whole domain,
+//				UCS Manager only, objects outside of the chassis are not found, see crawl.go
+//  -chunk-by	split class queries into one query per 'chassis' or 'rack-unit' (wcard filter on the dn), UCS Manager only
+//  -session-cache	directory to keep the session cookie and TLS session tickets between runs, the session is not logged out
+//  -fallback-fi	UCS Manager: comma separated management addresses of the fabric interconnects, tried if the cluster VIP (-H)
+//				is unreachable, the long output reports "cluster VIP unreachable", see failover.go
+//  -session-max-age	log out a cached session at the end of the run once it is older than this, default: 0 (never)
+//  -shared-query-cache	directory to share the response of a query between runs evaluating different attributes,
+//				expect strings or thresholds (Icinga2 apply rules), runs answered from the cache don't log in, see querycache.go
+//  -shared-query-ttl	maximum age of a cached response, default: 1m
+//  -backend	protocol: ucs-xml (UCS Manager, CIMC, default), ucs-central or redfish (CIMC), see backend.go
+//				redfish: -t dn -q <path> [-o <array>], attributes may be paths, example: -q /redfish/v1/Chassis/1/Power -o PowerSupplies -a "MemberId Status.Health" -e OK
+//  -label		name of the UCS domain prefixing the status line, example: -label ucs-dc1
+//				machine readable output formats: label of the results, default: the host (-H)
+//  -check		built-in check template, flags given on the command line take precedence, example: -check psu
+//				templates: bios-policy, blade, boot-order, chassis, controller, controller-cache, disk-attention, disk-layout,
+//				disks, fan, faults, fi, fi-mgmt, firmware, hfp-compliance, pools, psu, psu-consistency, psu-imbalance, rack-unit,
+//				secure-boot, temperature, tpm, vic, virtual-drives, vmedia, vmedia-cimc
+//  -explain	print the class, attributes, expect string and states of the template of -check and exit
+//  -w			warning threshold <attribute>=<range>, WARN if the value is outside of the Nagios range, can be repeated,
+//				the attribute has to be in -a, example: -a "dn ambientTempAvg" -e . -w ambientTempAvg=30 -c ambientTempAvg=35
+//				ranges: 30 (below 0 or above, like 0:30), 1: (below, e.g. -w hotSpares=1:), ~:30, 10:30 (outside), @10:30 (inside), see thresholds.go
+//  -c			critical threshold <attribute>=<range>, CRIT if the value is outside of the range, can be repeated
+//  -crit-sev	comma separated fault severities which are CRIT instead of the expect string (-e isn't evaluated),
+//				critical, major, minor, warning, info, condition or cleared, the attribute severity has to be in -a,
+//				example: -q faultInst -a "code severity descr" -crit-sev critical,major -warn-sev minor,warning
+//  -warn-sev	comma separated fault severities which are WARN, see -crit-sev, see severity.go
+//  -descr-normalize	normalize the descr attributes, e.g. localized fault descriptions: none (default), strip (line
+//				breaks and control characters to spaces, commas to semicolons) or ascii (strip and transliterate
+//				to ASCII, other characters removed), see normalize.go
+//  -hysteresis	margin below the threshold the value must drop to return from WARN or CRIT, requires -state-file,
+//				percentage of the threshold or absolute value, example: -hysteresis 5%
+//  -join		add the attributes of the objects of a second class with the same dn prefix, <class>:<attributes>,
+//				example: -q equipmentPsu -a "dn operState" -join "equipmentPsuStats:outputPower ambientTemp"
+//  -host-firmware	add the running firmware of every object (fwVersion, fwPackage) and fwMatch: yes if the package is the
+//				package of the CIMC firmware of its server (host firmware package), example: -check vic, see fwpackage.go
+//  -hfp		compare the running firmware of the server of every object with the host firmware package of its service profile,
+//				adds hfpVersion, runningVersion and hfpStatus (compliant, pending-activation, activating or none),
+//				example: -check hfp-compliance, see hfp.go
+//  -expect-file	file with the expected BIOS tokens of the servers of the query (class, attribute and value, YAML), adds the
+//				attribute bios: compliant or the drifted tokens, example: -check bios-policy -expect-file tokens.yaml, see biospolicy.go
+//  -boot-order	expected boot order of the boot definitions of the query (-s true), comma separated devices, adds the attributes
+//				bootOrder and bootMatch (yes or no), example: -check boot-order -boot-order san-primary,san-secondary,local-storage, see bootorder.go
+//  -layout-file	file with the expected disk states per server model and slot (YAML), adds the attribute layout: compliant,
+//				none (no layout of the model) or the drift, example: -check disk-layout -layout-file layout.yaml, see layout.go
+//  -hot-spares	add the number of hot spare disks of the storage controller of every object as attribute hotSpares,
+//				example: -check virtual-drives, see hotspare.go
+//  -derive	derived attribute <name>=<expression> of numeric attributes (+ - * / and parentheses), can be repeated,
+//				example: -a "dn outputPower inputPower" -derive "efficiency=outputPower/inputPower*100"
+//  -imbalance	numeric attribute, adds the attribute imbalance: the deviation in percent from the average of the objects of
+//				the same chassis, rack server or fabric interconnect, for thresholds, example: -check psu-imbalance, see imbalance.go
+//  -consistent	space separated attributes, adds the attribute consistent: yes if the object has the values of the majority of
+//				the objects of the same chassis, rack server or fabric interconnect, example: -check psu-consistency, see consistency.go
+//  -probe		probe the address in an attribute of every object with a TCP connection, <attribute>[:<port>], default port 443,
+//				adds the attribute reachable (yes or no) after the attributes of -a, the address may be a share or URL
+//				with the port of its protocol (//host/share, host:/path, http(s)://host/path), see probe.go
+//  -perfdata	space separated list of numeric attributes (queried or derived) reported as performance data of every object
+//				every result reports the errors of its requests: errors_auth, errors_net, errors_api and errors_parse
+//  -perfdata-label	label template of -perfdata: {dn}, {rn} (last dn component), {path} (dn components without sys
+//				and dashes joined by _), {1}, {2}, ... (dn component), {attr}, {label}, example: {path}_{attr}
+//  -samples	number of readings of the objects within one run, numeric values are aggregated, default: 1
+//  -sample-interval	time between two readings, default: 5s
+//  -sample-aggregate	aggregation of the numeric values: avg (default) or median
+//  -soft-during-upgrade	WARN instead of CRIT while an infrastructure firmware upgrade is running, see upgrade.go
+//  -show-session	append the privileges, refresh period, domains and version of the aaaLogin response to the long output
+//  -trace		append the timeline of the phases (dns, connect, tls, login, query, parse, evaluate, logout) to the long output
+//  -output	output format: nagios (default), json, csv, zabbix, checkmk, influx or prometheus, json: the objects with
+//				their attributes and ok (expect string matched), the state and the perfdata, also for a failed login,
+//				table: aligned table of the objects colored by state, default on a terminal, see table.go
+//  -dialect	plugin output of the monitoring core, nagios: perfdata on the status line, output truncated to 8 KB, naemon:
+//				perfdata on the status line, icinga2: perfdata on a line after the long output, default: perfdata after the
+//				output, pipes in the output are replaced by slashes, see dialect.go
+//  -max-instances	UNKNOWN if the query returns more objects, guards against pathological queries (e.g. lsServer
+//				with child objects on a big domain), the output tells how to narrow the query, default: 0 (no limit)
+//  -sample	percentage of the objects evaluated per run, e.g. 10%, the subset rotates by dn so all objects are covered
+//				over time, requires -state-file, for trending checks of very large classes, see subset.go
+//  -limit	number of objects evaluated per run, rotating like -sample, requires -state-file, default: 0 (all)
+//  -rotate-group	k/N: one of N groups of chassis and rack servers is checked per run, starting with group k, N services
+//				with k = 1..N cover the domain in every cycle, requires -state-file, see rotate.go
+//  -parallel	maximum number of concurrent requests of -crawl and -chunk-by or targets of batch mode, 1: sequential, default: 4
+//  -deadline	execution budget, after it no further profiles (-config), targets of batch mode or chunks (-crawl, -chunk-by)
+//				are started, the results so far and the skipped ones are reported, example: -deadline 50s, see deadline.go
+//  -timeout	timeout of the run (login, queries, logout) in seconds or a duration, the requests still running
+//				are canceled, UNKNOWN "timeout after <n>s", per target in batch mode, example: -timeout 50, see timeout.go
+//  -score		report the health score of the UCS domain as perfdata health_score, 0 to 100, weighted by the severity
+//				of the faults and the ratio of failed objects of all profiles of the run, for dashboards, see score.go
+//  -sla		record the outages (non-OK time) of the objects in the state of the check, requires -state-file,
+//				subcommand report sla shows the availability per object, outages are kept 400 days, see sla.go
+//  -touch-file	file written after every run with a result other than UNKNOWN (time and state), an external watchdog detects
+//				checks that stopped running, {label}: name of the UCS domain, example: -touch-file /var/lib/ucs/{label}.alive
+//
+// subcommands:
+// 	led on|off -H <ip_addr> -u <username> -p <password> -dn <dn>
+//				switch the locator LED of a rack server or blade on or off, dn examples: sys/rack-unit-1 or sys/chassis-1/blade-2
+// 	power status|cycle|reset -H <ip_addr> -u <username> -p <password> -dn <dn> [-yes]
+//				show the power state (operPower) of a rack server or blade, cycle or reset it,
+//				cycle and reset need flag -yes
+// 	agent -listen <socket_or_addr> [-idle-timeout <duration>] [-max-concurrent-scrapes <n>] [-scrape-timeout <duration>] [-service-name <name>] [-event-log <source>] [-heartbeat-interval <duration> -command-file <file>] [-request-log <n>] [-pprof <addr>]
+//				run the agent keeping one XML API session per UCS domain for the checks, see agent.go
+//				default socket: /run/check_ucs.sock, default idle timeout: 10m,
+//				at most 10 concurrent requests with a timeout of 30s, internal metrics: GET /metrics
+//				Windows: runs as a service if started by the service control manager (-service-name, default: check_cisco_ucs),
+//				-event-log logs to the Windows event log, see service_windows.go
+//				-heartbeat-interval submits the passive result "agent alive" for every UCS domain to the Nagios
+//				external command file -command-file (service -heartbeat-service), see heartbeat.go
+//				-request-log keeps the last n requests and responses per UCS domain, GET /debug/requests, see requestlog.go
+//				-pprof serves the Go profiling endpoints /debug/pprof/ on a TCP address
+// 	session stats [-session-cache <dir>] [-via-agent <socket_or_addr>]
+//				show the sessions of the session cache and the agent: age, refreshes, logins and the login rate
+//				per UCS domain, see sessionstats.go
+// 	faults -H <ip_addr> -u <username> -p <password> [-follow] [-interval <duration>] [-no-color]
+//				print the active faults, -follow keeps polling (default every 10s) and prints the new, changed and
+//				cleared faults with a timestamp, colored by severity on a terminal, see faults.go
+// 	sign-config -key <private_key> <file> ...
+//				write the Ed25519 signature <file>.sig of config files for -config-sig, see configsig.go
+// 	examples [<template> ...]
+//				print the class, attributes, expect string and states of the built-in check templates (-check)
+// 	classes
+//				print the classes of the check templates (faults, power supplies, disks, virtual drives, fans, temperatures,
+//				firmware, pools) with a description, the suggested attributes and expect string
+// 	completion bash|zsh|fish
+//				print the shell completion script, example: source <(check_cisco_ucs completion bash)
+// 	report sla -state-file <file> [-period <days>d] [-host <domain>] [-all]
+//				availability, downtime and number of outages per object recorded with -sla over the period (default: 30d),
+//				lowest availability first, -all includes the objects without outage
 //
 // usage examples:
 //
@@ -140,148 +510,26 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
-	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path"
 	"regexp"
+	"regexp/syntax"
 	"strings"
-)
+	"time"
 
-const (
-	maxNumAttrib = 10
-	version      = "0.7"
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
 )
 
-type (
-	AaaLogin struct {
-		XMLName    struct{} `xml:"aaaLogin"`
-		InName     string   `xml:"inName,attr"`
-		InPassword string   `xml:"inPassword,attr"`
-	}
-
-	AaaLoginResp struct {
-		XMLName          struct{} `xml:"aaaLogin"`
-		Cookie           string   `xml:"cookie,attr"`
-		Response         string   `xml:"response,attr"`
-		OutCookie        string   `xml:"outCookie,attr"`
-		OutRefreshPeriod string   `xml:"outRefreshPeriod,attr"`
-		OutPriv          string   `xml:"outPriv,attr"`
-		ErrorCode        int      `xml:"errorCode,attr"`
-		ErrorDescr       string   `xml:"errorDescr,attr"`
-	}
-
-	ConfigResolveClass struct {
-		XMLName        struct{} `xml:"configResolveClass"`
-		Cookie         string   `xml:"cookie,attr"`
-		InHierarchical string   `xml:"inHierarchical,attr"`
-		ClassId        string   `xml:"classId,attr"`
-		InFilter       *InFilter
-	}
-
-	InFilter struct {
-		XMLName xml.Name `xml:"inFilter,omitempty"`
-		Eq      *Eq      `xml:"eq,omitempty"`
-		Ne      *Ne      `xml:"ne,omitempty"`
-		Gt      *Gt      `xml:"gt,omitempty"`
-		Ge      *Ge      `xml:"ge,omitempty"`
-		Lt      *Lt      `xml:"lt,omitempty"`
-		Le      *Le      `xml:"le,omitempty"`
-		Wcard   *Wcard   `xml:"wcard,omitempty"`
-		Anybit  *Anybit  `xml:"anybit,omitempty"`
-		Allbits *Allbits `xml:"allbits,omitempty"`
-	}
-
-	// Equality Filter
-	Eq struct {
-		XMLName  struct{} `xml:"eq"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// Not Equal Filter
-	Ne struct {
-		XMLName  struct{} `xml:"ne"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// Greater Than Filter
-	Gt struct {
-		XMLName  struct{} `xml:"gt"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// Greater Than or Equal to Filter
-	Ge struct {
-		XMLName  struct{} `xml:"ge"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// Less Than Filter
-	Lt struct {
-		XMLName  struct{} `xml:"lt"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// Less Than or Equal to Filter
-	Le struct {
-		XMLName  struct{} `xml:"le"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// Wildcard Filter
-	Wcard struct {
-		XMLName  struct{} `xml:"wcard"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// Any Bits Filter
-	Anybit struct {
-		XMLName  struct{} `xml:"anybit"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	// All Bits Filter
-	Allbits struct {
-		XMLName  struct{} `xml:"allbits"`
-		Class    string   `xml:"class,attr"`
-		Property string   `xml:"property,attr"`
-		Value    string   `xml:"value,attr"`
-	}
-
-	ConfigResolveDn struct {
-		XMLName        struct{} `xml:"configResolveDn"`
-		Cookie         string   `xml:"cookie,attr"`
-		InHierarchical string   `xml:"inHierarchical,attr"`
-		Dn             string   `xml:"dn,attr"`
-	}
-
-	AaaLogout struct {
-		XMLName  struct{} `xml:"aaaLogout"`
-		InCookie string   `xml:"inCookie,attr"`
-	}
-)
+const version = "0.7"
 
 var (
 	ipAddr              string
@@ -302,67 +550,100 @@ var (
 	faultsOnly          bool
 	maxTlsVersionString string
 	propertyFilter      string
+	requireQuorum       string
+	suppressIf          string
+	configFile          string
+	profileNames        string
+	stateFile           string
+	alertOnlyNew        bool
+	autoAck             string
+	collectTechSupport  bool
+	techSupportDest     string
+	techSupportHoldoff  time.Duration
+	viaAgent            string
+	userAgent           string
+	headers             headerList
+	auditFile           string
+	auditSyslog         string
+	validate            bool
+	crawl               string
+	chunkBy             string
+	sessionCache        string
+	outputFormat        string
+	backendName         string
+	label               string
+	showTrace           bool
+	checkName           string
+	softDuringUpgrade   bool
+	hysteresis          string
+	samples             int
+	sampleInterval      time.Duration
+	sampleAggregate     string
+	explain             bool
+	parallel            int
+	maxInstances        int
 )
 
+var statePrefix = map[int]string{0: "OK", 1: "WARN", 2: "CRIT", 3: "UNKNOWN"}
+
 func debugPrintf(level int, format string, a ...interface{}) {
 	if level <= debug {
 		log.Printf(format, a...)
 	}
 }
 
-func logout(client *http.Client, url, cookie string) {
-	xmlAaaLogout := &AaaLogout{InCookie: cookie}
-	buf, _ := xml.Marshal(xmlAaaLogout)
-	debugPrintf(3, "logout request: %s\n", string(buf))
-
-	data := bytes.NewBuffer(buf)
-	resp, err := client.Post(url, "text/xml", data)
-
-	if err != nil {
-		log.Fatal(err)
+// worstState returns the more severe of two plugin exit codes (CRIT > WARN > UNKNOWN > OK)
+func worstState(a, b int) int {
+	rank := map[int]int{0: 0, 3: 1, 1: 2, 2: 3}
+	if rank[b] > rank[a] {
+		return b
 	}
-	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
-
-	debugPrintf(2, "logout respons: %s\n", body)
+	return a
 }
 
-func getXmlAttr(xml_data string, element_name string, attributes []string) (result []string, counter int) {
-
-	counter = 0
-	values := make([]string, maxNumAttrib)
-
-	resultStr := ""
-	decoder := xml.NewDecoder(bytes.NewBufferString(xml_data))
-
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			break
-		}
-		switch t := token.(type) {
-		case xml.StartElement:
-			elmt := xml.StartElement(t)
-			name := elmt.Name.Local
-
-			if name == element_name {
-				counter++
-				for _, attr := range token.(xml.StartElement).Attr {
-					attr_name := attr.Name.Local
-					attr_value := attr.Value
-					if i := findIndex(attr_name, attributes); i > -1 {
-						values[i] = attr_value
-					}
-					resultStr = strings.Join(values, ",")
-				}
-				result = append(result, strings.TrimRight(resultStr, ","))
-				resultStr = ""
-			}
-
+func logout(client *http.Client, url, cookie string) {
+	if session != nil {
+		expired := sessionMaxAge > 0 && time.Since(session.Created) > sessionMaxAge
+		if expired {
+			debugPrintf(2, "cached session older than %s, logging out\n", sessionMaxAge)
+			session.Cookie = ""
+		}
+		if err := session.save(); err != nil {
+			debugPrintf(1, "session cache error: %v\n", err)
+		}
+		if !expired {
+			// keep the session for the next run
+			return
 		}
 	}
+	api := &ucsxml.Client{HTTP: client, URL: url, Cookie: cookie}
+	if err := api.Logout(); err != nil {
+		// the result of the check is complete, the session times out on UCS
+		debugPrintf(1, "logout error: %v\n", err)
+	}
+}
 
-	return result, counter
+// readBody reads a complete XML API response, see ucsxml.ReadBody
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := ucsxml.ReadBody(resp)
+	countAPIError(err)
+	return body, err
+}
+
+// decodeEnvelope checks the root element of the response to a request
+// method, see ucsxml.DecodeEnvelope
+func decodeEnvelope(body []byte, method string) (*ucsxml.Envelope, error) {
+	env, err := ucsxml.DecodeEnvelope(body, method)
+	countAPIError(err)
+	return env, err
+}
+
+// getXmlAttr returns every element_name object found in xml_data with its dn
+// and the requested attributes, see ucsxml.ParseObjects
+func getXmlAttr(xml_data string, element_name string, attributes []string) ([]managedObject, error) {
+	objects, err := ucsxml.ParseObjects([]byte(xml_data), element_name, attributes, ignoreAttrCase)
+	countAPIError(err)
+	return objects, err
 }
 
 func findIndex(a string, list []string) int {
@@ -374,223 +655,751 @@ func findIndex(a string, list []string) int {
 	return -1
 }
 
+// parseQuorum parses a quorum definition like "2 of 4" into the number of
+// objects required to be ok and the number of objects expected in total.
+func parseQuorum(s string) (need int, total int, err error) {
+	if _, err = fmt.Sscanf(s, "%d of %d", &need, &total); err != nil {
+		return 0, 0, fmt.Errorf("invalid quorum %q, expected format \"<k> of <n>\"", s)
+	}
+	if need < 1 || need > total {
+		return 0, 0, fmt.Errorf("invalid quorum %q, k must be between 1 and n", s)
+	}
+	return need, total, nil
+}
+
+// regexHints explain the common mistakes in the regexes of -e, -suppress-if
+// and the wcard filter of -f, e.g. a glob *Optimal instead of a regex
+var regexHints = map[syntax.ErrorCode]string{
+	syntax.ErrMissingParen:          `parentheses must be balanced, match a literal parenthesis with \( or \)`,
+	syntax.ErrUnexpectedParen:       `parentheses must be balanced, match a literal parenthesis with \( or \)`,
+	syntax.ErrMissingBracket:        `character classes [...] must be closed, match a literal bracket with \[`,
+	syntax.ErrMissingRepeatArgument: `* + ? repeat the expression before them, this is a regex and not a glob: use .* or match a literal * with \*`,
+	syntax.ErrInvalidRepeatOp:       `* + ? repeat the expression before them, match a literal * + ? with \* \+ \?`,
+	syntax.ErrInvalidRepeatSize:     `repeat counts {n,m} must be at most 1000, match a literal { with \{`,
+	syntax.ErrInvalidEscape:         `unknown escape sequence, match a literal backslash with \\`,
+	syntax.ErrTrailingBackslash:     `the regex ends with a backslash, match a literal backslash with \\`,
+	syntax.ErrInvalidPerlOp:         `lookahead, lookbehind and backreferences are not supported (RE2 syntax)`,
+	syntax.ErrInvalidCharRange:      `character ranges [a-z] must be ascending, match a literal - at the end of the class: [a-z-]`,
+}
+
+// checkRegex returns an error with the position of the syntax error and a
+// hint if expr of flag name is not a valid regex
+func checkRegex(name, expr string) error {
+	_, err := regexp.Compile(expr)
+	if err == nil {
+		return nil
+	}
+	serr, ok := err.(*syntax.Error)
+	if !ok {
+		return fmt.Errorf("flag %s: invalid regex \"%s\": %v", name, expr, err)
+	}
+	msg := fmt.Sprintf("flag %s: invalid regex \"%s\": %s", name, expr, serr.Code)
+	// the position of the offending part, e.g. a * without expression before it
+	if pos := strings.Index(expr, serr.Expr); pos >= 0 && serr.Expr != expr {
+		msg += fmt.Sprintf(" at position %d (%s)", pos+1, serr.Expr)
+	}
+	if hint, ok := regexHints[serr.Code]; ok {
+		msg += ", hint: " + hint
+	}
+	return fmt.Errorf("%s", msg)
+}
+
 func init() {
-	flag.StringVar(&ipAddr, "H", "", "UCS Manager IP address or CIMC IP address")
+	ucsxml.Debugf = debugPrintf
+
+	flag.StringVar(&ipAddr, "H", "", "UCS Manager IP address or CIMC IP address, several comma separated addresses of the system are tried in order,\nbatch mode: srv:<name> or consul:<service>[@<dc>] checks all targets of the DNS SRV or Consul lookup")
 	flag.StringVar(&queryType, "t", "class", "query type 'class' or 'dn'")
-	flag.StringVar(&dnOrClass, "q", "storageLocalDisk", "XML API object class name, examples: storageVirtualDrive or storageLocalDisk or storageControllerProps\nor Distinguished Name (DN) name, examples: \"sys/rack-unit-1\"")
+	flag.StringVar(&dnOrClass, "q", "storageLocalDisk", "XML API object class name, examples: storageVirtualDrive or storageLocalDisk or storageControllerProps\nor Distinguished Name (DN) name, examples: \"sys/rack-unit-1\", braces are expanded: \"sys/chassis-{1..8}/psu-{1..4}\"")
 	flag.StringVar(&class, "o", "", "XML API object class name, examples: storageVirtualDrive or storageLocalDisk")
 	flag.StringVar(&hierarchical, "s", "false", "true or false. If true, the inHierarchical argument returns all child objects")
 	flag.StringVar(&attributes, "a", "id name", "space separated list of XML attributes for display in nagios output and match against *expect* string")
 	flag.StringVar(&expectString, "e", "Optimal", "expect string, ok if this is found, examples: 'Optimal' or 'Good' or 'Optimal|Good'")
 	flag.StringVar(&username, "u", "", "XML API username")
 	flag.StringVar(&password, "p", "", "XML API password")
+	flag.StringVar(&usernameFile, "ufile", "", "file with the XML API username, if -u isn't given, default: environment variable "+usernameEnv)
+	flag.StringVar(&passwordFile, "pfile", "", "file with the XML API password, if -p isn't given, default: environment variable "+passwordEnv)
 	flag.IntVar(&debug, "d", 0, "print debug, level: 1 errors only, 2 warnings and 3 informational messages")
 	flag.BoolVar(&showEnv, "E", false, "print environment variables for debug purpose")
 	flag.BoolVar(&showVersion, "V", false, "print plugin version")
+	flag.StringVar(&cipherSpec, "ciphers", "", "comma separated cipher suites of TLS 1.0 to 1.2, Go names or the presets modern, intermediate and legacy-cimc (old CIMC firmware), default: Go defaults")
+	flag.BoolVar(&fipsMode, "fips", fipsBuild, "FIPS mode: TLS 1.2 with FIPS 140 approved cipher suites only, -k is refused")
 	flag.StringVar(&proxyString, "P", "", "proxy URL")
+	flag.StringVar(&sshJump, "ssh-jump", "", "tunnel the HTTPS requests through an SSH connection to this jump host, [<user>@]<host>[:<port>], needs the build tag sshjump")
+	flag.StringVar(&sshJumpKey, "ssh-key", "", "private key (without passphrase) of -ssh-jump, default: ~/.ssh/id_ed25519, id_ecdsa or id_rsa and the keys of the ssh-agent")
 	flag.BoolVar(&zeroInst, "z", false, "true or false. if set to true the check will return OK status if zero instances where found. Default is false.")
 	flag.BoolVar(&faultsOnly, "F", false, "display only faults in output")
-	flag.StringVar(&maxTlsVersionString, "M", "1.1", "used TLS version, default: v1.1")
-	flag.StringVar(&propertyFilter, "f", "", "property filter <type>:<property>:<value>, works only with query type class (-t class), example: wcard:dn:^sys/chassis-[1-3].*")
+	flag.StringVar(&maxTlsVersionString, "M", "1.1", "maximum TLS version 1.0, 1.1, 1.2 or 1.3")
+	flag.StringVar(&minTlsVersionString, "tls-min", "", "minimum TLS version 1.0, 1.1, 1.2 or 1.3, default: 1.2 or -M if it is lower")
+	flag.BoolVar(&insecureSkipVerify, "k", false, "don't verify the certificate of the UCS Manager or CIMC, e.g. a self-signed certificate")
+	flag.StringVar(&caFile, "cafile", "", "file with the CA certificates (PEM) the certificate is verified against, default: the system CA certificates")
+	flag.StringVar(&propertyFilter, "f", "", "property filter <type>:<property>:<value> or composite filter and(...), or(...), not(...) of filters, works only with query type class (-t class), example: wcard:dn:^sys/chassis-[1-3].*")
+	flag.StringVar(&orgScope, "org", "", "UCS Manager organization the class queries are limited to (dn filter), including its sub-organizations, example: org-root/org-tenantA")
+	flag.BoolVar(&clientFilter, "client-filter", false, "apply the property filter -f (eq, ne, wcard) to the unfiltered objects instead of sending it, wcard values are Go regexes")
+	flag.IntVar(&warnFailed, "warn-failed", 0, "WARN if up to this number of objects fail the expect string, CRIT above, default: 0")
+	flag.IntVar(&critFailed, "crit-failed", 0, "CRIT only if more than this number of objects fail the expect string, fewer are WARN (up to -warn-failed, not above it), default: 0 (CRIT from the first failed object)")
+	flag.StringVar(&requireQuorum, "require", "", "quorum \"<k> of <n>\" for redundant objects, CRIT if less than k objects are ok, WARN if less than n objects are ok")
+	flag.StringVar(&suppressIf, "suppress-if", "", "regex matched against the whole result set (all objects, one per line), if found the check returns OK")
+	flag.StringVar(&configFile, "config", "", "config file with check profiles")
+	flag.StringVar(&profileNames, "profile", "", "comma separated list of profiles to run, default: all profiles of the config file")
+	flag.StringVar(&stateFile, "state-file", "", "file to keep state between runs, objects not found in the previous run are tagged NEW, or redis://[:<password>@]<host>[:<port>][/<db>] or sqlite:<file>")
+	flag.StringVar(&configSigKey, "config-sig", "", "Ed25519 public key (PEM): read -config, -expect-file and -layout-file only with a valid signature <file>.sig")
+	flag.BoolVar(&daemonMode, "daemon", false, "Prometheus exporter: run the check or the profiles of -config every -interval and serve the metrics on -listen")
+	flag.StringVar(&daemonListen, "listen", ":9710", "TCP address of the /metrics endpoint of -daemon")
+	flag.DurationVar(&daemonInterval, "interval", time.Minute, "interval of the runs of -daemon")
+	flag.StringVar(&leaseLocation, "lease", "", "poll the UCS domain only on the node holding its lease: directory of lock files on shared storage or redis://[:<password>@]<host>[:<port>][/<db>]")
+	flag.DurationVar(&leaseTTL, "lease-ttl", 5*time.Minute, "time a lease of -lease is held without renewal, longer than the check interval")
+	flag.StringVar(&leaseOwner, "lease-owner", "", "owner of the leases of -lease, default: the host name")
+	flag.BoolVar(&alertOnlyNew, "alert-only-new", false, "only objects tagged NEW can fail the check, requires -state-file")
+	flag.StringVar(&autoAck, "auto-ack", "", "comma separated list of fault codes to acknowledge after reporting them, works only with class faultInst and needs fault privileges, example: F0461,F0181")
+	flag.BoolVar(&collectTechSupport, "collect-techsupport-on-crit", false, "start a tech-support collection if the check is CRIT and report the export status")
+	flag.StringVar(&techSupportDest, "techsupport-dest", "", "CIMC only: tech-support export destination <protocol>://<user>:<password>@<host>/<file>, protocol tftp, ftp, sftp, scp or http")
+	flag.DurationVar(&techSupportHoldoff, "techsupport-holdoff", 24*time.Hour, "minimum time between two tech-support collections, needs -state-file")
+	flag.StringVar(&viaAgent, "via-agent", "", "send the requests through the agent listening on this unix socket or TCP address, direct requests if the agent is not running")
+	flag.StringVar(&userAgent, "user-agent", "check_cisco_ucs/"+version, "User-Agent header of the HTTP requests")
+	flag.Var(&headers, "header", "additional HTTP request header <name>=<value>, can be repeated")
+	flag.StringVar(&hmacFile, "hmac-file", "", "file with a shared secret (first line), every request gets a HMAC-SHA256 signature header t=<unix time>,sig=<hex> for an API gateway")
+	flag.StringVar(&hmacHeader, "hmac-header", "X-Monitoring-Signature", "name of the signature header of -hmac-file")
+	flag.StringVar(&auditFile, "audit-log", "", "append a line for every write operation (ack, led, power, tech-support) to this file")
+	flag.StringVar(&auditSyslog, "audit-syslog", "", "send a syslog message for every write operation to udp://<host>:<port> or tcp://<host>:<port>")
+	flag.BoolVar(&ignoreAttrCase, "ignore-attr-case", false, "match the attributes of -a case-insensitively, e.g. operstate finds operState")
+	flag.BoolVar(&validate, "validate", false, "check the structure of the response (wrapper element, classId, attribute presence), UNKNOWN if anomalies are found")
+	flag.StringVar(&crawl, "crawl", "", "crawl strategy of class queries, 'chassis': query the subtree of every chassis instead of the whole domain, UCS Manager only")
+	flag.StringVar(&chunkBy, "chunk-by", "", "split class queries into one query per 'chassis' or 'rack-unit', filtered by the dn, UCS Manager only")
+	flag.StringVar(&sessionCache, "session-cache", "", "directory to keep the session cookie and TLS session tickets between runs, the session is not logged out")
+	flag.DurationVar(&sessionMaxAge, "session-max-age", 0, "log out a session of -session-cache at the end of the run once it is older than this, 0: never")
+	flag.StringVar(&fallbackFi, "fallback-fi", "", "UCS Manager: comma separated management addresses of the fabric interconnects, tried if the cluster VIP (-H) is unreachable")
+	flag.StringVar(&sharedQueryCache, "shared-query-cache", "", "directory to share the response of a query for -shared-query-ttl between runs evaluating different attributes, expect strings or thresholds")
+	flag.DurationVar(&sharedQueryTTL, "shared-query-ttl", time.Minute, "maximum age of a response of -shared-query-cache")
+	flag.StringVar(&backendName, "backend", "ucs-xml", "protocol: ucs-xml (UCS Manager, CIMC), ucs-central or redfish (CIMC, -t dn -q <path> [-o <array>])")
+	flag.StringVar(&label, "label", "", "name of the UCS domain prefixing the status line, default for the machine readable output formats: the host (-H)")
+	flag.StringVar(&checkName, "check", "", "built-in check template setting -t, -q, -a, -e, ..., flags given on the command line take precedence, see subcommand examples")
+	flag.BoolVar(&explain, "explain", false, "print the class, attributes, expect string and states of the template of -check and exit")
+	flag.Var(&warnThresholds, "w", "warning threshold <attribute>=<range>, WARN if the value is outside of the Nagios range (30, 1:, ~:30, 10:30, @10:30), can be repeated, example: -w ambientTempAvg=30")
+	flag.Var(&critThresholds, "c", "critical threshold <attribute>=<range>, CRIT if the value is outside of the Nagios range, can be repeated, example: -c ambientTempAvg=35")
+	flag.StringVar(&critSeverities, "crit-sev", "", "comma separated fault severities (critical, major, minor, warning, info, condition, cleared) which are CRIT, the expect string isn't evaluated, requires the attribute severity, example: critical,major")
+	flag.StringVar(&descrNormalize, "descr-normalize", "none", "normalize the descr attributes of localized UCS Manager: none, strip (line breaks, control characters, commas) or ascii (strip and transliterate to ASCII)")
+	flag.StringVar(&warnSeverities, "warn-sev", "", "comma separated fault severities which are WARN, see -crit-sev, example: minor,warning")
+	flag.StringVar(&hysteresis, "hysteresis", "", "margin below a threshold (percentage of the threshold or value) the value must drop to return from WARN or CRIT, requires -state-file, example: 5%")
+	flag.StringVar(&joinSpec, "join", "", "add the attributes of the objects of a second class with the same dn prefix, <class>:<attributes>, example: \"equipmentPsuStats:outputPower ambientTemp\"")
+	flag.BoolVar(&hostFirmware, "host-firmware", false, "add the running firmware (fwVersion, fwPackage) of every object and fwMatch: yes if the package is the one of the server's CIMC firmware")
+	flag.BoolVar(&hostFirmwarePackage, "hfp", false, "compare the running firmware of the server of every object with the host firmware package of its service profile, adds hfpVersion, runningVersion and hfpStatus")
+	flag.StringVar(&expectFile, "expect-file", "", "file with the expected BIOS tokens (YAML: <class>: <attribute>: <value>) of the servers of the query, adds the attribute bios: compliant or the drift")
+	flag.StringVar(&bootOrder, "boot-order", "", "expected boot order of the boot definitions of the query (-s true), comma separated devices, adds the attributes bootOrder and bootMatch (yes or no)")
+	flag.StringVar(&layoutFile, "layout-file", "", "file with the expected disk states per server model and slot (YAML: <model>: <slot>: <state>), adds the attribute layout: compliant or the drift")
+	flag.BoolVar(&hotSpares, "hot-spares", false, "add the number of hot spare disks of the storage controller of every object as attribute hotSpares, e.g. of virtual drives")
+	flag.Var(&derived, "derive", "derived attribute <name>=<expression> of numeric attributes (+ - * / and parentheses), can be repeated, example: efficiency=outputPower/inputPower*100")
+	flag.StringVar(&imbalanceAttr, "imbalance", "", "numeric attribute, adds the attribute imbalance: deviation in percent from the average of the objects of the same chassis, rack server or fabric interconnect")
+	flag.StringVar(&consistentAttrs, "consistent", "", "space separated attributes, adds the attribute consistent: yes if the object has the values of the majority of the objects of the same chassis, rack server or fabric interconnect")
+	flag.StringVar(&probeSpec, "probe", "", "probe the address in this attribute of every object with a TCP connection, <attribute>[:<port>] (default port 443), adds the attribute reachable (yes or no)")
+	flag.StringVar(&perfdataAttrs, "perfdata", "", "space separated list of numeric attributes (queried or derived) reported as performance data of every object")
+	flag.StringVar(&perfdataLabelTemplate, "perfdata-label", "", "label template of -perfdata with the placeholders {dn}, {rn}, {path}, {1}, {2}, ..., {attr} and {label}, example: {path}_{attr}")
+	flag.IntVar(&samples, "samples", 1, "number of readings of the objects within one run, numeric values are aggregated (-sample-aggregate)")
+	flag.DurationVar(&sampleInterval, "sample-interval", 5*time.Second, "time between two readings of -samples")
+	flag.StringVar(&sampleAggregate, "sample-aggregate", "avg", "aggregation of the numeric values of -samples: avg or median")
+	flag.BoolVar(&softDuringUpgrade, "soft-during-upgrade", false, "WARN instead of CRIT while an infrastructure firmware upgrade is running (FSM of UCS Manager, fabric interconnects or IO modules)")
+	flag.BoolVar(&showSession, "show-session", false, "append the privileges, refresh period, domains and version of the aaaLogin response to the long output (JSON output: session)")
+	flag.BoolVar(&showTrace, "trace", false, "append the timeline of the phases (dns, connect, tls, login, query, parse, evaluate, logout) to the long output")
+	flag.StringVar(&outputFormat, "output", "nagios", "output format: nagios, json, csv, zabbix, checkmk, influx, prometheus or table (default on a terminal)")
+	flag.StringVar(&dialect, "dialect", "", "plugin output of the monitoring core: nagios (perfdata on the status line, 8 KB), naemon or icinga2 (perfdata after the long output), default: perfdata after the output")
+	flag.IntVar(&maxInstances, "max-instances", 0, "UNKNOWN without evaluating the objects if the query returns more objects, 0: no limit")
+	flag.StringVar(&samplePercent, "sample", "", "percentage of the objects evaluated per run, rotating by dn so all objects are covered over time, requires -state-file, example: 10%")
+	flag.IntVar(&limitObjects, "limit", 0, "number of objects evaluated per run, rotating by dn like -sample, requires -state-file, 0: all")
+	flag.StringVar(&rotateGroup, "rotate-group", "", "k/N: check one of N groups of chassis and rack servers per run, starting with group k, requires -state-file, example: 1/4")
+	flag.StringVar(&touchFile, "touch-file", "", "file written after every run with a result other than UNKNOWN, for an external watchdog, {label}: name of the UCS domain")
+	flag.BoolVar(&slaTracking, "sla", false, "record the outages of the objects in the state (-state-file) for the availability report of subcommand report sla")
+	flag.BoolVar(&healthScore, "score", false, "report the health score of the UCS domain (0-100, weighted by fault severity and failed objects) as perfdata health_score")
+	flag.Var(&timeout, "timeout", "timeout of the run in seconds (or a duration like 1m30s), requests still running are canceled and the check is UNKNOWN, 0: none")
+	flag.DurationVar(&deadline, "deadline", 0, "execution budget, no further profiles, batch targets or chunks are started after it, the results so far and the skipped ones are reported, 0: none")
+	flag.IntVar(&parallel, "parallel", 4, "maximum number of concurrent requests of -crawl and -chunk-by or targets of batch mode, 1: sequential")
 }
 
-func main() {
-	flag.Parse()
+// tooManyInstances returns the message of a query exceeding -max-instances
+// with the ways to narrow the query
+func tooManyInstances(n int) string {
+	var hints []string
+	if hierarchical == "true" {
+		hints = append(hints, "without child objects (-s false)")
+	}
+	if queryType == "class" {
+		hints = append(hints, "with a property filter (-f)", "as dn query of a subtree (-t dn -q sys/chassis-1 -o "+dnOrClass+")")
+	}
+	msg := fmt.Sprintf("%d %s objects, more than -max-instances %d, not evaluated", n, class, maxInstances)
+	if len(hints) > 0 {
+		msg += "\nnarrow the query " + strings.Join(hints, ", ")
+	}
+	return msg + " or raise -max-instances"
+}
 
-	// send errors to Stdout instead to Stderr
-	// http://nagiosplug.sourceforge.net/developer-guidelines.html#PLUGOUTPUT
-	log.SetOutput(os.Stdout)
-	if showEnv {
-		log.Printf("** environment variables start **\n")
-		for _, v := range os.Environ() {
-			log.Printf("%s\n", v)
+// validateCheckFlags checks the flags of a single check before any request is sent
+func validateCheckFlags() error {
+	if len(requireQuorum) > 0 {
+		if _, _, err := parseQuorum(requireQuorum); err != nil {
+			return err
 		}
-		log.Printf("** environment variables end **\n")
 	}
-	if showVersion {
-		fmt.Printf("%s version: %s\n", path.Base(os.Args[0]), version)
-		os.Exit(0)
+	if err := validateFailedCounts(); err != nil {
+		return err
+	}
+	if alertOnlyNew && len(stateFile) == 0 {
+		return fmt.Errorf("flag -alert-only-new requires -state-file")
+	}
+	if len(checkName) > 0 {
+		if t, err := findTemplate(checkName); err == nil {
+			if err := t.needed(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := checkRegex("-e", expectString); err != nil {
+		return err
+	}
+	if err := checkRegex("-suppress-if", suppressIf); err != nil {
+		return err
+	}
+	if err := validatePropertyFilter(); err != nil {
+		return err
+	}
+	if err := validateOrg(); err != nil {
+		return err
 	}
 	attributeArray := strings.Split(attributes, " ")
-	attributeDescr := strings.Replace(attributes, " ", ",", -1)
+	if len(joinSpec) > 0 {
+		_, attrs, err := parseJoin()
+		if err != nil {
+			return err
+		}
+		for _, attr := range attrs {
+			if findIndex(attr, attributeArray) >= 0 {
+				return fmt.Errorf("attribute %s of -join is already one of the attributes (-a)", attr)
+			}
+		}
+		attributeArray = append(attributeArray, attrs...)
+	}
+	attributeArray = append(append(attributeArray, hostFirmwareAttrs()...), hfpAttrs()...)
+	if len(expectFile) > 0 {
+		if _, err := loadExpectFile(expectFile); err != nil {
+			return fmt.Errorf("flag -expect-file: %v", err)
+		}
+		attributeArray = append(attributeArray, biosPolicyAttrs()...)
+	}
+	if len(bootOrder) > 0 {
+		if hierarchical != "true" {
+			return fmt.Errorf("flag -boot-order needs the child objects of the boot definitions (-s true)")
+		}
+		attributeArray = append(attributeArray, bootOrderAttrs()...)
+	}
+	if len(layoutFile) > 0 {
+		if _, err := loadLayoutFile(layoutFile); err != nil {
+			return fmt.Errorf("flag -layout-file: %v", err)
+		}
+		if len(diskStateAttr()) == 0 {
+			return fmt.Errorf("flag -layout-file needs the state of the disks in the attributes (-a): %s", strings.Join(diskStateAttrs, " or "))
+		}
+		attributeArray = append(attributeArray, layoutAttrs()...)
+	}
+	attributeArray = append(attributeArray, hotSpareAttrs()...)
+	for _, attr := range derived.inputs() {
+		if findIndex(attr, attributeArray) < 0 {
+			return fmt.Errorf("attribute %s of -derive is not one of the attributes (-a, -join)", attr)
+		}
+	}
+	attributeArray = append(attributeArray, derived.names()...)
+	if len(imbalanceAttr) > 0 {
+		if findIndex(imbalanceAttr, attributeArray) < 0 {
+			return fmt.Errorf("attribute %s of -imbalance is not one of the attributes (-a) or derived attributes (-derive)", imbalanceAttr)
+		}
+		attributeArray = append(attributeArray, imbalanceName)
+	}
+	for _, attr := range strings.Fields(consistentAttrs) {
+		if findIndex(attr, attributeArray) < 0 {
+			return fmt.Errorf("attribute %s of -consistent is not one of the attributes (-a, -host-firmware) or derived attributes (-derive)", attr)
+		}
+	}
+	attributeArray = append(attributeArray, consistencyAttrs()...)
+	if len(probeSpec) > 0 {
+		attr, _, err := parseProbe()
+		if err != nil {
+			return err
+		}
+		if findIndex(attr, attributeArray) < 0 {
+			return fmt.Errorf("attribute %s of -probe is not one of the attributes (-a)", attr)
+		}
+	}
+	for _, attr := range thresholdAttrs() {
+		if findIndex(attr, attributeArray) < 0 {
+			return fmt.Errorf("threshold attribute %s is not one of the attributes (-a) or derived attributes (-derive)", attr)
+		}
+	}
+	if err := validateSeverities(attributeArray); err != nil {
+		return err
+	}
+	if err := validateDescrNormalize(); err != nil {
+		return err
+	}
+	if err := validateSLA(); err != nil {
+		return err
+	}
+	for _, attr := range strings.Fields(perfdataAttrs) {
+		if findIndex(attr, attributeArray) < 0 {
+			return fmt.Errorf("perfdata attribute %s is not one of the attributes (-a) or derived attributes (-derive)", attr)
+		}
+	}
+	if err := validatePerfdataLabel(); err != nil {
+		return err
+	}
+	if samples < 1 || (sampleAggregate != "avg" && sampleAggregate != "median") {
+		return fmt.Errorf("flag -samples must be at least 1, -sample-aggregate avg or median")
+	}
+	if len(sharedQueryCache) > 0 && samples > 1 {
+		return fmt.Errorf("flag -samples can't be used with -shared-query-cache, all samples would be the cached response")
+	}
+	if len(hysteresis) > 0 {
+		if _, err := parseHysteresis(hysteresis); err != nil {
+			return err
+		}
+		if len(stateFile) == 0 || len(thresholdAttrs()) == 0 {
+			return fmt.Errorf("flag -hysteresis requires -state-file and thresholds (-w or -c)")
+		}
+	}
+	if len(autoAck) > 0 {
+		checkClass := class
+		if queryType == "class" {
+			checkClass = dnOrClass
+		}
+		if checkClass != "faultInst" {
+			return fmt.Errorf("flag -auto-ack works only with object class faultInst")
+		}
+	}
+	if len(crawl) > 0 {
+		if crawl != "chassis" {
+			return fmt.Errorf("unknown crawl strategy %q, expected chassis", crawl)
+		}
+		if queryType != "class" || len(serverFilter()) > 0 {
+			return fmt.Errorf("flag -crawl works only with query type class (-t class) and without property filter (-f) sent to UCS, use -client-filter")
+		}
+	}
+	if len(chunkBy) > 0 {
+		if _, ok := chunkClasses[chunkBy]; !ok {
+			return fmt.Errorf("unknown chunk %q, expected chassis or rack-unit", chunkBy)
+		}
+		if queryType != "class" || len(serverFilter()) > 0 || len(crawl) > 0 {
+			return fmt.Errorf("flag -chunk-by works only with query type class (-t class) and without property filter (-f) sent to UCS, use -client-filter, or -crawl")
+		}
+	}
+	if !isXmlBackend() {
+		if validate || len(autoAck) > 0 || collectTechSupport || len(crawl) > 0 || len(chunkBy) > 0 || softDuringUpgrade || len(joinSpec) > 0 || len(sharedQueryCache) > 0 || clientFilter || hostFirmware || hostFirmwarePackage || len(expectFile) > 0 || len(bootOrder) > 0 || len(layoutFile) > 0 || hotSpares {
+			return fmt.Errorf("flags -validate, -auto-ack, -collect-techsupport-on-crit, -crawl, -chunk-by, -soft-during-upgrade, -join, -shared-query-cache, -client-filter, -host-firmware, -hfp, -expect-file, -boot-order, -layout-file and -hot-spares need the XML API (-backend ucs-xml or ucs-central)")
+		}
+		if backendName == "redfish" && queryType != "dn" {
+			return fmt.Errorf("backend redfish needs query type dn (-t dn) with a Redfish path, example: -q /redfish/v1/Chassis/1/Power -o PowerSupplies")
+		}
+	}
+	if queryType == "dn" && isDnPattern(dnOrClass) {
+		if !isXmlBackend() {
+			return fmt.Errorf("dn patterns (-q with braces) need the XML API (-backend ucs-xml or ucs-central)")
+		}
+		if _, err := expandDn(dnOrClass); err != nil {
+			return err
+		}
+	}
+	if maxInstances < 0 {
+		return fmt.Errorf("flag -max-instances must not be negative")
+	}
+	if err := validateSubset(); err != nil {
+		return err
+	}
+	if err := validateRotation(); err != nil {
+		return err
+	}
+	if parallel < 1 {
+		return fmt.Errorf("flag -parallel must be at least 1")
+	}
+	return nil
+}
 
-	debugPrintf(3, "attributes: %v\n", attributeArray)
+func newClient() *http.Client {
+	minTlsVersion, maxTlsVersion, err := tlsVersionRange()
+	if err != nil {
+		exitUnknown(err.Error())
+	}
+	if err := validateFips(); err != nil {
+		exitUnknown(err.Error())
+	}
+	roots, err := rootCAs()
+	if err != nil {
+		exitUnknown(fmt.Sprintf("-cafile: %v", err))
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+		RootCAs:            roots,
+		MinVersion:         minTlsVersion,
+		MaxVersion:         maxTlsVersion,
+	}
+	if fipsMode {
+		fipsConfig(tlsConfig)
+	}
+	ciphers, err := tlsCiphers()
+	if err != nil {
+		exitUnknown(err.Error())
+	}
+	if ciphers != nil {
+		tlsConfig.CipherSuites = ciphers
+	}
+	if session != nil {
+		tlsConfig.ClientSessionCache = session
+	}
+	if err := validateSSHJump(); err != nil {
+		exitUnknown(err.Error())
+	}
 
-	if len(attributeArray) > maxNumAttrib {
-		fmt.Printf("maximum number of attibutes is %d\n", maxNumAttrib)
-		os.Exit(3)
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	if len(sshJump) > 0 {
+		transport.Proxy = nil
+		transport.DialContext = sshJumpDial
 	}
+	signed, err := withSignature(transport)
+	if err != nil {
+		exitUnknown(err.Error())
+	}
+	return &http.Client{
+		Transport: withHeaders(signed),
+	}
+}
 
-	output := "Cisco UCS "
-	output += dnOrClass
-	output += " (" + attributeDescr + ")"
+// headerList collects the values of the repeatable flag -header
+type headerList []string
 
-	switch queryType {
-	case "class":
-		class = dnOrClass
-		debugPrintf(2, "query type: class (%s)\n", class)
-	case "dn":
-		dn = dnOrClass
-		debugPrintf(2, "query type: dn (%s)\n", dn)
+func (h *headerList) String() string {
+	return strings.Join(*h, " ")
+}
+
+func (h *headerList) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+		return fmt.Errorf("expected <name>=<value>")
 	}
+	*h = append(*h, value)
+	return nil
+}
 
-	debugPrintf(1, "ip addr: %s dn or class: %s\n", ipAddr, dnOrClass)
-	debugPrintf(1, "hierarchical: %s attributes: \"%s\" expectString: %s\n", hierarchical, attributes, expectString)
+// headerTransport adds the User-Agent and the headers of flag -header to every request
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
 
-	var maxTlsVersion uint16
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if phases != nil {
+		ctx = phases.withClientTrace(ctx)
+	}
+	req = req.Clone(withTimeout(ctx))
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		countError(errNet)
+		if timedOut() {
+			err = timeoutError()
+		}
+	}
+	return resp, err
+}
+
+func withHeaders(base http.RoundTripper) http.RoundTripper {
+	header := make(http.Header)
+	header.Set("User-Agent", userAgent)
+	for _, h := range headers {
+		kv := strings.SplitN(h, "=", 2)
+		header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return &headerTransport{base: base, header: header}
+}
 
-	maxTlsVersion = tls.VersionTLS11
-	if maxTlsVersionString == "1.2" {
-		maxTlsVersion = tls.VersionTLS12
+// agentNetwork returns unix if addr is a socket path, else tcp
+func agentNetwork(addr string) string {
+	if strings.Contains(addr, "/") {
+		return "unix"
 	}
+	return "tcp"
+}
 
+// agentClient returns the HTTP client and the base URL of the agent listening on addr
+func agentClient(addr string) (*http.Client, string) {
+	network := agentNetwork(addr)
 	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				MaxVersion:         maxTlsVersion,
+		Transport: withHeaders(&http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
 			},
-		},
+		}),
 	}
+	return client, "http://agent"
+}
 
-	url := "https://" + ipAddr + "/nuova"
-	debugPrintf(2, "url: %s\n", url)
-	xml_aaaLogin := &AaaLogin{InName: username, InPassword: password}
-	buf, _ := xml.Marshal(xml_aaaLogin)
-	debugPrintf(3, "login request: %s\n", string(buf))
-	data := bytes.NewBuffer(buf)
-	resp, err := client.Post(url, "text/xml", data)
+// apiClient returns the HTTP client and the URL of the XML API. With flag
+// -via-agent the requests go through the agent if it is running.
+func apiClient() (*http.Client, string) {
+	if err := resolveCredentials(); err != nil {
+		exitUnknown(err.Error())
+	}
+	selectAddress()
+	if len(viaAgent) > 0 {
+		conn, err := net.DialTimeout(agentNetwork(viaAgent), viaAgent, time.Second)
+		if err == nil {
+			conn.Close()
+			client, base := agentClient(viaAgent)
+			return client, base + "/nuova?host=" + neturl.QueryEscape(ipAddr)
+		}
+		debugPrintf(1, "agent %s not reachable, direct mode: %v\n", viaAgent, err)
+	}
+	if len(sessionCache) > 0 {
+		var err error
+		if session, err = loadSession(sessionCache); err != nil {
+			debugPrintf(1, "session cache error, not used: %v\n", err)
+		}
+	}
+	return newClient(), "https://" + ipAddr + "/nuova"
+}
 
+// login sends the aaaLogin request and returns the session cookie.
+// On failure the plugin exits with status UNKNOWN.
+func login(client *http.Client, url string) string {
+	if session != nil {
+		if cookie, ok := session.resume(client, url); ok {
+			debugPrintf(1, "cached session cookie: %s\n", cookie)
+			return cookie
+		}
+	}
+
+	api := ucsxml.NewClient(client, url)
+	xmlAaaLoginResp, err := api.Login(username, password)
 	if err != nil {
+		countAPIError(err)
 		debugPrintf(3, "login error: %s\n", err.Error())
-		if strings.Contains(err.Error(), "EOF") {
-			fmt.Printf("CRIT: EOF received from the target system.\n")
-		} else {
-			fmt.Printf("CRIT: %v\n", err)
+		if timedOut() {
+			exitUnknown(timeoutError().Error() + " (login)")
+		}
+		var apiErr *ucsxml.APIError
+		switch {
+		case errors.As(err, &apiErr):
+			exitUnknown(fmt.Sprintf("aaaLogin Error: %s (%s)", apiErr.Descr, apiErr.Code))
+		case !strings.Contains(err.Error(), "EOF"):
+			exitUnknown(err.Error())
+		case ucsxml.ErrorClass(err) == ucsxml.ErrParse:
+			exitUnknown("EOF received from the target system. Check if CIMC interface is working.")
 		}
-		os.Exit(3)
+		exitUnknown("EOF received from the target system.")
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	debugPrintf(1, "login cookie: %s\n", xmlAaaLoginResp.OutCookie)
 
-	debugPrintf(2, "http status code: %s\n", resp.Status)
-	debugPrintf(3, "login response: %s\n", string(body))
+	loginSession = newSessionDetails(xmlAaaLoginResp)
+	if session != nil {
+		session.loggedIn(xmlAaaLoginResp)
+	}
 
-	xmlAaaLoginResp := &AaaLoginResp{Cookie: "", Response: "", OutCookie: "", OutRefreshPeriod: "", OutPriv: ""}
+	return xmlAaaLoginResp.OutCookie
+}
 
-	err = xml.Unmarshal([]byte(body), &xmlAaaLoginResp)
+// check runs the query defined by the check flags (-t, -q, -o, -a, -e, ...)
+// and evaluates the result. The flags must be validated by validateCheckFlags.
+func check(b Backend) *checkResult {
+	var (
+		prefix  string
+		ret_val int
+		output  string
+	)
+	res := newResult()
 
-	if err != nil {
-		if strings.Contains(err.Error(), "EOF") {
-			fmt.Printf("CRIT: EOF received from the target system. Check if CIMC interface is working.\n")
-		} else {
-			fmt.Printf("CRIT: %v\n", err)
-		}
-		os.Exit(3)
+	attributeArray := strings.Split(attributes, " ")
+	attributeDescr := strings.Replace(attributes, " ", ",", -1)
+	if extra := resultAttributes()[len(attributeArray):]; len(extra) > 0 {
+		attributeDescr += "," + strings.Join(extra, ",")
 	}
 
-	defer logout(client, url, xmlAaaLoginResp.OutCookie)
+	debugPrintf(3, "attributes: %v\n", attributeArray)
 
-	debugPrintf(2, "%#v\n", xmlAaaLoginResp)
+	quorumNeed, quorumTotal := 0, 0
+	if len(requireQuorum) > 0 {
+		quorumNeed, quorumTotal, _ = parseQuorum(requireQuorum)
+		debugPrintf(3, "quorum: %d of %d\n", quorumNeed, quorumTotal)
+	}
 
-	debugPrintf(1, "login cookie: %s\n", xmlAaaLoginResp.OutCookie)
-	debugPrintf(3, "login error code: %d\n", xmlAaaLoginResp.ErrorCode)
+	output = "Cisco UCS "
+	output += dnOrClass
+	output += " (" + attributeDescr + ")"
 
-	if xmlAaaLoginResp.ErrorCode != 0 {
-		fmt.Printf("aaaLogin Error: %s (%d)\n", xmlAaaLoginResp.ErrorDescr, xmlAaaLoginResp.ErrorCode)
-		os.Exit(3)
+	switch queryType {
+	case "class":
+		class = dnOrClass
+		debugPrintf(2, "query type: class (%s)\n", class)
+	case "dn":
+		dn = dnOrClass
+		debugPrintf(2, "query type: dn (%s)\n", dn)
 	}
 
+	debugPrintf(1, "ip addr: %s dn or class: %s\n", ipAddr, dnOrClass)
+	debugPrintf(1, "hierarchical: %s attributes: \"%s\" expectString: %s\n", hierarchical, attributes, expectString)
+
 	num_found := 0
 
-	switch queryType {
-	case "class":
-		xmlConfigResolveClass := &ConfigResolveClass{Cookie: xmlAaaLoginResp.OutCookie, InHierarchical: hierarchical, ClassId: class}
-		if len(propertyFilter) > 0 {
-			parts := strings.Split(propertyFilter, ":")
-			debugPrintf(3, "propertyFilter split: %#v\n", parts)
-			switch parts[0] {
-			case "eq":
-				xmlConfigResolveClass.InFilter.Eq = &Eq{Class: class, Property: parts[1], Value: parts[2]}
-			case "ne":
-				xmlConfigResolveClass.InFilter.Ne = &Ne{Class: class, Property: parts[1], Value: parts[2]}
-			case "gt":
-				xmlConfigResolveClass.InFilter.Gt = &Gt{Class: class, Property: parts[1], Value: parts[2]}
-			case "ge":
-				xmlConfigResolveClass.InFilter.Ge = &Ge{Class: class, Property: parts[1], Value: parts[2]}
-			case "lt":
-				xmlConfigResolveClass.InFilter.Lt = &Lt{Class: class, Property: parts[1], Value: parts[2]}
-			case "le":
-				xmlConfigResolveClass.InFilter.Le = &Le{Class: class, Property: parts[1], Value: parts[2]}
-			case "wcard":
-				xmlConfigResolveClass.InFilter.Wcard = &Wcard{Class: class, Property: parts[1], Value: parts[2]}
-			case "anybit":
-				xmlConfigResolveClass.InFilter.Anybit = &Anybit{Class: class, Property: parts[1], Value: parts[2]}
-			case "allbits":
-				xmlConfigResolveClass.InFilter.Allbits = &Allbits{Class: class, Property: parts[1], Value: parts[2]}
+	if err := startRotation(); err != nil {
+		return res.unknown(err.Error())
+	}
+	objects, body, err := sampleObjects(b)
+	if err != nil {
+		return res.unknown(fmt.Sprintf("error: %v", privilegeError(err, class)))
+	}
+	if len(objects) == 0 {
+		if msg := missingPrivileges(loginSession, class); len(msg) > 0 {
+			return res.unknown(output + ": " + msg)
+		}
+	}
+	objects = rotationObjects(objects)
+	if maxInstances > 0 && len(objects) > maxInstances {
+		return res.unknown(output + ": " + tooManyInstances(len(objects)))
+	}
+	if err := hostFirmwareObjects(b, objects); err != nil {
+		return res.unknown(fmt.Sprintf("error: %v", err))
+	}
+	if err := hfpObjects(b, objects); err != nil {
+		return res.unknown(fmt.Sprintf("error: %v", err))
+	}
+	if err := biosPolicyObjects(b, objects); err != nil {
+		return res.unknown(fmt.Sprintf("error: %v", err))
+	}
+	bootOrderObjects(objects, body)
+	if err := layoutObjects(b, objects); err != nil {
+		return res.unknown(fmt.Sprintf("error: %v", err))
+	}
+	if err := hotSpareObjects(b, objects); err != nil {
+		return res.unknown(fmt.Sprintf("error: %v", err))
+	}
+	normalizeObjects(objects)
+	for i := range objects {
+		derived.apply(&objects[i])
+	}
+	applyImbalance(objects)
+	applyConsistency(objects)
+	if objects, err = subsetObjects(objects); err != nil {
+		return res.unknown(err.Error())
+	}
+	probeObjects(objects)
+	defer phases.begin("evaluate")()
+
+	validation := ""
+	if validate {
+		method := "configResolveClass"
+		if queryType == "dn" {
+			method = "configResolveDn"
+			if isDnPattern(dnOrClass) {
+				method = "configResolveDns"
 			}
 		}
-
-		debugPrintf(3, "xmlConfigResolveClass request: %#v\n", xmlConfigResolveClass)
-
-		buf, err = xml.MarshalIndent(xmlConfigResolveClass, "  ", "    ")
-		if err != nil {
-			debugPrintf(2, "xmlConfigResolveClass marshal error: %s\n", err)
+		anomalies, stats := validateResponse(body, method, class, attributeArray)
+		validation = "\nattribute presence: " + strings.Join(stats, ", ")
+		if len(anomalies) > 0 {
+			return res.unknown(output + " response validation failed:\n" + strings.Join(anomalies, "\n") + validation)
 		}
+	}
 
-		debugPrintf(3, "buf before regex:\n%s\n", string(buf))
+	var r, dns []string
+	for _, obj := range objects {
+		r = append(r, obj.Line())
+		dns = append(dns, obj.Dn)
+	}
+	n := len(objects)
+	debugPrintf(3, "result: %v counter: %d\n", r, n)
 
-		// see issue:
-		// encoding/xml: cannot marshal self-closing tag #21399
-		// https://github.com/golang/go/issues/21399
-		re := regexp.MustCompile("></.*?>")
-		result := re.ReplaceAllString(string(buf), " />")
-		data = bytes.NewBuffer([]byte(result))
-		debugPrintf(3, "configResolveClass request:\n%s\n", result)
-		resp, err = client.Post(url, "text/xml", data)
-		if err != nil {
-			fmt.Printf("error: %v", err)
-			os.Exit(3)
+	// objects are identified by dn or, if the dn is missing, by the output line
+	for i := range dns {
+		if len(dns[i]) == 0 {
+			dns[i] = r[i]
 		}
-		defer resp.Body.Close()
-		body, err = ioutil.ReadAll(resp.Body)
-		debugPrintf(2, "configResolveClass respons: %s\n", body)
-
-	case "dn":
-		xmlConfigResolveDn := &ConfigResolveDn{Cookie: xmlAaaLoginResp.OutCookie, InHierarchical: hierarchical, Dn: dn}
+	}
 
-		buf, err = xml.Marshal(xmlConfigResolveDn)
-		if err != nil {
-			log.Printf("xmlConfigResolveDn marshal error: %s\n", err)
-		}
-		debugPrintf(3, "configResolveDn request: %s\n", string(buf))
-		data = bytes.NewBuffer(buf)
-		resp, err = client.Post(url, "text/xml", data)
+	var (
+		cs      *checkState
+		newObjs map[string]bool
+		numNew  int
+	)
+	if len(stateFile) > 0 {
+		var err error
+		cs, err = loadCheckState(stateFile, checkStateKey())
 		if err != nil {
-			fmt.Printf("error: %v", err)
-			os.Exit(3)
+			return res.unknown(fmt.Sprintf("state file error: %v", err))
 		}
-		defer resp.Body.Close()
-		body, err = ioutil.ReadAll(resp.Body)
-		debugPrintf(2, "configResolveDn respons: %s\n", body)
-
+		newObjs = cs.newObjects(dns)
 	}
 
-	// "defer logout" not working ? ... so:
-	logout(client, url, xmlAaaLoginResp.OutCookie)
-
-	r, n := getXmlAttr(string(body), class, attributeArray)
-	debugPrintf(3, "result: %v counter: %d\n", r, n)
-
 	re := regexp.MustCompile(expectString)
 
+	margin, _ := parseHysteresis(hysteresis)
+	var prevThresholds map[string]map[string]int
+	if cs != nil {
+		prevThresholds = cs.Thresholds
+	}
+	thresholdStates := make(map[string]map[string]int)
+	thresholdWorst, numExceeded := 0, 0
+	sevStates, _ := severityStates()
+	numSevere := 0
+
 	debugPrintf(3, "\n%v\n\n", r)
-	for _, val := range r {
+	for i, val := range r {
 		n := len(re.FindAllString(val, -1))
+		if n > 1 || sevStates != nil {
+			// an object is ok once, even if the expect string matches several times,
+			// with -crit-sev and -warn-sev the severity decides
+			n = 1
+		}
+		isNew := newObjs[dns[i]]
+		if isNew {
+			numNew++
+			val = "NEW " + val
+		}
+		if n == 0 && alertOnlyNew && !isNew {
+			// known fault, handled outside of monitoring
+			n = 1
+		}
+		sState := 0
+		if isNew || !alertOnlyNew {
+			sState = objectSeverity(sevStates, objects[i].Attrs)
+		}
+		if sState > 0 {
+			n = 0
+			numSevere++
+			thresholdWorst = worstState(thresholdWorst, sState)
+		}
 		num_found += n
+
+		tState := 0
+		if len(thresholdAttrs()) > 0 {
+			dn := dns[i]
+			states, exceeded := map[string]int(nil), []string(nil)
+			tState, states, exceeded = objectThresholds(objects[i].Attrs, func(attr string) int {
+				return prevThresholds[dn][attr]
+			}, margin)
+			if tState > 0 {
+				numExceeded++
+				thresholdWorst = worstState(thresholdWorst, tState)
+				thresholdStates[dn] = states
+				val += " (" + statePrefix[tState] + ": " + strings.Join(exceeded, ", ") + ")"
+			}
+		}
+
+		objState := worstState(tState, sState)
+		if n == 0 && sState == 0 {
+			objState = 2
+		}
+		res.Objects = append(res.Objects, checkObject{Dn: dns[i], Line: r[i], Attrs: objects[i].Attrs, Ok: n > 0 && tState == 0, State: objState, New: isNew})
+		res.Perfdata = append(res.Perfdata, objectPerfdata(objects[i])...)
 		debugPrintf(3, "%s num_found=%d n=%d", val, num_found, n)
-		if n == 0 && faultsOnly {
+		if (n == 0 || tState > 0) && faultsOnly {
 			output += "\n" + val
 		}
 		if !faultsOnly {
@@ -598,19 +1407,246 @@ func main() {
 		}
 
 	}
+	uniquePerfdataLabels(res.Perfdata)
 
-	prefix := "UNKNOWN"
-	ret_val := 3
+	prefix = "UNKNOWN"
+	ret_val = 3
 
 	// new in version 0.9: output example for case (zeroInst && num_found == 0 && n == 0) ---> "... (0 of 0 ok)" or "... (<num_found> of <n> ok)"
-	if (zeroInst && num_found == 0 && n == 0) || (n > 0 && num_found == n) {
+	if quorumTotal > 0 {
+		// quorum: redundancy margin reduced is WARN, quorum lost is CRIT
+		switch {
+		case num_found < quorumNeed:
+			prefix = "CRIT"
+			ret_val = 2
+		case num_found < quorumTotal:
+			prefix = "WARN"
+			ret_val = 1
+		default:
+			prefix = "OK"
+			ret_val = 0
+		}
+	} else if ((zeroInst || rotationEmpty) && num_found == 0 && n == 0) || (n > 0 && num_found+numSevere == n) {
 		prefix = "OK"
 		ret_val = 0
+	} else if n > 0 && failedCounts() {
+		// count thresholds: WARN or CRIT by the number of failed objects
+		ret_val = failedState(n - num_found - numSevere)
+		prefix = statePrefix[ret_val]
 	} else {
 		prefix = "CRIT"
 		ret_val = 2
 	}
 
-	fmt.Printf("%s - %s (%d of %d ok)\n", prefix, output, num_found, n)
-	os.Exit(ret_val)
+	// thresholds (-w, -c) and severities (-crit-sev, -warn-sev) on top of the expect string
+	if thresholdWorst > 0 {
+		ret_val = worstState(ret_val, thresholdWorst)
+		prefix = statePrefix[ret_val]
+	}
+
+	summary := fmt.Sprintf("%d of %d ok", num_found, n)
+	if len(thresholdAttrs()) > 0 {
+		summary += fmt.Sprintf(", %d %s thresholds", numExceeded, thresholdWord())
+	}
+	if sevStates != nil {
+		summary += fmt.Sprintf(", %d by severity", numSevere)
+	}
+	if cs != nil {
+		summary += fmt.Sprintf(", %d new", numNew)
+	}
+	if quorumTotal > 0 {
+		summary += fmt.Sprintf(", require %d of %d", quorumNeed, quorumTotal)
+	}
+	if failedCounts() {
+		summary += fmt.Sprintf(", %d failed, WARN up to %d, CRIT above %d", n-num_found-numSevere, failedLimit(), failedLimit())
+	}
+
+	// suppress alerts if the whole result set matches, e.g. a parent object in maintenance state
+	if len(suppressIf) > 0 && ret_val != 0 {
+		reSuppress := regexp.MustCompile(suppressIf)
+		if reSuppress.MatchString(strings.Join(r, "\n")) {
+			debugPrintf(2, "suppress-if %q matched, %s suppressed\n", suppressIf, prefix)
+			summary += ", " + prefix + " suppressed"
+			prefix = "OK"
+			ret_val = 0
+		}
+	}
+
+	upgrade := ""
+	if softDuringUpgrade && ret_val == 2 {
+		xb := xmlSession(b)
+		found, err := upgradeInProgress(xb.client, xb.url, xb.cookie)
+		switch {
+		case err != nil:
+			upgrade = "\nfirmware upgrade detection error: " + err.Error()
+		case len(found) > 0:
+			debugPrintf(2, "firmware upgrade in progress: %v\n", found)
+			summary += ", CRIT downgraded to WARN during firmware upgrade"
+			upgrade = "\nfirmware upgrade in progress: " + strings.Join(found, ", ")
+			prefix = "WARN"
+			ret_val = 1
+		}
+	}
+
+	output += " (" + summary + ")" + validation + upgrade + missingAttributeNotes(objects, body, attributeArray)
+	if queryType == "dn" && len(unresolvedDns) > 0 {
+		output += "\nunresolved: " + strings.Join(unresolvedDns, ", ")
+	}
+	output += subsetNote + rotationNote()
+
+	// acknowledge after reporting, so the faults show up at least once
+	if len(autoAck) > 0 && class == "faultInst" {
+		xb := xmlSession(b)
+		output += ackFaults(xb.client, xb.url, xb.cookie, string(body))
+	}
+
+	// collect tech-support data for TAC cases, with a state file at most once per holdoff time
+	if collectTechSupport && ret_val == 2 {
+		if cs != nil && time.Since(cs.TechSupport) < techSupportHoldoff {
+			output += "\ntech-support: collection skipped, last one started " + cs.TechSupport.Format(time.RFC3339)
+		} else {
+			xb := xmlSession(b)
+			output += collectTechSupportData(xb.client, xb.url, xb.cookie)
+			if cs != nil {
+				cs.TechSupport = time.Now()
+			}
+		}
+	}
+
+	if cs != nil {
+		cs.Seen = dns
+		if subsetDns != nil {
+			cs.Seen = subsetDns
+		}
+		cs.Cursor = subsetCursor
+		cs.Thresholds = thresholdStates
+		if slaTracking {
+			cs.trackSLA(res.Objects, dns, time.Now())
+		}
+		if err := saveCheckState(stateFile, checkStateKey(), cs); err != nil {
+			return res.unknown(fmt.Sprintf("state file error: %v", err))
+		}
+	}
+
+	res.State, res.Status, res.Output = ret_val, prefix, output
+	res.NumOk, res.Num = num_found, n
+	return res
+}
+
+func main() {
+	// send errors to Stdout instead to Stderr
+	// http://nagiosplug.sourceforge.net/developer-guidelines.html#PLUGOUTPUT
+	log.SetOutput(os.Stdout)
+
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	parseFlags(flag.CommandLine, os.Args[1:])
+	startDeadline()
+	startTimeout()
+	if err := resolveCredentials(); err != nil {
+		exitUnknown(err.Error())
+	}
+
+	if showTrace {
+		phases = newPhaseTrace()
+	}
+
+	if showEnv {
+		log.Printf("** environment variables start **\n")
+		for _, v := range os.Environ() {
+			log.Printf("%s\n", maskedEnv(v))
+		}
+		log.Printf("** environment variables end **\n")
+	}
+	if showVersion {
+		fmt.Printf("%s version: %s\n", path.Base(os.Args[0]), version)
+		fmt.Println(cryptoPosture())
+		os.Exit(0)
+	}
+
+	if _, ok := backends[backendName]; !ok {
+		exitUnknown(fmt.Sprintf("unknown backend %q, expected one of %s", backendName, backendNames()))
+	}
+	if !isFlagSet("output") && isTerminal() {
+		// manual run, Nagios never runs the plugin on a terminal
+		outputFormat = "table"
+	}
+	if _, ok := renderers[outputFormat]; !ok {
+		exitUnknown(fmt.Sprintf("unknown output format %q, expected one of %s", outputFormat, rendererNames()))
+	}
+	if err := validateDialect(); err != nil {
+		exitUnknown(err.Error())
+	}
+
+	if len(checkName) > 0 {
+		t, err := findTemplate(checkName)
+		if err != nil {
+			exitUnknown(err.Error())
+		}
+		if explain {
+			t.explain(os.Stdout)
+			os.Exit(0)
+		}
+		given := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) {
+			given[f.Name] = true
+		})
+		if err := t.apply(given); err != nil {
+			exitUnknown(err.Error())
+		}
+	} else if explain {
+		exitUnknown(fmt.Sprintf("flag -explain needs a template (-check), one of %s", templateNames()))
+	}
+
+	if isBatch() && len(configFile) > 0 {
+		exitUnknown(fmt.Sprintf("batch mode (-H %s) can't be used with -config", ipAddr))
+	}
+	selectAddress()
+	if err := validateLease(); err != nil {
+		exitUnknown(err.Error())
+	}
+	if daemonMode {
+		os.Exit(runDaemon())
+	}
+	if len(configFile) > 0 {
+		os.Exit(runProfiles())
+	}
+
+	if err := validateCheckFlags(); err != nil {
+		exitUnknown(err.Error())
+	}
+	if isBatch() {
+		os.Exit(runBatch())
+	}
+	if res := leaseHeld(hostName()); res != nil {
+		renderers[outputFormat].Render(os.Stdout, []*checkResult{res})
+		os.Exit(0)
+	}
+
+	res := runCheck()
+	renderers[outputFormat].Render(os.Stdout, []*checkResult{res})
+	touchAfterRun([]*checkResult{res})
+	os.Exit(res.State)
+}
+
+// runCheck logs in, runs the check of the flags and logs out
+func runCheck() *checkResult {
+	b := openBackend()
+	res := check(b)
+	b.Close()
+	incompleteResult(res)
+	res.Output += addressNote
+	if showSession {
+		res.Session = loginSession
+		res.Output += loginSession.String()
+	}
+	res.Output += phases.String()
+	res.Perfdata = append(res.Perfdata, errorPerfdata()...)
+	addScore([]*checkResult{res})
+	return res
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+func TestFaultsToAckCorrupt(t *testing.T) {
+	// corruption in the middle of the stream must not look like missing hardware
+	dns, err := faultsToAck(`<configResolveClass><outConfigs><faultInst dn="f1" code="F0461" ack="no"/><faultInst`, []string{"F0461"})
+	if err == nil {
+		t.Errorf("faultsToAck: no error, %v", dns)
+	}
+}
+
+// hierarchicalResponse returns a configResolveClass response of computeBlade
+// with inHierarchical=true of at least size bytes: blades of 8 per chassis
+// with their adaptors, cpus, dimms, disks and faults
+func hierarchicalResponse(size int) string {
+	var b strings.Builder
+	b.WriteString(`<configResolveClass cookie="1/abc" response="yes" classId="computeBlade"><outConfigs>`)
+	for n := 0; b.Len() < size; n++ {
+		dn := fmt.Sprintf("sys/chassis-%d/blade-%d", n/8+1, n%8+1)
+		fmt.Fprintf(&b, `<computeBlade dn="%s" model="UCSB-B200-M5" serial="FCH%08d" operState="ok" operability="operable" operPower="on" totalMemory="393216" numOfCpus="2" presence="equipped">`, dn, n)
+		fmt.Fprintf(&b, `<adaptorUnit dn="%s/adaptor-1" model="UCSB-MLOM-40G-04" operState="operable" presence="equipped"><adaptorHostEthIf dn="%s/adaptor-1/host-eth-1" mac="00:25:B5:00:00:%02X" operState="up"/></adaptorUnit>`, dn, dn, n%256)
+		for cpu := 1; cpu <= 2; cpu++ {
+			fmt.Fprintf(&b, `<processorUnit dn="%s/board/cpu-%d" model="Intel(R) Xeon(R) Gold 6130" cores="16" operState="operable" presence="equipped"/>`, dn, cpu)
+		}
+		for dimm := 1; dimm <= 12; dimm++ {
+			fmt.Fprintf(&b, `<memoryUnit dn="%s/board/memarray-1/mem-%d" capacity="32768" clock="2666" operState="operable" operability="operable" presence="equipped"/>`, dn, dimm)
+		}
+		for disk := 1; disk <= 2; disk++ {
+			fmt.Fprintf(&b, `<storageLocalDisk dn="%s/board/storage-SAS-1/disk-%d" diskState="good" operability="operable" size="571776"/>`, dn, disk)
+		}
+		fmt.Fprintf(&b, `<faultInst dn="%s/fault-F0181" code="F0181" severity="cleared" ack="yes" descr="Local disk 2 on server %d/%d operability: inoperable"/>`, dn, n/8+1, n%8+1)
+		b.WriteString(`</computeBlade>`)
+	}
+	b.WriteString(`</outConfigs></configResolveClass>`)
+	return b.String()
+}
+
+// The benchmarks parse hierarchical responses of 10MB and more, the size of
+// the responses of big domains, e.g. go test -bench . -benchmem -cpuprofile cpu.out
+
+func BenchmarkGetXmlAttr(b *testing.B) {
+	data := hierarchicalResponse(10 << 20)
+	attributes := []string{"dn", "operState", "operability"}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := getXmlAttr(data, "memoryUnit", attributes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkXmlComplete(b *testing.B) {
+	data := []byte(hierarchicalResponse(10 << 20))
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ucsxml.Complete(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCheck measures a check from the parsed response to the
+// plugin output, readBody included
+func BenchmarkCheck(b *testing.B) {
+	data := hierarchicalResponse(10 << 20)
+	saved := []string{queryType, dnOrClass, class, attributes, expectString}
+	defer func() {
+		queryType, dnOrClass, class, attributes, expectString = saved[0], saved[1], saved[2], saved[3], saved[4]
+	}()
+	queryType, dnOrClass, class = "class", "computeBlade", "memoryUnit"
+	attributes, expectString = "dn operability", ",operable$"
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{Body: ioutil.NopCloser(strings.NewReader(data)), ContentLength: int64(len(data))}
+		body, err := readBody(resp)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if res := check(&fakeBackend{body: string(body)}); res.State != 0 {
+			b.Fatalf("state %d: %.200s", res.State, res.Output)
+		}
+	}
+}
+
+func TestAttributeCase(t *testing.T) {
+	data := `<configResolveClass response="yes" classId="equipmentPsu"><outConfigs><equipmentPsu dn="sys/psu-1" operState="operable" model="N20-PAC5-2500W"/></outConfigs></configResolveClass>`
+	defer func(saved string) { class = saved }(class)
+	class = "equipmentPsu"
+	for _, ignore := range []bool{false, true} {
+		ignoreAttrCase = ignore
+		objects, err := getXmlAttr(data, "equipmentPsu", []string{"dn", "operstate"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := objects[0].Attrs["operstate"]; ok != ignore || (ignore && got != "operable") {
+			t.Errorf("ignore case %v: operstate %q found %v", ignore, got, ok)
+		}
+		notes := missingAttributeNotes(objects, []byte(data), []string{"dn", "operstate", "modle"})
+		want := "\nattribute modle not found in 1 equipmentPsu objects, did you mean model?"
+		if !ignore {
+			want = "\nattribute operstate not found in 1 equipmentPsu objects, did you mean operState?" + want
+		}
+		if notes != want {
+			t.Errorf("ignore case %v: notes %q, want %q", ignore, notes, want)
+		}
+	}
+	ignoreAttrCase = false
+}
+
+func TestCheckRegex(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // part of the error, empty: valid
+	}{
+		{"Optimal|Good", ""},
+		{"", ""},
+		{"*Optimal", "at position 1 (*), hint: * + ? repeat"},
+		{"Good|(?!Bad)", "at position 6 ((?!), hint: lookahead"},
+		{"sys/(chassis", "missing closing ), hint: parentheses"},
+	}
+	for _, tt := range tests {
+		err := checkRegex("-e", tt.expr)
+		switch {
+		case len(tt.want) == 0 && err != nil:
+			t.Errorf("%q: %v", tt.expr, err)
+		case len(tt.want) > 0 && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%q: error %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+// Shell completion: subcommand *completion* prints a completion script for
+// bash, zsh or fish, generated from the flags, subcommands, check templates
+// and output formats of the plugin, and completes the UCS class names of
+// -q and -o.
+//
+//	$ source <(check_cisco_ucs completion bash)
+//	$ check_cisco_ucs completion fish > ~/.config/fish/completions/check_cisco_ucs.fish
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ucsClasses are the XML API classes offered for -q and -o, the classes of
+// the templates are added
+var ucsClasses = []string{
+	"aaaUser", "adaptorExtEthIf", "adaptorHostEthIf", "adaptorHostFcIf", "adaptorUnit",
+	"biosUnit", "commNtpProvider", "computeBlade", "computeBoard", "computeMbPowerStats",
+	"computeMbTempStats", "computeRackUnit", "computeRackUnitMbTempStats", "equipmentChassis",
+	"equipmentChassisStats", "equipmentFan", "equipmentFanModule", "equipmentFanModuleStats",
+	"equipmentFanStats", "equipmentFex", "equipmentIOCard", "equipmentIOCardStats",
+	"equipmentIndicatorLed", "equipmentLocatorLed", "equipmentPsu", "equipmentPsuInputStats",
+	"equipmentPsuStats", "etherPIo", "etherRxStats", "etherTxStats", "eventRecord", "faultInst",
+	"fcPIo", "firmwareRunning", "lsServer", "memoryArray", "memoryUnit", "memoryUnitEnvStats",
+	"mgmtController", "mgmtIf", "networkElement", "processorEnvStats", "processorUnit",
+	"storageController", "storageControllerProps", "storageFlexFlashCard", "storageLocalDisk",
+	"storageLocalDiskProps", "storageRaidBattery", "storageVirtualDrive", "swEnvStats",
+	"swSystemStats", "sysdebugCore", "topSystem",
+}
+
+// completionValues returns the values completed after a flag
+func completionValues() map[string][]string {
+	classes := append([]string{}, ucsClasses...)
+	seen := make(map[string]bool)
+	for _, c := range classes {
+		seen[c] = true
+	}
+	for _, t := range templates {
+		if c := t.value("q"); !seen[c] {
+			seen[c] = true
+			classes = append(classes, c)
+		}
+	}
+	sort.Strings(classes)
+
+	var templateNames, outputs, backendList []string
+	for _, t := range templates {
+		templateNames = append(templateNames, t.name)
+	}
+	for n := range renderers {
+		outputs = append(outputs, n)
+	}
+	for n := range backends {
+		backendList = append(backendList, n)
+	}
+	sort.Strings(outputs)
+	sort.Strings(backendList)
+
+	return map[string][]string{
+		"q":        classes,
+		"o":        classes,
+		"check":    templateNames,
+		"output":   outputs,
+		"backend":  backendList,
+		"t":        {"class", "dn"},
+		"s":        {"true", "false"},
+		"M":        {"1.0", "1.1", "1.2", "1.3"},
+		"tls-min":  {"1.0", "1.1", "1.2", "1.3"},
+		"crawl":    {"chassis"},
+		"chunk-by": {"chassis", "rack-unit"},
+	}
+}
+
+// subcommandArgs are the positional arguments of the subcommands
+func subcommandArgs() map[string][]string {
+	var templateNames []string
+	for _, t := range templates {
+		templateNames = append(templateNames, t.name)
+	}
+	return map[string][]string{
+		"led":        {"on", "off"},
+		"power":      {"status", "cycle", "reset"},
+		"session":    {"stats"},
+		"report":     {"sla"},
+		"examples":   templateNames,
+		"completion": {"bash", "zsh", "fish"},
+	}
+}
+
+// fileFlags complete file names, dirFlags directory names
+var (
+	fileFlags = []string{"config", "state-file", "audit-log", "expect-file", "layout-file", "touch-file", "cafile", "ufile", "pfile", "config-sig", "ssh-key", "hmac-file"}
+	dirFlags  = []string{"session-cache", "shared-query-cache"}
+)
+
+func flagNames() []string {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
+
+func subcommandNames() []string {
+	var names []string
+	for n := range subcommands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeBashCompletion(w io.Writer, name string) {
+	fn := "_" + strings.Replace(name, "-", "_", -1)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "\tcase \"$prev\" in\n")
+	values := completionValues()
+	for _, f := range sortedKeys(values) {
+		fmt.Fprintf(w, "\t-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f, strings.Join(values[f], " "))
+	}
+	fmt.Fprintf(w, "\t-%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(fileFlags, "|-"))
+	fmt.Fprintf(w, "\t-%s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", strings.Join(dirFlags, "|-"))
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tif [[ $cur != -* ]]; then\n")
+	fmt.Fprintf(w, "\t\tcase \"${COMP_WORDS[1]}\" in\n")
+	args := subcommandArgs()
+	for _, cmd := range sortedKeys(args) {
+		fmt.Fprintf(w, "\t\t%s) [[ $COMP_CWORD -eq 2 ]] && COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", cmd, strings.Join(args[cmd], " "))
+	}
+	fmt.Fprintf(w, "\t\tesac\n")
+	fmt.Fprintf(w, "\t\t[[ $COMP_CWORD -eq 1 ]] && COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(subcommandNames(), " "))
+	fmt.Fprintf(w, "\t\treturn\n")
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(flagNames(), " "))
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -F %s %s\n", fn, name)
+}
+
+func writeZshCompletion(w io.Writer, name string) {
+	fn := "_" + strings.Replace(name, "-", "_", -1)
+	fmt.Fprintf(w, "#compdef %s\n\n", name)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "\tcase $words[CURRENT-1] in\n")
+	values := completionValues()
+	for _, f := range sortedKeys(values) {
+		fmt.Fprintf(w, "\t-%s) compadd -- %s; return ;;\n", f, strings.Join(values[f], " "))
+	}
+	fmt.Fprintf(w, "\t-%s) _files; return ;;\n", strings.Join(fileFlags, "|-"))
+	fmt.Fprintf(w, "\t-%s) _files -/; return ;;\n", strings.Join(dirFlags, "|-"))
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tif [[ $words[CURRENT] != -* ]]; then\n")
+	fmt.Fprintf(w, "\t\tcase $words[2] in\n")
+	args := subcommandArgs()
+	for _, cmd := range sortedKeys(args) {
+		fmt.Fprintf(w, "\t\t%s) (( CURRENT == 3 )) && compadd -- %s; return ;;\n", cmd, strings.Join(args[cmd], " "))
+	}
+	fmt.Fprintf(w, "\t\tesac\n")
+	fmt.Fprintf(w, "\t\t(( CURRENT == 2 )) && compadd -- %s\n", strings.Join(subcommandNames(), " "))
+	fmt.Fprintf(w, "\t\treturn\n")
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "\tcompadd -- %s\n", strings.Join(flagNames(), " "))
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "compdef %s %s\n", fn, name)
+}
+
+func writeFishCompletion(w io.Writer, name string) {
+	values := completionValues()
+	fmt.Fprintf(w, "complete -c %s -f\n", name)
+	for _, cmd := range subcommandNames() {
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", name, cmd, fishQuote(subcommands[cmd].descr))
+	}
+	args := subcommandArgs()
+	for _, cmd := range sortedKeys(args) {
+		fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -a %s\n", name, cmd, fishQuote(strings.Join(args[cmd], " ")))
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		descr := strings.SplitN(f.Usage, "\n", 2)[0]
+		opt := fmt.Sprintf("complete -c %s -o %s -d %s", name, f.Name, fishQuote(descr))
+		switch {
+		case values[f.Name] != nil:
+			opt += " -x -a " + fishQuote(strings.Join(values[f.Name], " "))
+		case findIndex(f.Name, fileFlags) >= 0:
+			opt += " -r -F"
+		case findIndex(f.Name, dirFlags) >= 0:
+			opt += " -x -a '(__fish_complete_directories)'"
+		case !isBoolFlag(f):
+			opt += " -x"
+		}
+		fmt.Fprintln(w, opt)
+	})
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+var completionWriters = map[string]func(w io.Writer, name string){
+	"bash": writeBashCompletion,
+	"zsh":  writeZshCompletion,
+	"fish": writeFishCompletion,
+}
+
+func runCompletion(args []string) int {
+	fs := flag.NewFlagSet(path.Base(os.Args[0])+" completion", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s\n", path.Base(os.Args[0]), subcommands["completion"].usage)
+	}
+	parseFlags(fs, args)
+	if fs.NArg() != 1 || completionWriters[fs.Arg(0)] == nil {
+		fs.Usage()
+		return 3
+	}
+	completionWriters[fs.Arg(0)](os.Stdout, path.Base(os.Args[0]))
+	return 0
+}
@@ -0,0 +1,140 @@
+package main
+
+// Signed configuration files: with flag -config-sig <public key> the plugin
+// reads the config file (-config), -expect-file and -layout-file only if the
+// Ed25519 signature in <file>.sig is valid, so the configs distributed by a
+// central server can't be changed on a satellite poller unnoticed. A file
+// without or with an invalid signature is a config error, UNKNOWN.
+//
+// The keys are PEM files (PKCS #8 private key, PKIX public key), e.g. of
+// openssl, the signature is base64 and made by subcommand sign-config or
+// openssl:
+//
+//	openssl genpkey -algorithm ed25519 -out config-sign.key
+//	openssl pkey -in config-sign.key -pubout -out config-sign.pub
+//	check_cisco_ucs sign-config -key config-sign.key /etc/ucs-profiles.conf
+//	openssl pkeyutl -sign -inkey config-sign.key -rawin -in /etc/ucs-profiles.conf | base64 -w0 > /etc/ucs-profiles.conf.sig
+//
+//	check_cisco_ucs -config /etc/ucs-profiles.conf -config-sig /etc/nagios/config-sign.pub ...
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+var configSigKey string
+
+// readPEM returns the DER bytes of the first PEM block of the file
+func readPEM(filename string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM key found", filename)
+	}
+	return block.Bytes, nil
+}
+
+func loadPublicKey(filename string) (ed25519.PublicKey, error) {
+	der, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 public key", filename)
+	}
+	return pub, nil
+}
+
+func loadPrivateKey(filename string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", filename)
+	}
+	return priv, nil
+}
+
+// verifyConfig checks the signature <file>.sig of the content of a config file
+func verifyConfig(filename string, buf []byte) error {
+	pub, err := loadPublicKey(configSigKey)
+	if err != nil {
+		return fmt.Errorf("flag -config-sig: %v", err)
+	}
+	sig, err := ioutil.ReadFile(filename + ".sig")
+	if err != nil {
+		return fmt.Errorf("flag -config-sig: %s isn't signed: %v", filename, err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(pub, buf, raw) {
+		return fmt.Errorf("flag -config-sig: invalid signature %s.sig, %s was changed after signing or signed with another key", filename, filename)
+	}
+	return nil
+}
+
+// openConfigFile returns the content of a config file, with -config-sig only
+// if its signature is valid
+func openConfigFile(filename string) (io.Reader, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(configSigKey) > 0 {
+		if err := verifyConfig(filename, buf); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// signConfig writes the signature <file>.sig of a config file
+func signConfig(priv ed25519.PrivateKey, filename string) error {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, buf))
+	return writeFileAtomic(filename+".sig", []byte(sig+"\n"), 0644)
+}
+
+func runSignConfig(args []string) int {
+	fs := newFlagSet("sign-config")
+	keyFile := fs.String("key", "", "Ed25519 private key (PEM, PKCS #8) of the signatures")
+	files := parseArgs(fs, args)
+	if len(*keyFile) == 0 || len(files) == 0 {
+		fs.Usage()
+		return 3
+	}
+	priv, err := loadPrivateKey(*keyFile)
+	if err != nil {
+		exitUnknown("sign-config: " + err.Error())
+	}
+	for _, f := range files {
+		if err := signConfig(priv, f); err != nil {
+			exitUnknown("sign-config: " + err.Error())
+		}
+		fmt.Printf("%s.sig written\n", f)
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigSignature(t *testing.T) {
+	defer func(k string) { configSigKey = k }(configSigKey)
+	dir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	configSigKey = filepath.Join(dir, "config.pub")
+	ioutil.WriteFile(configSigKey, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+	config := filepath.Join(dir, "profiles.conf")
+	ioutil.WriteFile(config, []byte("[psu]\ncheck = psu\n"), 0644)
+
+	if _, err := openConfigFile(config); err == nil {
+		t.Error("unsigned config accepted")
+	}
+	if err := signConfig(priv, config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openConfigFile(config); err != nil {
+		t.Errorf("signed config: %v", err)
+	}
+	ioutil.WriteFile(config, []byte("[psu]\ncheck = psu\nz = true\n"), 0644)
+	if _, err := openConfigFile(config); err == nil {
+		t.Error("changed config accepted")
+	}
+}
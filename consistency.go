@@ -0,0 +1,71 @@
+package main
+
+// Consistency of flag -consistent <attributes>: the attributes of every
+// object are compared with the other objects of the same chassis, rack server
+// or fabric interconnect (the group of -imbalance), the added attribute
+// "consistent" is yes if the object has the values of the majority of its
+// group. Example: the model and firmware of the power supplies (template
+// psu-consistency), mixed power supplies are unsupported and cause spurious
+// redundancy faults.
+//
+//	-q equipmentPsu -a "dn model" -host-firmware -consistent "model fwVersion" -e ",yes$"
+//
+// Without a majority (e.g. two of four power supplies replaced) no object of
+// the group is consistent. Objects without values (e.g. a removed power
+// supply) are not compared and consistent.
+
+import "strings"
+
+// consistentName is the attribute added by -consistent
+const consistentName = "consistent"
+
+var consistentAttrs string
+
+// consistencyAttrs returns the attribute added by -consistent
+func consistencyAttrs() []string {
+	if len(consistentAttrs) == 0 {
+		return nil
+	}
+	return []string{consistentName}
+}
+
+// applyConsistency adds the consistency with the group to the objects
+func applyConsistency(objects []managedObject) {
+	if len(consistentAttrs) == 0 {
+		return
+	}
+	attrs := strings.Fields(consistentAttrs)
+	key := func(obj managedObject) string {
+		var values []string
+		for _, a := range attrs {
+			values = append(values, obj.Attrs[a])
+		}
+		return strings.Join(values, "\x00")
+	}
+	// number of objects per group and values
+	count := make(map[string]map[string]int)
+	size := make(map[string]int)
+	for _, obj := range objects {
+		if len(strings.Trim(key(obj), "\x00")) == 0 {
+			continue
+		}
+		g := imbalanceGroup(obj.Dn)
+		if count[g] == nil {
+			count[g] = make(map[string]int)
+		}
+		count[g][key(obj)]++
+		size[g]++
+	}
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), consistentName)
+		g := imbalanceGroup(obj.Dn)
+		n := count[g][key(*obj)]
+		if len(strings.Trim(key(*obj), "\x00")) == 0 || n*2 > size[g] {
+			obj.Attrs[consistentName] = "yes"
+			continue
+		}
+		debugPrintf(2, "consistency %s: %d of %d objects of %s with its %s\n", obj.Dn, n, size[g], g, consistentAttrs)
+		obj.Attrs[consistentName] = "no"
+	}
+}
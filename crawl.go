@@ -0,0 +1,140 @@
+package main
+
+// Split class queries, sent with at most -parallel requests at the same time.
+// The objects of the responses are merged into a single configResolveClass
+// response, so the evaluation is the same as for one query.
+//
+// -crawl chassis: a domain-wide class query is split into one hierarchical
+// configResolveDn per chassis. This is faster and gentler on UCS Manager than
+// one giant inHierarchical=true query. Objects outside of the chassis (fabric
+// interconnects, rack servers) are not found by the crawl.
+//
+// -chunk-by chassis|rack-unit: the class query is sent once per chassis or
+// rack server with a wcard filter on the dn of the chunk.
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// chunkClasses are the object classes of the chunks of flag -chunk-by
+var chunkClasses = map[string]string{
+	"chassis":   "equipmentChassis",
+	"rack-unit": "computeRackUnit",
+}
+
+// classDns returns the dn of all objects of a class, e.g. of all chassis
+func classDns(client *http.Client, url, cookie, classId string) ([]string, error) {
+	body, err := configRequest(client, url, &ucsxml.ConfigResolveClass{Cookie: cookie, InHierarchical: "false", ClassId: classId})
+	if err != nil {
+		return nil, err
+	}
+	objects, err := getXmlAttr(string(body), classId, nil)
+	if err != nil {
+		return nil, err
+	}
+	var dns []string
+	for _, obj := range objects {
+		dns = append(dns, obj.Dn)
+	}
+	return dns, nil
+}
+
+// outConfigContent returns the objects inside the outConfig or outConfigs
+// element of a response
+func outConfigContent(body []byte, wrapper string) []byte {
+	start := bytes.Index(body, []byte("<"+wrapper+">"))
+	end := bytes.LastIndex(body, []byte("</"+wrapper+">"))
+	if start < 0 || end < start {
+		return nil
+	}
+	return body[start+len(wrapper)+2 : end]
+}
+
+// queryChunks runs query for every chunk with at most -parallel requests at
+// the same time and merges the objects of the responses into a single
+// configResolveClass response of class. wrapper is the element around the
+// objects in the responses.
+func queryChunks(chunks []string, wrapper, cookie string, query func(chunk string) ([]byte, error)) ([]byte, error) {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(chunks))
+		subs = make([][]byte, len(chunks))
+		sem  = make(chan struct{}, parallel)
+	)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if skipChunk(chunk) {
+				return
+			}
+			body, err := query(chunk)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %v", chunk, err)
+				return
+			}
+			subs[i] = outConfigContent(body, wrapper)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	merged := bytes.NewBufferString(fmt.Sprintf(`<configResolveClass cookie="%s" response="yes" classId="%s"><outConfigs>`, cookie, class))
+	for i := range chunks {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		merged.Write(subs[i])
+	}
+	merged.WriteString("</outConfigs></configResolveClass>")
+	return merged.Bytes(), nil
+}
+
+// crawlChassis queries the subtree of every chassis and returns the merged
+// objects as configResolveClass response of class
+func crawlChassis(client *http.Client, url, cookie string) ([]byte, error) {
+	dns, err := classDns(client, url, cookie, "equipmentChassis")
+	if err != nil {
+		return nil, fmt.Errorf("chassis crawl: %v", err)
+	}
+	dns = rotationDns(dns)
+	debugPrintf(2, "chassis crawl: %d chassis, %d parallel\n", len(dns), parallel)
+
+	body, err := queryChunks(dns, "outConfig", cookie, func(chassis string) ([]byte, error) {
+		return configRequest(client, url, &ucsxml.ConfigResolveDn{Cookie: cookie, InHierarchical: "true", Dn: chassis})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chassis crawl %v", err)
+	}
+	return body, nil
+}
+
+// chunkClass splits the class query into one query per chassis or rack
+// server (flag -chunk-by), each filtered by the dn of the chunk, and returns
+// the merged objects. This bounds the size of the single responses, which
+// otherwise hit the response limits of UCS Manager on very large domains.
+func chunkClass(client *http.Client, url, cookie string) ([]byte, error) {
+	dns, err := classDns(client, url, cookie, chunkClasses[chunkBy])
+	if err != nil {
+		return nil, fmt.Errorf("chunk by %s: %v", chunkBy, err)
+	}
+	dns = rotationDns(dns)
+	debugPrintf(2, "chunk by %s: %d chunks, %d parallel\n", chunkBy, len(dns), parallel)
+
+	body, err := queryChunks(dns, "outConfigs", cookie, func(chunk string) ([]byte, error) {
+		req := &ucsxml.ConfigResolveClass{Cookie: cookie, InHierarchical: hierarchical, ClassId: class, InFilter: &ucsxml.InFilter{
+			Wcard: &ucsxml.Wcard{Class: class, Property: "dn", Value: "^" + chunk + "(/|$)"},
+		}}
+		return configRequest(client, url, req)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chunk by %s %v", chunkBy, err)
+	}
+	return body, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+// Credentials of the XML API or Redfish without -u and -p on the command
+// line, where ps and the Nagios object configuration show them. Precedence,
+// first wins:
+//
+//	1. flags -u and -p
+//	2. flags -ufile and -pfile, the first line of the file
+//	3. environment variables CHECK_UCS_USERNAME and CHECK_UCS_PASSWORD
+//
+// e.g. -u monitor -pfile /etc/nagios/ucs.secret (mode 0600, owned by the
+// nagios user). Flag -E masks CHECK_UCS_PASSWORD.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	usernameEnv = "CHECK_UCS_USERNAME"
+	passwordEnv = "CHECK_UCS_PASSWORD"
+)
+
+var usernameFile, passwordFile string
+
+// readSecret returns the first line of the file without the line break
+func readSecret(filename string) (string, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	line := strings.SplitN(string(buf), "\n", 2)[0]
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// credential returns the value of the flag, the file or the environment variable
+func credential(value, filename, env string) (string, error) {
+	if len(value) > 0 {
+		return value, nil
+	}
+	if len(filename) > 0 {
+		return readSecret(filename)
+	}
+	return os.Getenv(env), nil
+}
+
+// resolveCredentials sets -u and -p from -ufile, -pfile or the environment
+func resolveCredentials() error {
+	var err error
+	if username, err = credential(username, usernameFile, usernameEnv); err != nil {
+		return fmt.Errorf("flag -ufile: %v", err)
+	}
+	if password, err = credential(password, passwordFile, passwordEnv); err != nil {
+		return fmt.Errorf("flag -pfile: %v", err)
+	}
+	return nil
+}
+
+// maskedEnv returns the environment variable of -E, the password masked
+func maskedEnv(v string) string {
+	if strings.HasPrefix(v, passwordEnv+"=") {
+		return passwordEnv + "=********"
+	}
+	return v
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredential(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := ioutil.WriteFile(file, []byte("from file\r\nsecond line\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(passwordEnv, "from env")
+
+	tests := []struct {
+		value, filename, want string
+	}{
+		{"from flag", file, "from flag"},
+		{"", file, "from file"},
+		{"", "", "from env"},
+	}
+	for _, tt := range tests {
+		if got, err := credential(tt.value, tt.filename, passwordEnv); err != nil || got != tt.want {
+			t.Errorf("credential(%q, %q): got %q, %v, want %q", tt.value, tt.filename, got, err, tt.want)
+		}
+	}
+}
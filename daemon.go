@@ -0,0 +1,139 @@
+package main
+
+// Daemon mode of flag -daemon: a Prometheus exporter, the check of the flags
+// or the profiles of -config run every -interval, GET /metrics returns the
+// results of the last run in the format of -output prometheus (state of the
+// checks and objects, numeric attributes and performance data) and the
+// duration and success of the run. Same XML API client, templates and
+// profiles as the Nagios checks, no second tool.
+//
+//	check_cisco_ucs -daemon -listen :9710 -interval 60s -H ucs-a.example.com -u monitor -pfile /etc/ucs.secret -config /etc/ucs-profiles.conf
+//
+// A failed login or invalid profile makes the run UNKNOWN
+// (ucs_daemon_run_success 0), the daemon keeps running. Every run logs in and
+// out, -session-cache keeps the session.
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	daemonMode     bool
+	daemonListen   string
+	daemonInterval time.Duration
+)
+
+// daemonAbort is the panic of exitUnknown in daemon mode, it ends the run
+// instead of the process
+type daemonAbort struct {
+	msg string
+}
+
+// daemon serves the metrics of the last run
+type daemon struct {
+	mu      sync.Mutex
+	metrics []byte
+}
+
+// collect runs the checks once and returns their results, a run ended by
+// exitUnknown is an UNKNOWN result
+func collect() (results []*checkResult, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			abort, isAbort := r.(daemonAbort)
+			if !isAbort {
+				panic(r)
+			}
+			res := newResult().unknown(abort.msg)
+			res.Perfdata = errorPerfdata()
+			results, ok = []*checkResult{res}, false
+		}
+	}()
+	startDeadline()
+	startTimeout()
+	skippedChecks = nil
+	if len(configFile) > 0 {
+		results, err := profileResults()
+		if err != nil {
+			return []*checkResult{newResult().unknown("config error: " + err.Error())}, false
+		}
+		return results, true
+	}
+	if res := leaseHeld(hostName()); res != nil {
+		return []*checkResult{res}, true
+	}
+	return []*checkResult{runCheck()}, true
+}
+
+// run runs the checks and renders their metrics
+func (d *daemon) run() {
+	start := time.Now()
+	results, ok := collect()
+	var buf bytes.Buffer
+	prometheusRenderer{}.Render(&buf, results)
+	fmt.Fprintf(&buf, "# HELP ucs_daemon_run_success 1 if the last run logged in and ran the checks\n# TYPE ucs_daemon_run_success gauge\n")
+	fmt.Fprintf(&buf, "ucs_daemon_run_success{host=%q} %d\n", hostName(), boolInt(ok))
+	fmt.Fprintf(&buf, "# HELP ucs_daemon_run_duration_seconds duration of the last run\n# TYPE ucs_daemon_run_duration_seconds gauge\n")
+	fmt.Fprintf(&buf, "ucs_daemon_run_duration_seconds{host=%q} %.3f\n", hostName(), time.Since(start).Seconds())
+	fmt.Fprintf(&buf, "# HELP ucs_daemon_last_run_timestamp_seconds end of the last run\n# TYPE ucs_daemon_last_run_timestamp_seconds gauge\n")
+	fmt.Fprintf(&buf, "ucs_daemon_last_run_timestamp_seconds{host=%q} %d\n", hostName(), time.Now().Unix())
+	if !ok {
+		log.Printf("daemon: run failed: %s\n", results[0].Output)
+	}
+
+	d.mu.Lock()
+	d.metrics = buf.Bytes()
+	d.mu.Unlock()
+}
+
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+	d.mu.Lock()
+	metrics := d.metrics
+	d.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(metrics)
+}
+
+// validateDaemon checks the flags of -daemon
+func validateDaemon() error {
+	switch {
+	case isBatch():
+		return fmt.Errorf("flag -daemon can't be used with batch mode (-H %s)", ipAddr)
+	case daemonInterval < time.Second:
+		return fmt.Errorf("flag -interval must be at least 1s")
+	case len(configFile) == 0:
+		return validateCheckFlags()
+	}
+	return nil
+}
+
+// runDaemon runs the checks every -interval and serves their metrics until
+// the listener fails
+func runDaemon() int {
+	if err := validateDaemon(); err != nil {
+		fmt.Printf("UNKNOWN - %v\n", err)
+		return 3
+	}
+	d := &daemon{}
+	go func() {
+		d.run()
+		for range time.Tick(daemonInterval) {
+			d.run()
+		}
+	}()
+	log.Printf("daemon: listening on %s, interval %s\n", daemonListen, daemonInterval)
+	if err := http.ListenAndServe(daemonListen, d); err != nil {
+		fmt.Printf("UNKNOWN - daemon: %v\n", err)
+		return 3
+	}
+	return 0
+}
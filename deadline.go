@@ -0,0 +1,102 @@
+package main
+
+// Execution budget of flag -deadline <duration>: after the deadline no further
+// sub-checks are started, the profiles of -config, the targets of batch mode
+// and the chunks of -crawl and -chunk-by. The plugin reports the results it
+// has and lists the skipped sub-checks, instead of being killed by the global
+// timeout of Nagios (service_check_timeout, default 60s) without any output.
+// Requests in flight are completed, set the deadline a request time below the
+// timeout, e.g. -deadline 50s.
+//
+// A skipped profile or target is UNKNOWN, a check with skipped chunks is at
+// least UNKNOWN, its objects are incomplete. Batch mode passes the rest of the
+// budget to the checks of the targets.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	deadline   time.Duration
+	deadlineAt time.Time // zero: no deadline
+
+	skippedMu     sync.Mutex
+	skippedChecks []string // profiles or targets not started after the deadline
+	skippedChunks []string // chunks of the current check
+)
+
+// startDeadline starts the execution budget of -deadline
+func startDeadline() {
+	if deadline > 0 {
+		deadlineAt = time.Now().Add(deadline)
+	}
+}
+
+// pastDeadline returns true after the deadline
+func pastDeadline() bool {
+	return !deadlineAt.IsZero() && !time.Now().Before(deadlineAt)
+}
+
+// skipCheck returns true if the profile or target may not be started anymore
+func skipCheck(name string) bool {
+	if !pastDeadline() {
+		return false
+	}
+	debugPrintf(1, "deadline %s reached, %s skipped\n", deadline, name)
+	skippedChecks = append(skippedChecks, name)
+	return true
+}
+
+// skipChunk returns true if the chunk may not be queried anymore, called concurrently
+func skipChunk(chunk string) bool {
+	if !pastDeadline() {
+		return false
+	}
+	debugPrintf(1, "deadline %s reached, chunk %s skipped\n", deadline, chunk)
+	skippedMu.Lock()
+	skippedChunks = append(skippedChunks, chunk)
+	skippedMu.Unlock()
+	return true
+}
+
+// remainingBudget returns the rest of the budget for the -deadline of a target
+func remainingBudget() time.Duration {
+	if d := time.Until(deadlineAt); d > time.Millisecond {
+		return d.Round(time.Millisecond)
+	}
+	return time.Millisecond
+}
+
+// skippedResult returns the result of a profile or target skipped after the deadline
+func skippedResult() *checkResult {
+	res := newResult()
+	res.Output = fmt.Sprintf("SKIPPED - deadline %s reached, check not run", deadline)
+	return res
+}
+
+// incompleteResult makes the result of a check with skipped chunks at least
+// UNKNOWN and lists the chunks
+func incompleteResult(res *checkResult) {
+	skippedMu.Lock()
+	defer skippedMu.Unlock()
+	if len(skippedChunks) == 0 {
+		return
+	}
+	sort.Strings(skippedChunks)
+	res.State = worstState(res.State, 3)
+	res.Status = statePrefix[res.State]
+	res.Output += fmt.Sprintf("\ndeadline %s reached, objects incomplete, skipped: %s", deadline, strings.Join(skippedChunks, ", "))
+	skippedChunks = nil
+}
+
+// skippedNote returns the long output line of the skipped profiles or targets
+func skippedNote() string {
+	if len(skippedChecks) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\ndeadline %s reached, skipped: %s", deadline, strings.Join(skippedChecks, ", "))
+}
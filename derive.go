@@ -0,0 +1,258 @@
+package main
+
+// Derived attributes of flag -derive, computed per object from numeric
+// attributes, e.g. -derive "efficiency=outputPower/inputPower*100". The
+// expression knows + - * /, parentheses, numbers and attribute names. A derived
+// attribute is shown after the attributes of -a and can be used in thresholds
+// (-w, -c) and perfdata (-perfdata) like a queried attribute. If an input is
+// missing or not a number, or on division by zero, the attribute is missing.
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type derivedAttr struct {
+	name string
+	expr string
+	root exprNode
+}
+
+// derivedList collects the values of the repeatable flag -derive
+type derivedList []derivedAttr
+
+var derived derivedList
+
+func (l *derivedList) String() string {
+	var s []string
+	for _, d := range *l {
+		s = append(s, d.name+"="+d.expr)
+	}
+	return strings.Join(s, ";")
+}
+
+// Set adds derived attributes <name>=<expression>, several separated by semicolon
+func (l *derivedList) Set(value string) error {
+	for _, s := range strings.Split(value, ";") {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return fmt.Errorf("expected <name>=<expression>")
+		}
+		root, err := parseExpr(kv[1])
+		if err != nil {
+			return fmt.Errorf("derive %s: %v", s, err)
+		}
+		*l = append(*l, derivedAttr{name: strings.TrimSpace(kv[0]), expr: strings.TrimSpace(kv[1]), root: root})
+	}
+	return nil
+}
+
+func (l *derivedList) reset() {
+	*l = nil
+}
+
+// names returns the names of the derived attributes
+func (l derivedList) names() []string {
+	var names []string
+	for _, d := range l {
+		names = append(names, d.name)
+	}
+	return names
+}
+
+// inputs returns the attributes used by the expressions
+func (l derivedList) inputs() []string {
+	var attrs []string
+	for _, d := range l {
+		for _, a := range d.root.attrs() {
+			if findIndex(a, attrs) < 0 && findIndex(a, l.names()) < 0 {
+				attrs = append(attrs, a)
+			}
+		}
+	}
+	return attrs
+}
+
+// apply adds the derived attributes to the object, a derived attribute can
+// use the derived attributes before it
+func (l derivedList) apply(obj *managedObject) {
+	for _, d := range l {
+		v, err := d.root.eval(obj.Attrs)
+		if err == nil && (math.IsInf(v, 0) || math.IsNaN(v)) {
+			err = fmt.Errorf("result %v", v)
+		}
+		if err != nil {
+			debugPrintf(2, "derive %s of %s: %v\n", d.name, obj.Dn, err)
+			continue
+		}
+		obj.Attrs[d.name] = strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+	}
+	obj.Keys = append(append([]string{}, obj.Keys...), l.names()...)
+}
+
+type exprNode interface {
+	eval(attrs map[string]string) (float64, error)
+	attrs() []string
+}
+
+type (
+	numberNode float64
+	attrNode   string
+	binaryNode struct {
+		op          byte
+		left, right exprNode
+	}
+	negNode struct {
+		x exprNode
+	}
+)
+
+func (n numberNode) eval(map[string]string) (float64, error) { return float64(n), nil }
+func (n numberNode) attrs() []string                         { return nil }
+
+func (n attrNode) eval(attrs map[string]string) (float64, error) {
+	s, ok := attrs[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("attribute %s missing", n)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("attribute %s: %q is not a number", n, s)
+	}
+	return v, nil
+}
+func (n attrNode) attrs() []string { return []string{string(n)} }
+
+func (n negNode) eval(attrs map[string]string) (float64, error) {
+	v, err := n.x.eval(attrs)
+	return -v, err
+}
+func (n negNode) attrs() []string { return n.x.attrs() }
+
+func (n binaryNode) eval(attrs map[string]string) (float64, error) {
+	l, err := n.left.eval(attrs)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.eval(attrs)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	}
+	if r == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return l / r, nil
+}
+func (n binaryNode) attrs() []string { return append(n.left.attrs(), n.right.attrs()...) }
+
+// exprParser is a recursive descent parser:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | attribute | "(" expr ")" | "-" factor
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func parseExpr(s string) (exprNode, error) {
+	p := &exprParser{s: s}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skip(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.s[p.pos:], p.pos+1)
+	}
+	return n, nil
+}
+
+func (p *exprParser) skip() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// next returns the next operator character or 0
+func (p *exprParser) next(ops string) byte {
+	p.skip()
+	if p.pos < len(p.s) && strings.IndexByte(ops, p.s[p.pos]) >= 0 {
+		p.pos++
+		return p.s[p.pos-1]
+	}
+	return 0
+}
+
+func (p *exprParser) expr() (exprNode, error) {
+	left, err := p.term()
+	for err == nil {
+		op := p.next("+-")
+		if op == 0 {
+			return left, nil
+		}
+		var right exprNode
+		right, err = p.term()
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return nil, err
+}
+
+func (p *exprParser) term() (exprNode, error) {
+	left, err := p.factor()
+	for err == nil {
+		op := p.next("*/")
+		if op == 0 {
+			return left, nil
+		}
+		var right exprNode
+		right, err = p.factor()
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return nil, err
+}
+
+func (p *exprParser) factor() (exprNode, error) {
+	if p.next("-") != 0 {
+		x, err := p.factor()
+		return negNode{x: x}, err
+	}
+	if p.next("(") != 0 {
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next(")") == 0 {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		return n, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && (unicode.IsLetter(rune(p.s[p.pos])) || unicode.IsDigit(rune(p.s[p.pos])) || strings.IndexByte("._", p.s[p.pos]) >= 0) {
+		p.pos++
+	}
+	token := p.s[start:p.pos]
+	if len(token) == 0 {
+		if p.pos < len(p.s) {
+			return nil, fmt.Errorf("unexpected %q at position %d", p.s[p.pos:], p.pos+1)
+		}
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if v, err := strconv.ParseFloat(token, 64); err == nil {
+		return numberNode(v), nil
+	}
+	if unicode.IsDigit(rune(token[0])) || token[0] == '.' {
+		return nil, fmt.Errorf("invalid number %q", token)
+	}
+	return attrNode(token), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+// Plugin output dialect of flag -dialect, chosen once in the command
+// definition of the monitoring core. Without -dialect the perfdata follows
+// the last line of the output, which all of them parse.
+//
+//	nagios	perfdata on the status line, the long output follows, at most 8 KB
+//		(MAX_PLUGIN_OUTPUT_LENGTH of Nagios Core 4), the long output is
+//		truncated at a line end so the status line and perfdata are kept
+//	naemon	perfdata on the status line, the long output follows, no limit
+//	icinga2	the long output follows the status line, the perfdata is on a
+//		line of its own after it (Icinga Web shows it apart from the output)
+//
+// A pipe in the output would start the perfdata, it is replaced by a slash in
+// all dialects.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dialectLimits are the maximum output lengths of the dialects, 0: no limit
+var dialectLimits = map[string]int{"nagios": 8192, "naemon": 0, "icinga2": 0}
+
+var dialect string
+
+// validateDialect checks flag -dialect
+func validateDialect() error {
+	if _, ok := dialectLimits[dialect]; !ok && len(dialect) > 0 {
+		return fmt.Errorf("unknown dialect %q, expected nagios, naemon or icinga2", dialect)
+	}
+	return nil
+}
+
+// pluginOutput returns the plugin output of the text (status line and long
+// output) and the perfdata in the format of -dialect
+func pluginOutput(text string, perf []perfValue) string {
+	if len(dialect) > 0 {
+		text = strings.Replace(text, "|", "/", -1)
+	}
+	perfdata := perfdataString(perf)
+	switch dialect {
+	case "":
+		return text + perfdata + "\n"
+	case "icinga2":
+		if len(perfdata) > 0 {
+			perfdata = "\n|" + strings.TrimPrefix(perfdata, " |")
+		}
+		return text + perfdata + "\n"
+	}
+
+	lines := strings.Split(text, "\n")
+	first := lines[0] + perfdata
+	long := lines[1:]
+	if limit := dialectLimits[dialect]; limit > 0 {
+		size := len(first) + 1
+		for i, l := range long {
+			if size+len(l)+1 > limit-64 {
+				// room for the note
+				long = append(long[:i], fmt.Sprintf("... %d lines truncated (%s output limit %d bytes)", len(long)-i, dialect, limit))
+				break
+			}
+			size += len(l) + 1
+		}
+	}
+	return strings.Join(append([]string{first}, long...), "\n") + "\n"
+}
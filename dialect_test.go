@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPluginOutput(t *testing.T) {
+	defer func() { dialect = "" }()
+	perf := []perfValue{{Label: "psu-1:outputPower", Value: "420"}}
+	text := "OK - Cisco UCS equipmentPsuStats (dn,outputPower)\nsys/chassis-1/psu-1,420|x (1 of 1 ok)"
+	tests := []struct {
+		dialect, want string
+	}{
+		{"", text + " | 'psu-1:outputPower'=420\n"},
+		{"nagios", "OK - Cisco UCS equipmentPsuStats (dn,outputPower) | 'psu-1:outputPower'=420\nsys/chassis-1/psu-1,420/x (1 of 1 ok)\n"},
+		{"icinga2", "OK - Cisco UCS equipmentPsuStats (dn,outputPower)\nsys/chassis-1/psu-1,420/x (1 of 1 ok)\n| 'psu-1:outputPower'=420\n"},
+	}
+	for _, tt := range tests {
+		dialect = tt.dialect
+		if got := pluginOutput(text, perf); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+
+	// nagios: the long output is truncated, the status line and perfdata are kept
+	dialect = "nagios"
+	long := "CRIT - Cisco UCS faultInst" + strings.Repeat("\nF0181,major,no,local disk inoperable", 500)
+	got := pluginOutput(long, perf)
+	if len(got) > 8192 || !strings.HasPrefix(got, "CRIT - Cisco UCS faultInst | 'psu-1:outputPower'=420\n") || !strings.Contains(got, "lines truncated") {
+		t.Errorf("%d bytes: %q", len(got), got[:100])
+	}
+}
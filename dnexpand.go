@@ -0,0 +1,150 @@
+package main
+
+// Brace expansion of dn queries: -t dn -q "sys/chassis-{1..8}/psu-{1..4}"
+// expands to the 32 dns sys/chassis-1/psu-1 ... sys/chassis-8/psu-4, read
+// with one configResolveDns request. Braces hold a numeric range {1..8} or a
+// comma separated list {a,b}, several braces are combined. Dns not found (e.g.
+// chassis 6 to 8 of a domain with 5 chassis) are listed in the long output as
+// unresolved and don't change the state. XML API only.
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// maxExpandedDns limits the dns of one expanded query
+const maxExpandedDns = 1000
+
+type (
+	ConfigResolveDns struct {
+		XMLName        struct{} `xml:"configResolveDns"`
+		Cookie         string   `xml:"cookie,attr"`
+		InHierarchical string   `xml:"inHierarchical,attr"`
+		InDns          []dnRef  `xml:"inDns>dn"`
+	}
+
+	dnRef struct {
+		Value string `xml:"value,attr"`
+	}
+
+	configResolveDnsResp struct {
+		OutUnresolved []dnRef `xml:"outUnresolved>dn"`
+	}
+)
+
+// unresolvedDns are the dns of the last configResolveDns request not found
+var unresolvedDns []string
+
+// isDnPattern is true if a dn query has to be expanded
+func isDnPattern(s string) bool {
+	return strings.ContainsAny(s, "{}")
+}
+
+// expandDn returns the dns of a pattern with braces
+func expandDn(s string) ([]string, error) {
+	open := strings.IndexByte(s, '{')
+	if open < 0 {
+		if strings.IndexByte(s, '}') >= 0 {
+			return nil, fmt.Errorf("dn %q: } without {", s)
+		}
+		return []string{s}, nil
+	}
+	end := strings.IndexByte(s[open:], '}')
+	if end < 0 {
+		return nil, fmt.Errorf("dn %q: { without }", s)
+	}
+	end += open
+	alternatives, err := braceAlternatives(s[open+1 : end])
+	if err != nil {
+		return nil, fmt.Errorf("dn %q: %v", s, err)
+	}
+	rest, err := expandDn(s[end+1:])
+	if err != nil {
+		return nil, err
+	}
+	var dns []string
+	for _, a := range alternatives {
+		for _, r := range rest {
+			if len(dns) >= maxExpandedDns {
+				return nil, fmt.Errorf("dn %q expands to more than %d dns", s, maxExpandedDns)
+			}
+			dns = append(dns, s[:open]+a+r)
+		}
+	}
+	return dns, nil
+}
+
+// braceAlternatives returns the values of a range a..b or a list a,b,c
+func braceAlternatives(s string) ([]string, error) {
+	if strings.ContainsRune(s, '{') {
+		return nil, fmt.Errorf("nested braces are not supported")
+	}
+	if r := strings.SplitN(s, "..", 2); len(r) == 2 {
+		from, err1 := strconv.Atoi(r[0])
+		to, err2 := strconv.Atoi(r[1])
+		if err1 != nil || err2 != nil || from > to {
+			return nil, fmt.Errorf("invalid range {%s}, example: {1..8}", s)
+		}
+		if to-from >= maxExpandedDns {
+			return nil, fmt.Errorf("range {%s} has more than %d values", s, maxExpandedDns)
+		}
+		var values []string
+		for i := from; i <= to; i++ {
+			values = append(values, strconv.Itoa(i))
+		}
+		return values, nil
+	}
+	values := strings.Split(s, ",")
+	for _, v := range values {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("empty value in {%s}", s)
+		}
+	}
+	return values, nil
+}
+
+// resolveDns sends configResolveDns for the expanded dn pattern
+func (b *xmlBackend) resolveDns() ([]byte, error) {
+	dns, err := expandDn(dn)
+	if err != nil {
+		return nil, err
+	}
+	req := &ConfigResolveDns{Cookie: b.cookie, InHierarchical: hierarchical}
+	for _, d := range dns {
+		req.InDns = append(req.InDns, dnRef{Value: d})
+	}
+	buf, err := ucsxml.BuildRequest(req)
+	if err != nil {
+		log.Printf("configResolveDns marshal error: %s\n", err)
+	}
+	debugPrintf(3, "configResolveDns request: %s\n", buf)
+	resp, err := b.client.Post(b.url, "text/xml", bytes.NewBuffer(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readBody(resp)
+	debugPrintf(2, "configResolveDns respons: %s\n", body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decodeEnvelope(body, "configResolveDns"); err != nil {
+		return nil, err
+	}
+
+	unresolved := &configResolveDnsResp{}
+	if err := ucsxml.NewDecoder(body).Decode(unresolved); err != nil {
+		return nil, fmt.Errorf("configResolveDns: %v", err)
+	}
+	unresolvedDns = nil
+	for _, d := range unresolved.OutUnresolved {
+		unresolvedDns = append(unresolvedDns, d.Value)
+	}
+	debugPrintf(2, "%d dns, %d unresolved\n", len(dns), len(unresolvedDns))
+	return body, nil
+}
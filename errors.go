@@ -0,0 +1,68 @@
+package main
+
+// Error counters in the perfdata: every result reports the errors of its
+// requests by class, also if the check recovered from them (e.g. a cached
+// session rejected with 552 and a new login, an unreachable address of -H
+// and the failover to the next one). Long-term graphs of the counters reveal
+// an intermittent instability of the API per UCS domain, which the states
+// hide once the retries of the monitoring core end in OK.
+//
+//	errors_auth	login refused, session expired or invalid (XML API 551, 552,
+//			553, 572) or HTTP 401/403 of Redfish
+//	errors_net	connection failed or timed out, response truncated
+//	errors_api	other errors reported by the API, e.g. an unknown class
+//	errors_parse	response not parsable (XML or JSON)
+//
+// The counters are always reported, 0 without errors. In profile mode every
+// profile reports the errors of its own requests.
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// error classes of the counters
+const (
+	errAuth  = ucsxml.ErrAuth
+	errNet   = ucsxml.ErrNet
+	errApi   = ucsxml.ErrAPI
+	errParse = ucsxml.ErrParse
+)
+
+var errorClasses = []string{errAuth, errNet, errApi, errParse}
+
+var (
+	errorMu     sync.Mutex
+	errorCounts = make(map[string]int)
+)
+
+// countError counts an error of the class, called concurrently by the chunks
+func countError(class string) {
+	errorMu.Lock()
+	errorCounts[class]++
+	errorMu.Unlock()
+	debugPrintf(2, "%s error counted\n", class)
+}
+
+// countAPIError counts an error of the XML API client, the errors of the
+// HTTP client are counted by headerTransport
+func countAPIError(err error) {
+	if class := ucsxml.ErrorClass(err); len(class) > 0 {
+		countError(class)
+	}
+}
+
+// errorPerfdata returns the counters of all classes and resets them for the
+// next check
+func errorPerfdata() []perfValue {
+	errorMu.Lock()
+	defer errorMu.Unlock()
+	var perf []perfValue
+	for _, class := range errorClasses {
+		perf = append(perf, perfValue{Label: "errors_" + class, Value: strconv.Itoa(errorCounts[class])})
+	}
+	errorCounts = make(map[string]int)
+	return perf
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestErrorPerfdata(t *testing.T) {
+	errorPerfdata()
+	decodeEnvelope(readTestdata(t, "ucsm-4.1-configResolveClass-552.xml"), "configResolveClass")
+	decodeEnvelope(readTestdata(t, "ucsm-4.1-aaaLogin-551.xml"), "aaaLogin")
+	decodeEnvelope(readTestdata(t, "ucsm-4.1-error-xml-parse.xml"), "configResolveClass")
+	decodeEnvelope([]byte("<configResolveClass"), "configResolveClass")
+	decodeEnvelope(readTestdata(t, "ucsm-4.1-configResolveClass-faultInst.xml"), "configResolveClass")
+
+	want := map[string]string{"errors_auth": "2", "errors_net": "0", "errors_api": "1", "errors_parse": "1"}
+	perf := errorPerfdata()
+	if len(perf) != len(want) {
+		t.Fatalf("got %d counters, want %d", len(perf), len(want))
+	}
+	for _, p := range perf {
+		if p.Value != want[p.Label] {
+			t.Errorf("%s = %s, want %s", p.Label, p.Value, want[p.Label])
+		}
+	}
+	// reset for the next check
+	for _, p := range errorPerfdata() {
+		if p.Value != "0" {
+			t.Errorf("%s = %s after reset", p.Label, p.Value)
+		}
+	}
+}
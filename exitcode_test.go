@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// fakeBackend returns the objects of a configResolveClass response body
+// like the XML API backend, or an error
+type fakeBackend struct {
+	body string
+	err  error
+}
+
+func (f *fakeBackend) Open() error { return nil }
+func (f *fakeBackend) Close()      {}
+
+func (f *fakeBackend) Objects() ([]managedObject, []byte, error) {
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	objects, err := getXmlAttr(f.body, class, strings.Split(attributes, " "))
+	return objects, []byte(f.body), err
+}
+
+func psuResponse(states ...string) string {
+	var psus strings.Builder
+	for i, s := range states {
+		fmt.Fprintf(&psus, `<equipmentPsu dn="sys/chassis-1/psu-%d" operState="%s"/>`, i+1, s)
+	}
+	return `<configResolveClass cookie="1/abc" response="yes" classId="equipmentPsu"><outConfigs>` + psus.String() + `</outConfigs></configResolveClass>`
+}
+
+// setCheckFlags sets the flags of a psu check and restores them after the test
+func setCheckFlags(t *testing.T, zero, faults bool, filter string) {
+	t.Helper()
+	saved := []interface{}{queryType, dnOrClass, class, attributes, expectString, zeroInst, faultsOnly, propertyFilter}
+	t.Cleanup(func() {
+		queryType, dnOrClass, class, attributes = saved[0].(string), saved[1].(string), saved[2].(string), saved[3].(string)
+		expectString, zeroInst, faultsOnly, propertyFilter = saved[4].(string), saved[5].(bool), saved[6].(bool), saved[7].(string)
+	})
+	queryType, dnOrClass, class = "class", "equipmentPsu", ""
+	attributes, expectString = "dn operState", ",operable$"
+	zeroInst, faultsOnly, propertyFilter = zero, faults, filter
+}
+
+// TestCheckExitCodes locks down the exit code of every combination of -z,
+// -F, -f, expect match or mismatch and error conditions
+func TestCheckExitCodes(t *testing.T) {
+	responses := []struct {
+		name     string
+		backend  *fakeBackend
+		want     int // without -z
+		wantZero int // with -z
+	}{
+		{"all match", &fakeBackend{body: psuResponse("operable", "operable")}, 0, 0},
+		{"one mismatch", &fakeBackend{body: psuResponse("operable", "inoperable")}, 2, 2},
+		{"all mismatch", &fakeBackend{body: psuResponse("inoperable", "removed")}, 2, 2},
+		{"substring is no match", &fakeBackend{body: psuResponse("inoperable")}, 2, 2},
+		{"no objects", &fakeBackend{body: psuResponse()}, 2, 0},
+		{"empty response", &fakeBackend{body: ""}, 2, 0},
+		{"api error", &fakeBackend{err: &ucsxml.APIError{Method: "configResolveClass", Code: "552", Descr: "Authorization required"}}, 3, 3},
+		{"connection error", &fakeBackend{err: fmt.Errorf("connection refused")}, 3, 3},
+		{"corrupt response", &fakeBackend{body: `<configResolveClass><outConfigs><equipmentPsu dn="sys/chassis-1/psu-1" operState=operable/>`}, 3, 3},
+	}
+	for _, resp := range responses {
+		for _, zero := range []bool{false, true} {
+			for _, faults := range []bool{false, true} {
+				for _, filter := range []string{"", "wcard:dn:^sys/chassis-1/"} {
+					name := fmt.Sprintf("%s/z=%v/F=%v/f=%q", resp.name, zero, faults, filter)
+					t.Run(name, func(t *testing.T) {
+						setCheckFlags(t, zero, faults, filter)
+						want := resp.want
+						if zero {
+							want = resp.wantZero
+						}
+						res := check(resp.backend)
+						if res.State != want {
+							t.Errorf("state %d, want %d, output: %s", res.State, want, res.Output)
+						}
+						if res.Status != statePrefix[want] {
+							t.Errorf("status %s, want %s", res.Status, statePrefix[want])
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestCheckFaultsOnlyOutput checks that -F lists only the objects not
+// matching the expect string without changing the summary
+func TestCheckFaultsOnlyOutput(t *testing.T) {
+	for _, faults := range []bool{false, true} {
+		setCheckFlags(t, false, faults, "")
+		res := check(&fakeBackend{body: psuResponse("operable", "inoperable")})
+		if !strings.Contains(res.Output, "psu-2,inoperable") {
+			t.Errorf("F=%v: fault missing in output: %s", faults, res.Output)
+		}
+		if got := strings.Contains(res.Output, "psu-1,operable"); got == faults {
+			t.Errorf("F=%v: ok object listed %v, output: %s", faults, got, res.Output)
+		}
+		if !strings.Contains(res.Output, "(1 of 2 ok)") {
+			t.Errorf("F=%v: summary missing: %s", faults, res.Output)
+		}
+	}
+}
+
+func TestOutputArg(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-w", "foo", "-output", "json"}, "json"},
+		{[]string{"--output=perfdata", "-w", "foo"}, "perfdata"},
+		{[]string{"-w", "foo"}, ""},
+		{[]string{"-w", "foo", "--", "-output", "json"}, ""},
+		{[]string{"-w", "foo", "-output"}, ""},
+	} {
+		if got := outputArg(tc.args); got != tc.want {
+			t.Errorf("outputArg(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+// Count thresholds of flags -warn-failed and -crit-failed on the objects
+// failing the expect string: WARN while 1 up to -warn-failed objects fail,
+// CRIT above. -crit-failed alone is WARN up to -crit-failed failed objects,
+// CRIT above. With both flags -warn-failed must not be above -crit-failed and
+// the objects between them are CRIT as well, the first object above
+// -warn-failed is CRIT. Without the flags (both 0) the first failed object is
+// CRIT. In a C240 with 60 disks a single disk
+// with predictive failure is WARN, two are CRIT:
+//
+//	check_cisco_ucs -check disks -warn-failed 1
+//
+// The number of failed objects is reported in the summary. The objects of
+// -crit-sev and -warn-sev and the thresholds of -w and -c are evaluated on
+// top, -require is the quorum of the objects ok instead.
+
+import "fmt"
+
+var (
+	warnFailed int
+	critFailed int
+)
+
+// validateFailedCounts checks the flags -warn-failed and -crit-failed
+func validateFailedCounts() error {
+	switch {
+	case warnFailed < 0 || critFailed < 0:
+		return fmt.Errorf("flags -warn-failed and -crit-failed must not be negative")
+	case warnFailed > 0 && critFailed > 0 && warnFailed > critFailed:
+		return fmt.Errorf("flag -warn-failed %d is above -crit-failed %d", warnFailed, critFailed)
+	case failedCounts() && len(requireQuorum) > 0:
+		return fmt.Errorf("flags -warn-failed and -crit-failed can't be combined with the quorum -require")
+	}
+	return nil
+}
+
+// failedCounts is true with -warn-failed or -crit-failed
+func failedCounts() bool {
+	return warnFailed > 0 || critFailed > 0
+}
+
+// failedLimit returns the number of failed objects still WARN, -warn-failed
+// or -crit-failed alone
+func failedLimit() int {
+	if warnFailed > 0 {
+		return warnFailed
+	}
+	return critFailed
+}
+
+// failedState returns the state of the number of objects failing the
+// expect string
+func failedState(failed int) int {
+	switch {
+	case failed > failedLimit():
+		return 2
+	case failed > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFailedCounts(t *testing.T) {
+	defer func(w, c int) { warnFailed, critFailed = w, c }(warnFailed, critFailed)
+	for _, tt := range []struct {
+		warn, crit int
+		states     []string
+		want       int
+	}{
+		{0, 0, []string{"operable", "inoperable"}, 2},
+		{0, 1, []string{"operable", "operable", "inoperable"}, 1},
+		{0, 1, []string{"operable", "inoperable", "removed"}, 2},
+		{1, 2, []string{"operable", "inoperable"}, 1},
+		{1, 2, []string{"operable", "inoperable", "removed"}, 2},
+		{1, 0, []string{"operable", "operable", "inoperable"}, 1}, // -warn-failed alone
+		{1, 0, []string{"operable", "inoperable", "removed"}, 2},
+		{1, 5, []string{"operable", "inoperable", "removed", "removed"}, 2}, // between -warn-failed and -crit-failed
+		{0, 5, []string{"operable", "inoperable", "removed", "removed"}, 1},
+		{1, 5, []string{"operable", "removed", "removed", "removed", "removed", "removed", "removed"}, 2},
+		{0, 1, []string{"operable", "operable"}, 0},
+		{0, 1, nil, 2}, // no objects
+	} {
+		setCheckFlags(t, false, false, "")
+		warnFailed, critFailed = tt.warn, tt.crit
+		if err := validateFailedCounts(); err != nil {
+			t.Fatal(err)
+		}
+		res := check(&fakeBackend{body: psuResponse(tt.states...)})
+		if res.State != tt.want {
+			t.Errorf("-warn-failed %d -crit-failed %d %v: state %d, want %d, output: %s", tt.warn, tt.crit, tt.states, res.State, tt.want, res.Output)
+		}
+		if (tt.warn > 0 || tt.crit > 0) && len(tt.states) > 0 && !strings.Contains(res.Output, "failed, WARN up to") {
+			t.Errorf("summary without the failed objects: %s", res.Output)
+		}
+	}
+}
+
+func TestValidateFailedCounts(t *testing.T) {
+	defer func(w, c int, q string) { warnFailed, critFailed, requireQuorum = w, c, q }(warnFailed, critFailed, requireQuorum)
+	for _, tt := range []struct {
+		warn, crit int
+		quorum     string
+		ok         bool
+	}{
+		{0, 0, "", true},
+		{0, 0, "2 of 4", true},
+		{1, 3, "", true},
+		{2, 1, "", false},
+		{2, 0, "", true},
+		{-1, 0, "", false},
+		{0, 1, "2 of 4", false},
+		{1, 0, "2 of 4", false},
+	} {
+		warnFailed, critFailed, requireQuorum = tt.warn, tt.crit, tt.quorum
+		if err := validateFailedCounts(); (err == nil) != tt.ok {
+			t.Errorf("-warn-failed %d -crit-failed %d -require %q: %v", tt.warn, tt.crit, tt.quorum, err)
+		}
+	}
+}
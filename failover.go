@@ -0,0 +1,99 @@
+package main
+
+// Address failover of flag -H: several comma separated addresses of the same
+// system, e.g. the OOB and the inband management address of a CIMC or its
+// IPv4 and IPv6 address, are tried in order. The first address accepting a
+// TCP connection is used and reported in the long output with the unreachable
+// addresses before it. The state file, the labels and the shared query cache
+// use the first address, so a failover doesn't change the identity of the check.
+//
+//	-H 10.10.1.7,10.20.1.7
+//	-H 10.10.1.7,[2001:db8::7]
+//
+// Flag -fallback-fi: the management addresses of the fabric interconnects of
+// a UCS Manager domain, tried if the cluster VIP (-H) is unreachable, e.g.
+// during an election. The long output reports the unreachable VIP. Only the
+// primary fabric interconnect runs UCS Manager, the subordinate one refuses
+// the login, so list the usual primary first.
+//
+//	-H 10.10.1.5 -fallback-fi 10.10.1.6,10.10.1.7
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// failoverTimeout is the time to wait for the TCP connection to an address
+const failoverTimeout = 3 * time.Second
+
+var (
+	fallbackFi  string
+	hostAddrs   []string // addresses of -H, empty with a single address without -fallback-fi
+	addressNote string   // long output line of the failover
+)
+
+// hostName returns the first address of -H, the identity of the checked system
+func hostName() string {
+	if len(hostAddrs) > 0 {
+		return hostAddrs[0]
+	}
+	return ipAddr
+}
+
+// dialAddress returns host:port of an address, the port defaults to 443
+func dialAddress(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), "443")
+}
+
+func splitAddrs(s string) []string {
+	var addrs []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); len(a) > 0 {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// selectAddress sets ipAddr to the first reachable address of -H or
+// -fallback-fi. If none is reachable the first one is used and the login
+// reports the error.
+func selectAddress() {
+	if !strings.Contains(ipAddr, ",") && len(fallbackFi) == 0 || len(hostAddrs) > 0 {
+		return
+	}
+	hostAddrs = splitAddrs(ipAddr)
+	if len(hostAddrs) == 0 {
+		return
+	}
+	ipAddr = hostAddrs[0]
+	fis := splitAddrs(fallbackFi)
+
+	var unreachable []string
+	for i, a := range append(append([]string{}, hostAddrs...), fis...) {
+		conn, err := net.DialTimeout("tcp", dialAddress(a), failoverTimeout)
+		if err != nil {
+			debugPrintf(1, "address %s unreachable: %v\n", a, err)
+			countError(errNet)
+			unreachable = append(unreachable, a+" ("+err.Error()+")")
+			continue
+		}
+		conn.Close()
+		ipAddr = a
+		switch {
+		case i >= len(hostAddrs):
+			addressNote = "\ncluster VIP unreachable, address: " + a + " (fabric interconnect)"
+		case len(hostAddrs) > 1:
+			addressNote = "\naddress: " + a
+		}
+		if len(unreachable) > 0 {
+			addressNote += ", unreachable: " + strings.Join(unreachable, ", ")
+		}
+		debugPrintf(2, "address %s selected\n", a)
+		return
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+// Subcommand faults: prints the active faults (faultInst) of the UCS domain or
+// CIMC, with -follow it keeps polling and prints the new, changed and cleared
+// faults with a timestamp, a tail -f of the faults for the operators during a
+// maintenance window. On a terminal the lines are colored by severity.
+//
+//	check_cisco_ucs faults -H 10.10.1.5 -u admin -p secret -follow -interval 5s
+//
+// A fault is identified by its dn, a fault with severity cleared (UCS Manager
+// keeps it for the retention interval) or no longer returned is cleared.
+// Stops with Ctrl-C, the session is logged out. An expired session is
+// replaced by a new login.
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var faultAttrs = []string{"code", "severity", "descr"}
+
+// severityColors are the ANSI colors of the fault severities
+var severityColors = map[string]string{
+	"critical": "\x1b[1;31m",
+	"major":    "\x1b[31m",
+	"minor":    "\x1b[33m",
+	"warning":  "\x1b[33m",
+	"cleared":  "\x1b[32m",
+}
+
+// faultPrinter prints the fault lines, colored on a terminal
+type faultPrinter struct {
+	color bool
+}
+
+func (p faultPrinter) print(event string, f managedObject) {
+	line := fmt.Sprintf("%s %-7s %-8s %s %s %s", time.Now().Format("2006-01-02 15:04:05"), event,
+		f.Attrs["severity"], f.Attrs["code"], f.Dn, f.Attrs["descr"])
+	color := severityColors[f.Attrs["severity"]]
+	if event == "CLEARED" {
+		color = severityColors["cleared"]
+	}
+	if p.color && len(color) > 0 {
+		line = color + line + "\x1b[0m"
+	}
+	fmt.Println(line)
+}
+
+// isTerminal returns true if stdout is a terminal
+func isTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// activeFaults returns the faults without severity cleared by dn
+func activeFaults(client *http.Client, url, cookie string) (map[string]managedObject, error) {
+	body, err := configRequest(client, url, &ucsxml.ConfigResolveClass{Cookie: cookie, InHierarchical: "false", ClassId: "faultInst"})
+	if err != nil {
+		return nil, err
+	}
+	objects, err := getXmlAttr(string(body), "faultInst", faultAttrs)
+	if err != nil {
+		return nil, err
+	}
+	faults := make(map[string]managedObject)
+	for _, f := range objects {
+		if f.Attrs["severity"] != "cleared" {
+			faults[f.Dn] = f
+		}
+	}
+	return faults, nil
+}
+
+// sortedDns returns the dns of the faults in order
+func sortedDns(faults map[string]managedObject) []string {
+	var dns []string
+	for dn := range faults {
+		dns = append(dns, dn)
+	}
+	sort.Strings(dns)
+	return dns
+}
+
+func runFaults(args []string) int {
+	fs := newFlagSet("faults")
+	follow := fs.Bool("follow", false, "keep polling and print the new, changed and cleared faults")
+	interval := fs.Duration("interval", 10*time.Second, "polling interval of -follow")
+	noColor := fs.Bool("no-color", false, "no colors, default: colors on a terminal")
+	if pos := parseArgs(fs, args); len(pos) > 0 || *interval < time.Second {
+		fs.Usage()
+		return 3
+	}
+
+	client, url := apiClient()
+	cookie := login(client, url)
+	p := faultPrinter{color: !*noColor && useColor()}
+
+	faults, err := activeFaults(client, url, cookie)
+	if err != nil {
+		logout(client, url, cookie)
+		fmt.Printf("UNKNOWN - faults: %v\n", err)
+		return 3
+	}
+	for _, dn := range sortedDns(faults) {
+		p.print("ACTIVE", faults[dn])
+	}
+	if !*follow {
+		logout(client, url, cookie)
+		return 0
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			logout(client, url, cookie)
+			return 0
+		case <-ticker.C:
+		}
+		current, err := activeFaults(client, url, cookie)
+		if apiErr, ok := err.(*ucsxml.APIError); ok && apiErr.Code == sessionExpired {
+			debugPrintf(1, "faults: session expired, new login\n")
+			cookie = login(client, url)
+			current, err = activeFaults(client, url, cookie)
+		}
+		if err != nil {
+			// keep following, e.g. during a fabric interconnect failover
+			fmt.Printf("%s ERROR   %v\n", time.Now().Format("2006-01-02 15:04:05"), err)
+			continue
+		}
+		for _, dn := range sortedDns(current) {
+			prev, ok := faults[dn]
+			switch {
+			case !ok:
+				p.print("NEW", current[dn])
+			case prev.Attrs["severity"] != current[dn].Attrs["severity"]:
+				p.print("CHANGED", current[dn])
+			}
+		}
+		for _, dn := range sortedDns(faults) {
+			if _, ok := current[dn]; !ok {
+				p.print("CLEARED", faults[dn])
+			}
+		}
+		faults = current
+	}
+}
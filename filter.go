@@ -0,0 +1,142 @@
+package main
+
+// Property filter of flag -f, <type>:<property>:<value>, sent with
+// configResolveClass and evaluated by UCS Manager or CIMC, parsed and
+// translated by ucsxml.ParseFilter. The value of a wcard filter is a POSIX
+// extended regex there, not a Go regex: \d, \w, \s, non-capturing groups
+// (?:...) and lazy repeats are translated, flags like (?i), \b and Unicode
+// classes can't be and are rejected.
+//
+// Flag -client-filter: the filter is not sent, the unfiltered objects are
+// fetched and filtered by the plugin, a wcard value is a Go regex (RE2), e.g.
+// -f "wcard:descr:(?i)log capacity". Only eq, ne and wcard, the filter works
+// with query type dn as well (e.g. -t dn -q sys/chassis-1 -s true -o equipmentPsu).
+//
+// Composite filters and(...), or(...) and not(...) combine property filters
+// and composite filters, sent as <and>, <or> and <not> of the XML API:
+//
+//	-f "and(wcard:dn:^sys/chassis-1.*,gt:ambientTempAvg:24)"
+//	-f "or(eq:operState:inoperable,not(eq:presence:equipped))"
+//
+// and and or take two or more filters, not one. The filters are separated by
+// commas, commas inside parentheses or a bracket expression belong to the
+// value, e.g. wcard:dn:^sys/chassis-[1,2]/(psu|fan-module-1-[1,2])$, a comma
+// elsewhere in a value is escaped with a backslash. Spaces around the filters
+// are ignored. With -client-filter the property filters are eq, ne and wcard.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var clientFilter bool
+
+// clientFilterTypes are the filter types of -client-filter
+var clientFilterTypes = map[string]bool{"eq": true, "ne": true, "wcard": true}
+
+// parseFilter parses -f, a property filter or a composite filter
+func parseFilter(s string) (ucsxml.Filter, error) {
+	f, err := ucsxml.ParseFilter(s)
+	if err != nil {
+		return f, fmt.Errorf("flag -f: %v", err)
+	}
+	return f, nil
+}
+
+// newInFilter returns the inFilter of -f for the class
+func newInFilter(class string) *ucsxml.InFilter {
+	f, _ := parseFilter(serverFilter())
+	return f.InFilter(class)
+}
+
+// serverFilter returns the property filter sent with the query, with the
+// dn filter of -org
+func serverFilter() string {
+	f := propertyFilter
+	if clientFilter {
+		f = ""
+	}
+	switch {
+	case len(orgScope) == 0:
+		return f
+	case len(f) == 0:
+		return orgFilter()
+	}
+	return "and(" + orgFilter() + "," + f + ")"
+}
+
+// validatePropertyFilter checks flag -f and -client-filter
+func validatePropertyFilter() error {
+	if len(propertyFilter) == 0 {
+		if clientFilter {
+			return fmt.Errorf("flag -client-filter needs a property filter (-f)")
+		}
+		return nil
+	}
+	f, err := parseFilter(propertyFilter)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range f.Leaves() {
+		if leaf.Type == "wcard" {
+			if err := checkRegex("-f", leaf.Value); err != nil {
+				return err
+			}
+		}
+		if clientFilter {
+			if !clientFilterTypes[leaf.Type] {
+				return fmt.Errorf("flag -client-filter supports the filter types eq, ne and wcard")
+			}
+			continue
+		}
+		if leaf.Type == "wcard" {
+			if _, err := ucsxml.WcardValue(leaf.Value); err != nil {
+				return fmt.Errorf("flag -f: %v, use -client-filter for a Go regex", err)
+			}
+		}
+	}
+	return nil
+}
+
+// queryAttributes returns the attributes of -a and the properties of the
+// client filter
+func queryAttributes() []string {
+	attrs := strings.Split(attributes, " ")
+	if !clientFilter {
+		return attrs
+	}
+	f, _ := parseFilter(propertyFilter)
+	for _, leaf := range f.Leaves() {
+		if leaf.Property != "dn" && findIndex(leaf.Property, attrs) < 0 {
+			attrs = append(attrs, leaf.Property)
+		}
+	}
+	return attrs
+}
+
+// filterObjects returns the objects matching the client filter, the
+// properties of the filter are removed if they aren't attributes of -a
+func filterObjects(objects []managedObject) []managedObject {
+	if !clientFilter {
+		return objects
+	}
+	f, _ := parseFilter(propertyFilter)
+	attrs := strings.Split(attributes, " ")
+	var filtered []managedObject
+	for _, obj := range objects {
+		if !f.Match(obj) {
+			continue
+		}
+		for _, leaf := range f.Leaves() {
+			if findIndex(leaf.Property, attrs) < 0 {
+				delete(obj.Attrs, leaf.Property)
+			}
+		}
+		obj.Keys = attrs
+		filtered = append(filtered, obj)
+	}
+	debugPrintf(2, "client filter %s: %d of %d objects\n", propertyFilter, len(filtered), len(objects))
+	return filtered
+}
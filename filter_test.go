@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+func TestCompositeFilter(t *testing.T) {
+	defer func(f string) { propertyFilter = f }(propertyFilter)
+	propertyFilter = `and(wcard:dn:^sys/chassis-[1,2]/(psu|fan)-.*, or(gt:ambientTempAvg:24,not(eq:descr:a\,b)))`
+	if err := validatePropertyFilter(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := ucsxml.BuildRequest(&ucsxml.ConfigResolveClass{Cookie: "1/abc", InHierarchical: "false", ClassId: "equipmentPsuStats", InFilter: newInFilter("equipmentPsuStats")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<configResolveClass cookie="1/abc" inHierarchical="false" classId="equipmentPsuStats"><inFilter><and>` +
+		`<wcard class="equipmentPsuStats" property="dn" value="^sys/chassis-[1,2]/(psu|fan)-.*" />` +
+		`<or><gt class="equipmentPsuStats" property="ambientTempAvg" value="24" />` +
+		`<not><eq class="equipmentPsuStats" property="descr" value="a,b" /></not></or></and></inFilter></configResolveClass>`
+	if string(req) != want {
+		t.Errorf("got  %s\nwant %s", req, want)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build fips
+
+package main
+
+// fipsBuild is true in the FIPS build (go build -tags fips), FIPS mode can't
+// be turned off, see tls.go
+const fipsBuild = true
@@ -0,0 +1,6 @@
+//go:build !fips
+
+package main
+
+// fipsBuild is false without the build tag fips, FIPS mode with flag -fips
+const fipsBuild = false
@@ -0,0 +1,88 @@
+package main
+
+// Flag -host-firmware: adds the running firmware of every object (e.g. a VIC
+// adapter) and compares its package with the package of the firmware of the
+// server (blade or rack server) the object belongs to. UCS Manager updates
+// the adapters, BIOS and CIMC of a server with its host firmware package, an
+// adapter with a different package missed the last update (e.g. replaced
+// adapter, failed activation). Added attributes, after those of -a and -join:
+//
+//	fwVersion	running firmware version of the object (<dn>/mgmt/fw-system)
+//	fwPackage	package version of this firmware
+//	fwMatch		yes if fwPackage is the package of the server's CIMC firmware or the server has none (standalone CIMC)
+//
+// XML API only, one additional configResolveClass request of firmwareRunning.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var hostFirmware bool
+
+// serverDn matches the dn of the blade or rack server of a component
+var serverDn = regexp.MustCompile(`^.*?/(blade|rack-unit)-[^/]+`)
+
+// hostFirmwareAttrs returns the attributes added by -host-firmware
+func hostFirmwareAttrs() []string {
+	if !hostFirmware {
+		return nil
+	}
+	return []string{"fwVersion", "fwPackage", "fwMatch"}
+}
+
+// firmwareOf returns the system firmware of dn, <dn>/mgmt/fw-system or the
+// nearest fw-system below dn
+func firmwareOf(dn string, firmware []managedObject) *managedObject {
+	var nearest *managedObject
+	for i := range firmware {
+		fw := &firmware[i]
+		if fw.Dn == dn+"/mgmt/fw-system" {
+			return fw
+		}
+		if strings.HasPrefix(fw.Dn, dn+"/") && strings.HasSuffix(fw.Dn, "/fw-system") && (nearest == nil || len(fw.Dn) < len(nearest.Dn)) {
+			nearest = fw
+		}
+	}
+	return nearest
+}
+
+// hostFirmwareObjects adds the firmware attributes to the objects
+func hostFirmwareObjects(b Backend, objects []managedObject) error {
+	if !hostFirmware {
+		return nil
+	}
+	xb := xmlSession(b)
+	body, err := configRequest(xb.client, xb.url, &ucsxml.ConfigResolveClass{Cookie: xb.cookie, InHierarchical: "false", ClassId: "firmwareRunning"})
+	if err != nil {
+		return fmt.Errorf("host firmware: %v", err)
+	}
+	firmware, err := getXmlAttr(string(body), "firmwareRunning", []string{"version", "packageVersion"})
+	if err != nil {
+		return fmt.Errorf("host firmware: %v", err)
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), hostFirmwareAttrs()...)
+		fw := firmwareOf(obj.Dn, firmware)
+		if fw == nil {
+			debugPrintf(2, "host firmware: no firmware of %s\n", obj.Dn)
+			continue
+		}
+		obj.Attrs["fwVersion"] = fw.Attrs["version"]
+		obj.Attrs["fwPackage"] = fw.Attrs["packageVersion"]
+		match := "yes"
+		if server := serverDn.FindString(obj.Dn); len(server) > 0 && server != obj.Dn {
+			if sfw := firmwareOf(server, firmware); sfw != nil && len(sfw.Attrs["packageVersion"]) > 0 && sfw.Attrs["packageVersion"] != fw.Attrs["packageVersion"] {
+				debugPrintf(2, "host firmware: %s package %s, server %s package %s\n", obj.Dn, fw.Attrs["packageVersion"], server, sfw.Attrs["packageVersion"])
+				match = "no"
+			}
+		}
+		obj.Attrs["fwMatch"] = match
+	}
+	return nil
+}
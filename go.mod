@@ -0,0 +1,9 @@
+module github.com/mlueckert/check_cisco_ucs
+
+go 1.24.0
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.52
+	golang.org/x/crypto v0.48.0
+	golang.org/x/sys v0.41.0
+)
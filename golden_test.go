@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of TestGolden")
+
+// TestGolden runs checks against the captured responses of the tested
+// firmware versions (see the header of check_cisco_ucs.go) and compares the
+// plugin output with testdata/golden/<capture>.golden. After an intended
+// change of the output: go test -run TestGolden -update
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		file  string
+		flags [][2]string
+	}{
+		{"cimc-1.5-configResolveClass-storageVirtualDrive.xml",
+			[][2]string{{"t", "class"}, {"q", "storageVirtualDrive"}, {"a", "id raidLevel vdStatus health"}, {"e", ",Optimal,Good$"}}},
+		{"cimc-2.0-configResolveClass-storageLocalDisk.xml",
+			[][2]string{{"t", "class"}, {"q", "storageLocalDisk"}, {"a", "id pdStatus driveSerialNumber"}, {"e", ",(Online|Unconfigured Good|JBOD|Hot Spare),"}}},
+		{"cimc-3.0-configResolveClass-storageController.xml",
+			[][2]string{{"t", "class"}, {"q", "storageController"}, {"a", "id health"}, {"e", ",Good$"}}},
+		{"cimc-4.0-configResolveClass-faultInst-latin1.xml",
+			[][2]string{{"t", "class"}, {"q", "faultInst"}, {"a", "code severity descr"}, {"e", "^[^,]*,(cleared|info|condition|warning),"}, {"z", "true"}}},
+		{"cimc-4.1-configResolveDn-computeRackUnit.xml",
+			[][2]string{{"t", "dn"}, {"q", "sys/rack-unit-1"}, {"o", "computeRackUnit"}, {"a", "dn model operPower"}, {"e", ",on$"}}},
+		{"ucsm-2.1-configResolveClass-computeBlade.xml",
+			[][2]string{{"t", "class"}, {"q", "computeBlade"}, {"a", "dn operability"}, {"e", ",operable$"}, {"F", "true"}}},
+		{"ucsm-2.2-configResolveClass-equipmentFan.xml",
+			[][2]string{{"t", "class"}, {"q", "equipmentFan"}, {"a", "dn operState perf"}, {"e", ",operable,"}, {"require", "3 of 4"}}},
+		{"ucsm-3.2-configResolveClass-equipmentPsu.xml",
+			[][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn model operState"}, {"e", ",operable$"}}},
+		{"ucsm-4.1-configResolveClass-faultInst.xml",
+			[][2]string{{"t", "class"}, {"q", "faultInst"}, {"a", "code severity ack descr"}, {"e", "^[^,]*,(cleared|info|condition|warning),|^[^,]*,[^,]*,yes,"}, {"z", "true"}}},
+	}
+
+	saved := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		saved[f.Name] = f.Value.String()
+	})
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			defer restoreFlags(saved)
+			for _, kv := range tt.flags {
+				if err := flag.Set(kv[0], kv[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := validateCheckFlags(); err != nil {
+				t.Fatal(err)
+			}
+			// like readBody
+			body, _ := ucsxml.ToUTF8(readTestdata(t, tt.file))
+			res := check(&fakeBackend{body: string(body)})
+			var out bytes.Buffer
+			nagiosRenderer{}.Render(&out, []*checkResult{res})
+			out.WriteString("exit code: " + statePrefix[res.State] + "\n")
+
+			golden := filepath.Join("testdata", "golden", tt.file[:len(tt.file)-len(filepath.Ext(tt.file))]+".golden")
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s:\n%s\nwant:\n%s", golden, out.Bytes(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+// Heartbeat of the agent: with -heartbeat-interval the agent submits a passive
+// service result "agent alive" for every UCS domain it serves to the external
+// command file of Nagios (or Icinga, Naemon). With freshness checking enabled
+// on that service a dead agent is detected instead of silently stale results.
+//
+//	define service {
+//		host_name               <ip_addr of the UCS domain>
+//		service_description     Cisco UCS agent
+//		active_checks_enabled   0
+//		passive_checks_enabled  1
+//		check_freshness         1
+//		freshness_threshold     180
+//		check_command           check_dummy!2!"agent heartbeat missing"
+//	}
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// domains returns the hosts served by the agent
+func (a *agent) domains() []string {
+	seen := make(map[string]bool)
+	a.mu.Lock()
+	for _, s := range a.sessions {
+		seen[s.host] = true
+	}
+	a.mu.Unlock()
+	a.metrics.mu.Lock()
+	for h := range a.metrics.requests {
+		seen[h] = true
+	}
+	a.metrics.mu.Unlock()
+
+	var hosts []string
+	for h := range seen {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// heartbeatResult returns the passive check result of a host
+func (a *agent) heartbeatResult(host string) string {
+	sessions := 0
+	a.mu.Lock()
+	for _, s := range a.cookies {
+		if s.host == host {
+			sessions++
+		}
+	}
+	a.mu.Unlock()
+	a.metrics.mu.Lock()
+	requests, errors := a.metrics.requests[host], a.metrics.errors[host]
+	a.metrics.mu.Unlock()
+	return fmt.Sprintf("OK - check_cisco_ucs agent alive, %d sessions, %d requests, %d errors", sessions, requests, errors)
+}
+
+// heartbeat writes a PROCESS_SERVICE_CHECK_RESULT per domain to the external
+// command file at every interval
+func (a *agent) heartbeat(interval time.Duration, commandFile, service string) {
+	for range time.Tick(interval) {
+		var lines []string
+		now := time.Now().Unix()
+		for _, host := range a.domains() {
+			lines = append(lines, fmt.Sprintf("[%d] PROCESS_SERVICE_CHECK_RESULT;%s;%s;0;%s\n", now, host, service, a.heartbeatResult(host)))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if err := writeCommands(commandFile, strings.Join(lines, "")); err != nil {
+			log.Printf("agent: heartbeat failed: %v\n", err)
+		}
+	}
+}
+
+// writeCommands appends commands to the external command file (a named pipe)
+func writeCommands(commandFile, commands string) error {
+	f, err := os.OpenFile(commandFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(commands); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,110 @@
+package main
+
+// Flag -hfp: compares the running firmware of every server (blade or rack
+// server managed by UCS Manager) with the host firmware package of its service
+// profile. A changed package is activated with the next reboot of the server
+// (user acknowledgement of the maintenance policy), until then the server runs
+// the old firmware unnoticed. Template hfp-compliance lists the servers
+// pending activation. Added attributes, after those of -a and -join:
+//
+//	hfpVersion	blade or rack bundle version of the host firmware package, empty: no package
+//	runningVersion	package version of the running CIMC firmware of the server
+//	hfpStatus	compliant, pending-activation, activating (firmware update running) or none (no service profile or package)
+//
+// XML API only, additional configResolveClass requests of lsServer,
+// firmwareComputeHostPack, firmwareRunning and firmwareStatus.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var hostFirmwarePackage bool
+
+// hfpAttrs returns the attributes added by -hfp
+func hfpAttrs() []string {
+	if !hostFirmwarePackage {
+		return nil
+	}
+	return []string{"hfpVersion", "runningVersion", "hfpStatus"}
+}
+
+// resolveClass returns the objects of a class with the attributes
+func resolveClass(xb *xmlBackend, class string, attrs ...string) ([]managedObject, error) {
+	body, err := configRequest(xb.client, xb.url, &ucsxml.ConfigResolveClass{Cookie: xb.cookie, InHierarchical: "false", ClassId: class})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", class, err)
+	}
+	return getXmlAttr(string(body), class, attrs)
+}
+
+// hfpObjects adds the host firmware package compliance of the servers of the objects
+func hfpObjects(b Backend, objects []managedObject) error {
+	if !hostFirmwarePackage {
+		return nil
+	}
+	xb := xmlSession(b)
+	profiles, err := resolveClass(xb, "lsServer", "pnDn", "operHostFwPolicyName")
+	if err != nil {
+		return fmt.Errorf("host firmware package: %v", err)
+	}
+	packs, err := resolveClass(xb, "firmwareComputeHostPack", "bladeBundleVersion", "rackBundleVersion")
+	if err != nil {
+		return fmt.Errorf("host firmware package: %v", err)
+	}
+	firmware, err := resolveClass(xb, "firmwareRunning", "version", "packageVersion")
+	if err != nil {
+		return fmt.Errorf("host firmware package: %v", err)
+	}
+	status, err := resolveClass(xb, "firmwareStatus", "operState")
+	if err != nil {
+		return fmt.Errorf("host firmware package: %v", err)
+	}
+
+	// package of the service profile per server
+	packOf := make(map[string]string)
+	for _, sp := range profiles {
+		if len(sp.Attrs["pnDn"]) > 0 {
+			packOf[sp.Attrs["pnDn"]] = sp.Attrs["operHostFwPolicyName"]
+		}
+	}
+	bundles := make(map[string]managedObject)
+	for _, p := range packs {
+		bundles[p.Dn] = p
+	}
+	operState := make(map[string]string)
+	for _, s := range status {
+		operState[strings.TrimSuffix(s.Dn, "/fw-status")] = s.Attrs["operState"]
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), hfpAttrs()...)
+		server := serverDn.FindString(obj.Dn)
+		if fw := firmwareOf(server, firmware); len(server) > 0 && fw != nil {
+			obj.Attrs["runningVersion"] = fw.Attrs["packageVersion"]
+		}
+		bundle := "bladeBundleVersion"
+		if strings.Contains(server, "/rack-unit-") {
+			bundle = "rackBundleVersion"
+		}
+		pack, ok := bundles[packOf[server]]
+		want := pack.Attrs[bundle]
+		obj.Attrs["hfpVersion"] = want
+		switch {
+		case len(server) == 0 || !ok || len(want) == 0:
+			obj.Attrs["hfpStatus"] = "none"
+		case obj.Attrs["runningVersion"] == want:
+			obj.Attrs["hfpStatus"] = "compliant"
+		case len(operState[server]) > 0 && operState[server] != "ready":
+			debugPrintf(2, "host firmware package: %s firmware status %s\n", server, operState[server])
+			obj.Attrs["hfpStatus"] = "activating"
+		default:
+			debugPrintf(2, "host firmware package: %s running %s, package %s %s\n", server, obj.Attrs["runningVersion"], packOf[server], want)
+			obj.Attrs["hfpStatus"] = "pending-activation"
+		}
+	}
+	return nil
+}
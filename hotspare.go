@@ -0,0 +1,66 @@
+package main
+
+// Flag -hot-spares: adds the number of hot spare disks of the storage
+// controller of every object (e.g. a virtual drive) as attribute hotSpares, a
+// controller whose hot spares were used up by a rebuild runs the next disk
+// failure degraded. A lower limit threshold alerts below a number (template
+// virtual-drives):
+//
+//	-q storageVirtualDrive -a "id raidLevel size stripSize vdStatus health" -hot-spares -w hotSpares=1: -perfdata "size stripSize hotSpares"
+//
+// Hot spares are the disks of the controller with pdStatus (CIMC) or diskState
+// (UCS Manager) hot spare, dedicated or global. XML API only, one additional
+// configResolveClass request of storageLocalDisk.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var hotSpares bool
+
+// controllerDn matches the dn of the storage controller of a component
+var controllerDn = regexp.MustCompile(`^.*?/storage-[^/]+`)
+
+// hotSpareState matches the states of a hot spare disk
+var hotSpareState = regexp.MustCompile(`(?i)hot[ -]?spare`)
+
+// hotSpareAttrs returns the attribute added by -hot-spares
+func hotSpareAttrs() []string {
+	if !hotSpares {
+		return nil
+	}
+	return []string{"hotSpares"}
+}
+
+// hotSpareObjects adds the number of hot spares of the controller to the objects
+func hotSpareObjects(b Backend, objects []managedObject) error {
+	if !hotSpares {
+		return nil
+	}
+	disks, err := resolveClass(xmlSession(b), "storageLocalDisk", diskStateAttrs...)
+	if err != nil {
+		return fmt.Errorf("hot spares: %v", err)
+	}
+	count := make(map[string]int)
+	for _, d := range disks {
+		for _, attr := range diskStateAttrs {
+			if hotSpareState.MatchString(d.Attrs[attr]) {
+				count[controllerDn.FindString(d.Dn)]++
+				break
+			}
+		}
+	}
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), hotSpareAttrs()...)
+		controller := controllerDn.FindString(obj.Dn)
+		if len(controller) == 0 {
+			debugPrintf(2, "hot spares: no controller of %s\n", obj.Dn)
+			continue
+		}
+		obj.Attrs["hotSpares"] = strconv.Itoa(count[controller])
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+// Imbalance of flag -imbalance <attribute>: the deviation of a numeric
+// attribute of every object from the average of the objects of the same
+// chassis, rack server or fabric interconnect (the first two components of
+// the dn, e.g. sys/chassis-3) in percent, added as attribute "imbalance". The
+// thresholds -w and -c apply to it like to a derived attribute. Example: the
+// input current of the power supplies of a chassis (template psu-imbalance),
+// a power supply drawing much more or less than the others of its chassis is
+// an early sign of a failing power supply or a miswired PDU phase.
+//
+//	-q equipmentPsuInputStats -a "dn current" -imbalance current -e . -w imbalance=20 -c imbalance=35
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// imbalanceName is the attribute added by -imbalance
+const imbalanceName = "imbalance"
+
+var imbalanceAttr string
+
+// imbalanceAttrs returns the attribute added by -imbalance
+func imbalanceAttrs() []string {
+	if len(imbalanceAttr) == 0 {
+		return nil
+	}
+	return []string{imbalanceName}
+}
+
+// imbalanceGroup returns the first two components of a dn, e.g. sys/chassis-3
+func imbalanceGroup(dn string) string {
+	parts := strings.SplitN(dn, "/", 3)
+	if len(parts) < 2 {
+		return dn
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// applyImbalance adds the imbalance to the objects with a numeric value of
+// the attribute, objects without a value have no imbalance
+func applyImbalance(objects []managedObject) {
+	if len(imbalanceAttr) == 0 {
+		return
+	}
+	sum := make(map[string]float64)
+	count := make(map[string]int)
+	for _, obj := range objects {
+		if v, err := strconv.ParseFloat(obj.Attrs[imbalanceAttr], 64); err == nil {
+			g := imbalanceGroup(obj.Dn)
+			sum[g] += v
+			count[g]++
+		}
+	}
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), imbalanceName)
+		v, err := strconv.ParseFloat(obj.Attrs[imbalanceAttr], 64)
+		if err != nil {
+			continue
+		}
+		g := imbalanceGroup(obj.Dn)
+		avg := sum[g] / float64(count[g])
+		deviation := 0.0
+		if avg != 0 {
+			deviation = math.Abs(v-avg) / math.Abs(avg) * 100
+		}
+		debugPrintf(3, "imbalance %s: %s %v, average of %s %v\n", obj.Dn, imbalanceAttr, v, g, avg)
+		obj.Attrs[imbalanceName] = strconv.FormatFloat(math.Round(deviation*10)/10, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+// Flag -join <class>:<attributes>: the objects of a second class are matched
+// with the objects of the query by dn prefix and their attributes are added,
+// e.g. the state of a power supply with the counters of its stats object:
+//
+//	-q equipmentPsu -a "dn operState" -join "equipmentPsuStats:outputPower ambientTemp"
+//
+// sys/chassis-1/psu-1 is joined with its nearest child (sys/chassis-1/psu-1/stats)
+// or, if there is none, its nearest parent of the class (e.g. a chassis).
+// The joined attributes are shown after the attributes of -a and can be used
+// in -derive, thresholds and perfdata. XML API only.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+var joinSpec string
+
+// parseJoin returns the class and the attributes of -join
+func parseJoin() (string, []string, error) {
+	kv := strings.SplitN(joinSpec, ":", 2)
+	if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 || len(strings.Fields(kv[1])) == 0 {
+		return "", nil, fmt.Errorf("flag -join: expected <class>:<attributes>, example: equipmentPsuStats:outputPower ambientTemp")
+	}
+	return strings.TrimSpace(kv[0]), strings.Fields(kv[1]), nil
+}
+
+// joinAttrs returns the attributes added by -join
+func joinAttrs() []string {
+	if len(joinSpec) == 0 {
+		return nil
+	}
+	_, attrs, _ := parseJoin()
+	return attrs
+}
+
+// joinedObject returns the nearest child or parent of dn in objects
+func joinedObject(dn string, objects []managedObject) *managedObject {
+	var child, parent *managedObject
+	for i := range objects {
+		o := &objects[i]
+		switch {
+		case strings.HasPrefix(o.Dn, dn+"/"):
+			if child == nil || len(o.Dn) < len(child.Dn) {
+				child = o
+			}
+		case strings.HasPrefix(dn, o.Dn+"/"):
+			if parent == nil || len(o.Dn) > len(parent.Dn) {
+				parent = o
+			}
+		}
+	}
+	if child != nil {
+		return child
+	}
+	return parent
+}
+
+// joinObjects adds the attributes of the -join class to the objects
+func joinObjects(b Backend, objects []managedObject) error {
+	if len(joinSpec) == 0 {
+		return nil
+	}
+	joinClass, attrs, err := parseJoin()
+	if err != nil {
+		return err
+	}
+	xb := xmlSession(b)
+	body, err := configRequest(xb.client, xb.url, &ucsxml.ConfigResolveClass{Cookie: xb.cookie, InHierarchical: "false", ClassId: joinClass})
+	if err != nil {
+		return fmt.Errorf("join %s: %v", joinClass, err)
+	}
+	joined, err := getXmlAttr(string(body), joinClass, attrs)
+	if err != nil {
+		return fmt.Errorf("join %s: %v", joinClass, err)
+	}
+
+	for i := range objects {
+		objects[i].Keys = append(append([]string{}, objects[i].Keys...), attrs...)
+		j := joinedObject(objects[i].Dn, joined)
+		if j == nil {
+			debugPrintf(2, "join: no %s object for %s\n", joinClass, objects[i].Dn)
+			continue
+		}
+		for _, a := range attrs {
+			if v, ok := j.Attrs[a]; ok {
+				objects[i].Attrs[a] = v
+			}
+		}
+	}
+	return nil
+}
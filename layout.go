@@ -0,0 +1,161 @@
+package main
+
+// Disk layout of flag -layout-file <file>: the state of every disk of the
+// query (pdStatus of CIMC or diskState of UCS Manager in -a) is compared with
+// the expected state of its slot in the layout of the server model. A disk
+// that dropped out of its RAID group and came back as JBOD or Unconfigured
+// Good reports a good health, only the layout shows the drift. Added attribute
+// "layout": "compliant", "none" (no layout of the model) or the expected
+// state. Template disk-layout: -check disk-layout -layout-file layout.yaml
+//
+// The file has the syntax of -expect-file, the server models with the slots
+// (number, range or *) and the expected states (regular expression):
+//
+//	# boot mirror, data disks as JBOD
+//	UCSC-C240-M5SX:
+//	  1-2: Online
+//	  "*": JBOD|Hot Spare
+//
+// XML API only, one additional configResolveClass request of the servers.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var layoutFile string
+
+// layoutSlots is the expected state of the slots of a server model
+type layoutSlots struct {
+	from, to int // slot range, 0: all slots (*)
+	state    *regexp.Regexp
+}
+
+// diskStateAttrs are the attributes with the state of a disk
+var diskStateAttrs = []string{"pdStatus", "diskState"}
+
+// slotNumber matches the slot of a disk rn, e.g. pd-3 or disk-3
+var slotNumber = regexp.MustCompile(`-(\d+)$`)
+
+// loadLayoutFile reads the expected disk states per server model
+func loadLayoutFile(filename string) (map[string][]layoutSlots, error) {
+	entries, err := loadYamlSubset(filename, "model", "slot")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no server models", filename)
+	}
+	layouts := make(map[string][]layoutSlots)
+	for _, e := range entries {
+		var s layoutSlots
+		if e.attr != "*" {
+			bounds := strings.SplitN(e.attr, "-", 2)
+			if s.from, err = strconv.Atoi(bounds[0]); err != nil || s.from < 1 {
+				return nil, fmt.Errorf("%s: model %s: invalid slot %q, expected a number, range or *", filename, e.class, e.attr)
+			}
+			s.to = s.from
+			if len(bounds) == 2 {
+				if s.to, err = strconv.Atoi(bounds[1]); err != nil || s.to < s.from {
+					return nil, fmt.Errorf("%s: model %s: invalid slot %q, expected a number, range or *", filename, e.class, e.attr)
+				}
+			}
+		}
+		if s.state, err = regexp.Compile("^(?:" + e.value + ")$"); err != nil {
+			return nil, fmt.Errorf("%s: model %s slot %s: %v", filename, e.class, e.attr, err)
+		}
+		layouts[e.class] = append(layouts[e.class], s)
+	}
+	return layouts, nil
+}
+
+// expectedState returns the expected state of a slot, the first matching
+// slots of the layout
+func expectedState(layout []layoutSlots, slot int) *regexp.Regexp {
+	for _, s := range layout {
+		if s.from == 0 || (slot >= s.from && slot <= s.to) {
+			return s.state
+		}
+	}
+	return nil
+}
+
+// layoutAttrs returns the attribute added by -layout-file
+func layoutAttrs() []string {
+	if len(layoutFile) == 0 {
+		return nil
+	}
+	return []string{"layout"}
+}
+
+// diskStateAttr returns the attribute of -a with the state of the disks
+func diskStateAttr() string {
+	for _, a := range diskStateAttrs {
+		if findIndex(a, strings.Split(attributes, " ")) >= 0 {
+			return a
+		}
+	}
+	return ""
+}
+
+// layoutObjects compares the states of the disks with the layout of their server model
+func layoutObjects(b Backend, objects []managedObject) error {
+	if len(layoutFile) == 0 {
+		return nil
+	}
+	layouts, err := loadLayoutFile(layoutFile)
+	if err != nil {
+		return err
+	}
+	xb := xmlSession(b)
+	models := make(map[string]string)
+	for _, class := range []string{"computeRackUnit", "computeBlade"} {
+		part := "/rack-unit-"
+		if class == "computeBlade" {
+			part = "/blade-"
+		}
+		// CIMC knows no blades
+		needed := false
+		for _, obj := range objects {
+			needed = needed || strings.Contains(obj.Dn, part)
+		}
+		if !needed {
+			continue
+		}
+		servers, err := resolveClass(xb, class, "model")
+		if err != nil {
+			return fmt.Errorf("disk layout: %v", err)
+		}
+		for _, s := range servers {
+			models[s.Dn] = s.Attrs["model"]
+		}
+	}
+
+	state := diskStateAttr()
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), layoutAttrs()...)
+		model := models[serverDn.FindString(obj.Dn)]
+		m := slotNumber.FindStringSubmatch(obj.Dn)
+		layout, ok := layouts[model]
+		if !ok || m == nil {
+			debugPrintf(2, "disk layout: no layout of %s (model %q)\n", obj.Dn, model)
+			obj.Attrs["layout"] = "none"
+			continue
+		}
+		slot, _ := strconv.Atoi(m[1])
+		expected := expectedState(layout, slot)
+		switch {
+		case expected == nil:
+			obj.Attrs["layout"] = "drift: slot not in the layout of " + model
+		case expected.MatchString(obj.Attrs[state]):
+			obj.Attrs["layout"] = "compliant"
+		default:
+			// no commas, they separate the attributes of the line
+			obj.Attrs["layout"] = "drift: expected " + strings.Replace(strings.TrimSuffix(strings.TrimPrefix(expected.String(), "^(?:"), ")$"), ",", ";", -1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadLayoutFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "layout.yaml")
+	data := "UCSC-C240-M5SX:\n  1-2: Online\n  5: Hot Spare\n  \"*\": JBOD|Unconfigured Good\n"
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	layouts, err := loadLayoutFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		slot  int
+		state string
+		want  bool
+	}{
+		{1, "Online", true},
+		{2, "JBOD", false},
+		{5, "Hot Spare", true},
+		{7, "Unconfigured Good", true},
+		{7, "Unconfigured Good (Foreign)", false},
+	}
+	for _, tt := range tests {
+		if got := expectedState(layouts["UCSC-C240-M5SX"], tt.slot).MatchString(tt.state); got != tt.want {
+			t.Errorf("slot %d %q: %v, want %v", tt.slot, tt.state, got, tt.want)
+		}
+	}
+
+	for _, data := range []string{"UCSC-C240-M5SX:\n  0: Online\n", "UCSC-C240-M5SX:\n  3-1: Online\n", "UCSC-C240-M5SX:\n  1: (Online\n"} {
+		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadLayoutFile(file); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+// Leases of flag -lease: pollers sharing the same checks (HA pair, several
+// batch nodes) poll a UCS domain only on the node holding its lease, the
+// others return OK without login, no duplicate sessions and alerts. The lease
+// is held for -lease-ttl and renewed by every run of its owner, if the owner
+// stops polling another node takes it over after the TTL, -lease-ttl has to
+// be longer than the check interval.
+//
+//	-lease redis://:secret@redis.example.com:6379/2	key check_cisco_ucs:lease:<domain>, SET NX PX
+//	-lease /mnt/shared/ucs-leases			lock file <domain>.lease per domain
+//
+// The owner is the host name of the node, -lease-owner overrides it. In batch
+// mode (-H srv: or consul:) every target has its own lease. Lock files need a
+// shared file system with exclusive create, e.g. NFSv3 or later.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	leaseLocation string
+	leaseTTL      time.Duration
+	leaseOwner    string
+)
+
+// leaseFile is the content of a lock file
+type leaseFile struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// leaseChars are replaced in the file name of a lock file
+var leaseChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// validateLease checks flags -lease and -lease-ttl
+func validateLease() error {
+	if len(leaseLocation) == 0 {
+		return nil
+	}
+	if leaseTTL < time.Second {
+		return fmt.Errorf("flag -lease-ttl must be at least 1s")
+	}
+	if len(leaseOwner) == 0 {
+		name, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("flag -lease: no host name, use -lease-owner: %v", err)
+		}
+		leaseOwner = name
+	}
+	return nil
+}
+
+// acquireLease acquires or renews the lease of the domain, it returns the
+// owner of the lease if it is held by another node
+func acquireLease(domain string) (string, error) {
+	if strings.HasPrefix(leaseLocation, "redis:") {
+		return acquireRedisLease(domain)
+	}
+	return acquireFileLease(domain)
+}
+
+func acquireRedisLease(domain string) (string, error) {
+	s, err := dialRedis(leaseLocation)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	key := redisKeyPrefix + "lease:" + domain
+	ttl := strconv.FormatInt(leaseTTL.Milliseconds(), 10)
+	reply, err := s.do("SET", key, leaseOwner, "NX", "PX", ttl)
+	if err != nil || reply != nil {
+		return "", err
+	}
+	owner, err := s.do("GET", key)
+	if err != nil {
+		return "", err
+	}
+	if owner != nil && string(owner) != leaseOwner {
+		return string(owner), nil
+	}
+	// renew, or acquire if it expired right after SET NX
+	_, err = s.do("SET", key, leaseOwner, "PX", ttl)
+	return "", err
+}
+
+func acquireFileLease(domain string) (string, error) {
+	filename := filepath.Join(leaseLocation, leaseChars.ReplaceAllString(domain, "_")+".lease")
+	buf, err := json.Marshal(leaseFile{Owner: leaseOwner, Expires: time.Now().Add(leaseTTL)})
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err == nil {
+		_, err = f.Write(buf)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return "", err
+	}
+	if !os.IsExist(err) {
+		return "", err
+	}
+
+	var held leaseFile
+	current, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	// a lock file being written by another node is held
+	if err := json.Unmarshal(current, &held); err != nil {
+		return "unknown", nil
+	}
+	if held.Owner != leaseOwner && time.Now().Before(held.Expires) {
+		return held.Owner, nil
+	}
+	if err := writeFileAtomic(filename, buf, 0644); err != nil {
+		return "", err
+	}
+	// two nodes taking over an expired lease: the last rename wins
+	current, err = ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(current, &held); err == nil && held.Owner != leaseOwner {
+		return held.Owner, nil
+	}
+	return "", nil
+}
+
+// leasedResult returns the result of a domain polled by another node, OK so
+// that only the owner alerts
+func leasedResult(domain, owner string) *checkResult {
+	res := newResult()
+	res.State, res.Status = 0, statePrefix[0]
+	res.Output = fmt.Sprintf("%s is polled by %s (lease), not checked", domain, owner)
+	return res
+}
+
+// leaseHeld returns the result of a domain whose lease is held by another
+// node, nil if the domain is polled here
+func leaseHeld(domain string) *checkResult {
+	if len(leaseLocation) == 0 {
+		return nil
+	}
+	owner, err := acquireLease(domain)
+	if err != nil {
+		// better two nodes polling than none
+		debugPrintf(1, "lease of %s: %v, polled anyway\n", domain, err)
+		return nil
+	}
+	if len(owner) == 0 {
+		debugPrintf(2, "lease of %s held by %s for %s\n", domain, leaseOwner, leaseTTL)
+		return nil
+	}
+	debugPrintf(1, "lease of %s held by %s, not polled\n", domain, owner)
+	return leasedResult(domain, owner)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileLease(t *testing.T) {
+	defer func(l, o string, ttl time.Duration) { leaseLocation, leaseOwner, leaseTTL = l, o, ttl }(leaseLocation, leaseOwner, leaseTTL)
+	leaseLocation, leaseTTL = t.TempDir(), time.Minute
+
+	for _, tt := range []struct {
+		owner, want string // want: owner of the lease held elsewhere
+	}{
+		{"node-a", ""},
+		{"node-b", "node-a"},
+		{"node-a", ""}, // renewed
+	} {
+		leaseOwner = tt.owner
+		if got, err := acquireLease("ucs-a.example.com"); err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.owner, got, err, tt.want)
+		}
+	}
+
+	// expired: taken over
+	leaseOwner, leaseTTL = "node-a", -time.Second
+	acquireLease("ucs-a.example.com")
+	leaseOwner = "node-b"
+	if got, err := acquireLease("ucs-a.example.com"); err != nil || got != "" {
+		t.Errorf("expired lease: got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+// Internal metrics of the agent in Prometheus text format, served on
+// GET /metrics of the agent listener.
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type agentMetrics struct {
+	mu          sync.Mutex
+	requests    map[string]int // key: host
+	errors      map[string]int
+	durationSum map[string]float64
+	logins      map[string]int
+	rejected    int
+	inFlight    int
+}
+
+func newAgentMetrics() *agentMetrics {
+	return &agentMetrics{
+		requests:    make(map[string]int),
+		errors:      make(map[string]int),
+		durationSum: make(map[string]float64),
+		logins:      make(map[string]int),
+	}
+}
+
+func (m *agentMetrics) start() {
+	m.mu.Lock()
+	m.inFlight++
+	m.mu.Unlock()
+}
+
+// done records a finished request
+func (m *agentMetrics) done(host string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	m.requests[host]++
+	m.durationSum[host] += d.Seconds()
+	if err != nil {
+		m.errors[host]++
+	}
+}
+
+func (m *agentMetrics) login(host string) {
+	m.mu.Lock()
+	m.logins[host]++
+	m.mu.Unlock()
+}
+
+func (m *agentMetrics) reject() {
+	m.mu.Lock()
+	m.rejected++
+	m.mu.Unlock()
+}
+
+func (m *agentMetrics) write(w io.Writer, sessions int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var hosts []string
+	for h := range m.requests {
+		hosts = append(hosts, h)
+	}
+	for h := range m.logins {
+		if _, ok := m.requests[h]; !ok {
+			hosts = append(hosts, h)
+		}
+	}
+	sort.Strings(hosts)
+
+	counter := func(name, help string, values map[string]int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, h := range hosts {
+			fmt.Fprintf(w, "%s{host=%q} %d\n", name, h, values[h])
+		}
+	}
+	counter("ucs_agent_requests_total", "XML API requests passed through the agent", m.requests)
+	counter("ucs_agent_request_errors_total", "XML API requests failed in the agent", m.errors)
+	counter("ucs_agent_logins_total", "logins of the agent, more than one per host are re-logins", m.logins)
+
+	fmt.Fprintf(w, "# HELP ucs_agent_request_duration_seconds_sum total time of the XML API requests\n")
+	fmt.Fprintf(w, "# TYPE ucs_agent_request_duration_seconds_sum counter\n")
+	for _, h := range hosts {
+		fmt.Fprintf(w, "ucs_agent_request_duration_seconds_sum{host=%q} %g\n", h, m.durationSum[h])
+	}
+
+	fmt.Fprintf(w, "# HELP ucs_agent_requests_rejected_total requests rejected because of -max-concurrent-scrapes\n")
+	fmt.Fprintf(w, "# TYPE ucs_agent_requests_rejected_total counter\nucs_agent_requests_rejected_total %d\n", m.rejected)
+	fmt.Fprintf(w, "# HELP ucs_agent_requests_in_flight requests currently processed\n")
+	fmt.Fprintf(w, "# TYPE ucs_agent_requests_in_flight gauge\nucs_agent_requests_in_flight %d\n", m.inFlight)
+	fmt.Fprintf(w, "# HELP ucs_agent_sessions XML API sessions of the agent\n")
+	fmt.Fprintf(w, "# TYPE ucs_agent_sessions gauge\nucs_agent_sessions %d\n", sessions)
+}
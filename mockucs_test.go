@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// mockCookie is the outCookie of testdata/ucsm-4.1-aaaLogin.xml
+const mockCookie = "1602751220/8f2a6b31-0c9e-4d25-a1f7-5e6b8c9d0a12"
+
+// mockUCS is an XML API endpoint answering every method with a recorded
+// response of testdata, requests other than aaaLogin need the cookie of the
+// login, else the response is XML API error 552
+type mockUCS struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string // method and request body
+}
+
+func newMockUCS(t *testing.T, files map[string]string) *mockUCS {
+	m := &mockUCS{}
+	m.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		method, attrs, err := ucsxml.RequestMethod(body)
+		if err != nil || r.URL.Path != "/nuova" {
+			t.Errorf("invalid request %s %s: %v", r.URL.Path, body, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.mu.Lock()
+		m.requests = append(m.requests, method+" "+string(body))
+		m.mu.Unlock()
+		file, ok := files[method]
+		switch {
+		case !ok:
+			t.Errorf("unexpected request %s", body)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case method != "aaaLogin" && attrs["cookie"] != mockCookie:
+			file = "ucsm-4.1-configResolveClass-552.xml"
+		}
+		w.Write(readTestdata(t, file))
+	}))
+	return m
+}
+
+// methods returns the methods of the requests received
+func (m *mockUCS) methods() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var methods []string
+	for _, r := range m.requests {
+		methods = append(methods, strings.SplitN(r, " ", 2)[0])
+	}
+	return methods
+}
+
+// request returns the body of the first request of the method
+func (m *mockUCS) request(method string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.requests {
+		if strings.HasPrefix(r, method+" ") {
+			return strings.TrimPrefix(r, method+" ")
+		}
+	}
+	return ""
+}
+
+// runMockCheck runs the check of the flags against the mock like a daemon
+// run, a login failure is an UNKNOWN result instead of the exit of the test
+func runMockCheck(t *testing.T, m *mockUCS, flags [][2]string) *checkResult {
+	t.Helper()
+	saved := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		saved[f.Name] = f.Value.String()
+	})
+	defer restoreFlags(saved)
+	flags = append([][2]string{{"H", m.Listener.Addr().String()}, {"M", "1.2"}, {"k", "true"}, {"u", "nagios"}, {"p", "secret"}, {"daemon", "true"}}, flags...)
+	for _, kv := range flags {
+		if err := flag.Set(kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := validateCheckFlags(); err != nil {
+		t.Fatal(err)
+	}
+	errorPerfdata() // counters of the tests before
+	results, _ := collect()
+	return results[0]
+}
+
+// perfdataOf returns the value of a perfdata label
+func perfdataOf(res *checkResult, label string) string {
+	for _, p := range res.Perfdata {
+		if p.Label == label {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+func TestMockUCSClass(t *testing.T) {
+	m := newMockUCS(t, map[string]string{
+		"aaaLogin":           "ucsm-4.1-aaaLogin.xml",
+		"configResolveClass": "ucsm-3.2-configResolveClass-equipmentPsu.xml",
+		"aaaLogout":          "ucsm-4.1-aaaLogin.xml",
+	})
+	defer m.Close()
+
+	res := runMockCheck(t, m, [][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn operState"}, {"e", ",operable$"}})
+	if res.State != 2 || !strings.Contains(res.Output, "sys/chassis-1/psu-4,removed (2 of 3 ok)") {
+		t.Errorf("state %d, output %q, want CRIT with psu-4 removed", res.State, res.Output)
+	}
+	if got := strings.Join(m.methods(), " "); got != "aaaLogin configResolveClass aaaLogout" {
+		t.Errorf("requests %s", got)
+	}
+	req := m.request("configResolveClass")
+	if !strings.Contains(req, `classId="equipmentPsu"`) || !strings.Contains(req, `inHierarchical="false"`) {
+		t.Errorf("configResolveClass request %s", req)
+	}
+	if !strings.Contains(m.request("aaaLogin"), `inName="nagios"`) || !strings.Contains(m.request("aaaLogout"), mockCookie) {
+		t.Errorf("login %s, logout %s", m.request("aaaLogin"), m.request("aaaLogout"))
+	}
+	for _, label := range []string{"errors_auth", "errors_net", "errors_api", "errors_parse"} {
+		if v := perfdataOf(res, label); v != "0" {
+			t.Errorf("%s = %s, want 0", label, v)
+		}
+	}
+}
+
+func TestMockUCSFilter(t *testing.T) {
+	m := newMockUCS(t, map[string]string{
+		"aaaLogin":           "ucsm-4.1-aaaLogin.xml",
+		"configResolveClass": "ucsm-3.2-configResolveClass-equipmentPsu.xml",
+		"aaaLogout":          "ucsm-4.1-aaaLogin.xml",
+	})
+	defer m.Close()
+
+	// the filter is sent to UCS, the mock ignores it
+	runMockCheck(t, m, [][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn operState"}, {"e", ",operable$"}, {"f", "wcard:dn:^sys/switch-A/"}})
+	req := m.request("configResolveClass")
+	if !strings.Contains(req, `<inFilter><wcard class="equipmentPsu" property="dn" value="^sys/switch-A/" /></inFilter>`) {
+		t.Errorf("configResolveClass request %s, want the wcard filter", req)
+	}
+
+	// the filter is applied to the response
+	m.mu.Lock()
+	m.requests = nil
+	m.mu.Unlock()
+	res := runMockCheck(t, m, [][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn operState"}, {"e", ",operable$"}, {"f", "wcard:dn:^sys/switch-A/"}, {"client-filter", "true"}})
+	if req := m.request("configResolveClass"); strings.Contains(req, "inFilter") {
+		t.Errorf("configResolveClass request %s, want no filter", req)
+	}
+	if res.State != 0 || !strings.Contains(res.Output, "(2 of 2 ok)") {
+		t.Errorf("state %d, output %q, want OK with the PSUs of switch-A", res.State, res.Output)
+	}
+}
+
+func TestMockUCSDn(t *testing.T) {
+	m := newMockUCS(t, map[string]string{
+		"aaaLogin":        "ucsm-4.1-aaaLogin.xml",
+		"configResolveDn": "cimc-4.1-configResolveDn-computeRackUnit.xml",
+		"aaaLogout":       "ucsm-4.1-aaaLogin.xml",
+	})
+	defer m.Close()
+
+	res := runMockCheck(t, m, [][2]string{{"t", "dn"}, {"q", "sys/rack-unit-1"}, {"o", "computeRackUnit"}, {"a", "dn model operPower"}, {"e", ",on$"}})
+	if res.State != 0 || !strings.Contains(res.Output, "sys/rack-unit-1,UCSC-C220-M5SX,on (1 of 1 ok)") {
+		t.Errorf("state %d, output %q", res.State, res.Output)
+	}
+	if req := m.request("configResolveDn"); !strings.Contains(req, `dn="sys/rack-unit-1"`) {
+		t.Errorf("configResolveDn request %s", req)
+	}
+}
+
+func TestMockUCSLoginRefused(t *testing.T) {
+	m := newMockUCS(t, map[string]string{
+		"aaaLogin": "ucsm-4.1-aaaLogin-551.xml",
+	})
+	defer m.Close()
+
+	res := runMockCheck(t, m, [][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn operState"}, {"e", ",operable$"}})
+	if res.State != 3 || !strings.Contains(res.Output, "551") {
+		t.Errorf("state %d, output %q, want UNKNOWN with error 551", res.State, res.Output)
+	}
+	if got := strings.Join(m.methods(), " "); got != "aaaLogin" {
+		t.Errorf("requests %s, want the login only", got)
+	}
+	if v := perfdataOf(res, "errors_auth"); v != "1" {
+		t.Errorf("errors_auth = %s, want 1", v)
+	}
+}
+
+func TestMockUCSLogoutError(t *testing.T) {
+	m := newMockUCS(t, map[string]string{
+		"aaaLogin":           "ucsm-4.1-aaaLogin.xml",
+		"configResolveClass": "ucsm-3.2-configResolveClass-equipmentPsu.xml",
+		"aaaLogout":          "ucsm-4.1-configResolveClass-552.xml",
+	})
+	defer m.Close()
+
+	// a failed logout doesn't change the result of the check
+	res := runMockCheck(t, m, [][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn operState"}, {"e", ",operable$"}})
+	if res.State != 2 || !strings.Contains(res.Output, "(2 of 3 ok)") {
+		t.Errorf("state %d, output %q, want CRIT with psu-4 removed", res.State, res.Output)
+	}
+	if got := strings.Join(m.methods(), " "); got != "aaaLogin configResolveClass aaaLogout" {
+		t.Errorf("requests %s", got)
+	}
+}
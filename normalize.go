@@ -0,0 +1,88 @@
+package main
+
+// Normalization of the descr attributes of flag -descr-normalize. UCS Manager
+// with a locale other than English returns localized fault descriptions, with
+// line breaks, commas and characters a Nagios web interface or a log
+// collector mangles:
+//
+//	strip	line breaks, tabs and control characters become spaces, runs of
+//		spaces one space, commas semicolons, so the columns of the output
+//		line stay the same for every locale
+//	ascii	strip and the Latin letters with diacritics transliterated to
+//		ASCII (ä to ae, é to e, ß to ss, ...), typographic quotes and
+//		dashes to their ASCII form, all other characters removed
+//
+// The built-in templates match the fault code, severity and acknowledgement,
+// never the text of descr, see TestTemplatesIgnoreDescr.
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var descrNormalize string
+
+// translit maps the characters of -descr-normalize ascii to ASCII
+var translit = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "Ae", 'Å': "A", 'Æ': "AE", 'Ç': "C",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "Oe", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "Ue", 'Ý': "Y", 'Þ': "Th", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "ae", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ð': "d", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "oe", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "ue", 'ý': "y", 'þ': "th", 'ÿ': "y",
+	'Ą': "A", 'ą': "a", 'Ć': "C", 'ć': "c", 'Č': "C", 'č': "c", 'Ď': "D", 'ď': "d",
+	'Ę': "E", 'ę': "e", 'Ě': "E", 'ě': "e", 'Ł': "L", 'ł': "l", 'Ń': "N", 'ń': "n",
+	'Ň': "N", 'ň': "n", 'Œ': "OE", 'œ': "oe", 'Ř': "R", 'ř': "r", 'Ś': "S", 'ś': "s",
+	'Š': "S", 'š': "s", 'Ť': "T", 'ť': "t", 'Ů': "U", 'ů': "u", 'Ÿ': "Y", 'Ź': "Z",
+	'ź': "z", 'Ż': "Z", 'ż': "z", 'Ž': "Z", 'ž': "z", 'ğ': "g", 'Ğ': "G", 'ı': "i",
+	'İ': "I", 'ş': "s", 'Ş': "S",
+	'‘': "'", '’': "'", '‚': "'", '“': "\"", '”': "\"", '„': "\"", '«': "\"", '»': "\"",
+	'–': "-", '—': "-", '…': "...", '€': "EUR", '°': " deg",
+}
+
+// validateDescrNormalize checks flag -descr-normalize
+func validateDescrNormalize() error {
+	switch descrNormalize {
+	case "", "none", "strip", "ascii":
+		return nil
+	}
+	return fmt.Errorf("flag -descr-normalize: unknown mode %q, expected none, strip or ascii", descrNormalize)
+}
+
+// normalizeText returns s normalized by the mode of -descr-normalize
+func normalizeText(mode, s string) string {
+	if mode != "strip" && mode != "ascii" {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == ',':
+			b.WriteRune(';')
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			b.WriteRune(' ')
+		case mode == "ascii" && r > unicode.MaxASCII:
+			b.WriteString(translit[r])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// normalizeObjects normalizes the descr attributes of the objects
+func normalizeObjects(objects []managedObject) {
+	if descrNormalize != "strip" && descrNormalize != "ascii" {
+		return
+	}
+	for _, obj := range objects {
+		for k, v := range obj.Attrs {
+			if strings.EqualFold(k, "descr") {
+				obj.Attrs[k] = normalizeText(descrNormalize, v)
+			}
+		}
+	}
+}
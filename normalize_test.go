@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	descr := "Stromversorgung 2 in Gehäuse 1,\n Spannung außerhalb – prüfen "
+	for mode, want := range map[string]string{
+		"none":  descr,
+		"strip": "Stromversorgung 2 in Gehäuse 1; Spannung außerhalb – prüfen",
+		"ascii": "Stromversorgung 2 in Gehaeuse 1; Spannung ausserhalb - pruefen",
+	} {
+		if got := normalizeText(mode, descr); got != want {
+			t.Errorf("%s: got %q, want %q", mode, got, want)
+		}
+	}
+	if got := normalizeText("ascii", "电源 PSU 2 故障"); got != "PSU 2" {
+		t.Errorf("ascii of CJK: got %q", got)
+	}
+}
+
+// TestTemplatesIgnoreDescr makes sure that the expect strings of the
+// templates match the code and state of an object, not the text of descr
+func TestTemplatesIgnoreDescr(t *testing.T) {
+	descrs := []string{
+		"Power supply 2 in chassis 1 voltage problem",
+		"Stromversorgung 2 in Gehäuse 1, Spannungsproblem, cleared, yes",
+		"シャーシ 1 の電源 2 に電圧の問題があります",
+		"",
+	}
+	for _, tpl := range templates {
+		attrs := strings.Fields(tpl.value("a"))
+		i := findIndex("descr", attrs)
+		if i < 0 {
+			continue
+		}
+		re := regexp.MustCompile(tpl.value("e"))
+		for _, state := range []string{"major", "cleared", "warning", "critical"} {
+			for _, ack := range []string{"yes", "no"} {
+				values := map[string]string{"code": "F0369", "severity": state, "ack": ack, "dn": "sys/chassis-1/psu-2"}
+				match := -1
+				for _, d := range descrs {
+					line := make([]string, len(attrs))
+					for j, a := range attrs {
+						line[j] = values[a]
+					}
+					line[i] = normalizeText(tpl.value("descr-normalize"), d)
+					m := boolInt(re.MatchString(strings.Join(line, ",")))
+					if match >= 0 && m != match {
+						t.Errorf("template %s: expect string depends on descr %q (severity %s, ack %s)", tpl.name, d, state, ack)
+					}
+					match = m
+				}
+			}
+		}
+	}
+}
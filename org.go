@@ -0,0 +1,54 @@
+package main
+
+// Organization scope of flag -org: a hosting provider gives a tenant checks
+// limited to the objects of its UCS Manager organization (service profiles,
+// policies, pools) and its sub-organizations:
+//
+//	-org org-root/org-tenantA -q lsServer -a "dn operState" -e ,ok$
+//
+// A class query gets the dn filter wcard:dn:^org-root/org-tenantA/, combined
+// with the property filter of -f by and(...), so the objects outside of the
+// organization aren't sent by UCS Manager. With -client-filter only the dn
+// filter is sent. The dn of a query type dn has to be in the organization.
+// The physical objects (sys/...) belong to no organization, a class query of
+// e.g. equipmentPsu finds no objects.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var orgScope string
+
+// orgDn is the dn of an organization, org-root and its sub-organizations
+var orgDn = regexp.MustCompile(`^org-root(/org-[A-Za-z0-9_.:-]+)*$`)
+
+// validateOrg checks flag -org
+func validateOrg() error {
+	if len(orgScope) == 0 {
+		return nil
+	}
+	orgScope = strings.TrimSuffix(orgScope, "/")
+	switch {
+	case !orgDn.MatchString(orgScope):
+		return fmt.Errorf("flag -org: invalid organization %q, example: org-root/org-tenantA", orgScope)
+	case !isXmlBackend():
+		return fmt.Errorf("flag -org needs the XML API (-backend ucs-xml or ucs-central)")
+	case len(crawl) > 0 || len(chunkBy) > 0:
+		return fmt.Errorf("flag -org can't be combined with -crawl and -chunk-by, they query the physical objects")
+	case queryType == "dn" && !inOrg(dnOrClass):
+		return fmt.Errorf("flag -org: dn %s is outside of the organization %s", dnOrClass, orgScope)
+	}
+	return nil
+}
+
+// inOrg returns true if the dn is the organization of -org or one of its objects
+func inOrg(dn string) bool {
+	return dn == orgScope || strings.HasPrefix(dn, orgScope+"/")
+}
+
+// orgFilter returns the dn filter of -org
+func orgFilter() string {
+	return "wcard:dn:^" + regexp.QuoteMeta(orgScope) + "/"
+}
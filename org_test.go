@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+func TestOrgFilter(t *testing.T) {
+	defer func(o, f string, c bool) { orgScope, propertyFilter, clientFilter = o, f, c }(orgScope, propertyFilter, clientFilter)
+	orgScope = "org-root/org-tenant.A/"
+	if err := validateOrg(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		filter string
+		client bool
+		want   string
+	}{
+		{"", false, `<inFilter><wcard class="lsServer" property="dn" value="^org-root/org-tenant\.A/" /></inFilter>`},
+		{"eq:operState:ok", false, `<inFilter><and><wcard class="lsServer" property="dn" value="^org-root/org-tenant\.A/" /><eq class="lsServer" property="operState" value="ok" /></and></inFilter>`},
+		{"eq:operState:ok", true, `<inFilter><wcard class="lsServer" property="dn" value="^org-root/org-tenant\.A/" /></inFilter>`},
+	} {
+		propertyFilter, clientFilter = tt.filter, tt.client
+		if err := validatePropertyFilter(); err != nil {
+			t.Fatal(err)
+		}
+		req, err := ucsxml.BuildRequest(&ucsxml.ConfigResolveClass{Cookie: "1/abc", InHierarchical: "false", ClassId: "lsServer", InFilter: newInFilter("lsServer")})
+		if err != nil || !strings.Contains(string(req), tt.want) {
+			t.Errorf("-f %q -client-filter=%v: %s, %v, want %s", tt.filter, tt.client, req, err, tt.want)
+		}
+	}
+}
+
+func TestValidateOrg(t *testing.T) {
+	defer func(o, q, d string) { orgScope, queryType, dnOrClass = o, q, d }(orgScope, queryType, dnOrClass)
+	for _, tt := range []struct {
+		org, queryType, dn string
+		ok                 bool
+	}{
+		{"org-root", "class", "lsServer", true},
+		{"org-root/org-a/org-b", "dn", "org-root/org-a/org-b/ls-esx01", true},
+		{"org-root/org-a", "dn", "org-root/org-a", true},
+		{"org-root/org-a", "dn", "org-root/org-ab/ls-esx01", false},
+		{"org-root/org-a", "dn", "sys/rack-unit-1", false},
+		{"tenantA", "class", "lsServer", false},
+		{"org-root/ls-esx01", "class", "lsServer", false},
+	} {
+		orgScope, queryType, dnOrClass = tt.org, tt.queryType, tt.dn
+		if err := validateOrg(); (err == nil) != tt.ok {
+			t.Errorf("-org %s -t %s -q %s: %v", tt.org, tt.queryType, tt.dn, err)
+		}
+	}
+}
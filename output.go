@@ -0,0 +1,370 @@
+package main
+
+// Output formats of flag -output. The checks return a checkResult, the
+// renderer turns the results of a run into the plugin output, so new formats
+// can be added without touching the evaluation.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	checkObject struct {
+		Dn    string            `json:"dn"`
+		Line  string            `json:"line"` // comma separated attribute values
+		Attrs map[string]string `json:"attributes"`
+		Ok    bool              `json:"ok"`
+		State int               `json:"state"` // CRIT: expect string not matched, else the state of the thresholds
+		New   bool              `json:"new,omitempty"`
+	}
+
+	checkResult struct {
+		Label      string          `json:"label"` // UCS domain
+		Profile    string          `json:"profile,omitempty"`
+		Name       string          `json:"name"` // class or dn of the query
+		Attributes []string        `json:"attributes"`
+		State      int             `json:"state"`
+		Status     string          `json:"status"` // OK, WARN, CRIT or UNKNOWN
+		Output     string          `json:"output"` // nagios plugin output without status
+		Objects    []checkObject   `json:"objects"`
+		Perfdata   []perfValue     `json:"perfdata,omitempty"`
+		Session    *sessionDetails `json:"session,omitempty"` // flag -show-session
+		NumOk      int             `json:"numOk"`
+		Num        int             `json:"num"`
+	}
+
+	OutputRenderer interface {
+		Render(w io.Writer, results []*checkResult) error
+	}
+
+	nagiosRenderer     struct{}
+	jsonRenderer       struct{}
+	csvRenderer        struct{}
+	zabbixRenderer     struct{}
+	checkmkRenderer    struct{}
+	tableRenderer      struct{}
+	influxRenderer     struct{}
+	prometheusRenderer struct{}
+)
+
+var renderers = map[string]OutputRenderer{
+	"nagios":     nagiosRenderer{},
+	"json":       jsonRenderer{},
+	"csv":        csvRenderer{},
+	"zabbix":     zabbixRenderer{},
+	"checkmk":    checkmkRenderer{},
+	"table":      tableRenderer{},
+	"influx":     influxRenderer{},
+	"prometheus": prometheusRenderer{},
+}
+
+func rendererNames() string {
+	var names []string
+	for n := range renderers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// newResult returns the result of the current check flags, state UNKNOWN
+func newResult() *checkResult {
+	return &checkResult{
+		Label:      resultLabel(),
+		Name:       dnOrClass,
+		Attributes: resultAttributes(),
+		Objects:    []checkObject{},
+		State:      3,
+		Status:     statePrefix[3],
+	}
+}
+
+// resultAttributes returns the attributes of the objects: -a, -join and -derive
+func resultAttributes() []string {
+	attrs := append(append(strings.Split(attributes, " "), joinAttrs()...), hostFirmwareAttrs()...)
+	attrs = append(append(append(attrs, hfpAttrs()...), biosPolicyAttrs()...), bootOrderAttrs()...)
+	attrs = append(append(attrs, layoutAttrs()...), hotSpareAttrs()...)
+	attrs = append(append(attrs, derived.names()...), imbalanceAttrs()...)
+	attrs = append(attrs, consistencyAttrs()...)
+	return append(attrs, probeAttrs()...)
+}
+
+// resultLabel returns the name of the UCS domain, flag -label or the host
+func resultLabel() string {
+	if len(label) > 0 {
+		return label
+	}
+	return hostName()
+}
+
+// statusLine returns the status line prefix of the nagios output
+func statusLine(status string) string {
+	if len(label) > 0 {
+		return status + " - " + label + ":"
+	}
+	return status + " -"
+}
+
+// unknown sets state UNKNOWN with the given output
+func (res *checkResult) unknown(output string) *checkResult {
+	res.State, res.Status, res.Output = 3, statePrefix[3], output
+	return res
+}
+
+// exitUnknown ends a run that failed before a check result, e.g. the login:
+// the plugin output is UNKNOWN and the message, the other formats (-output
+// json, ...) get an UNKNOWN result, so automation never has to parse text.
+// Errors of the connection, login, API and parsing are always UNKNOWN, only
+// the objects of a check (expect string, thresholds, ...) are WARN or CRIT.
+func exitUnknown(msg string) {
+	if daemonMode {
+		panic(daemonAbort{msg: msg})
+	}
+	if r, ok := renderers[outputFormat]; ok && outputFormat != "nagios" {
+		res := newResult().unknown(msg)
+		res.Perfdata = errorPerfdata()
+		r.Render(os.Stdout, []*checkResult{res})
+	} else {
+		fmt.Print(pluginOutput(statePrefix[3]+" - "+msg, errorPerfdata()))
+	}
+	os.Exit(3)
+}
+
+// parseFlags parses the flags of the plugin or a subcommand, -h prints the
+// usage, an invalid or unknown flag is UNKNOWN in the format of -output (also
+// if -output follows the invalid flag) instead of the usage with exit code 2
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	fs.SetOutput(nil)
+	switch {
+	case err == flag.ErrHelp:
+		fs.Usage()
+		os.Exit(0)
+	case err != nil:
+		if format := outputArg(args); len(format) > 0 {
+			outputFormat = format
+		}
+		daemonMode = false // -daemon before the invalid flag, nothing runs yet
+		exitUnknown(err.Error())
+	}
+}
+
+// outputArg returns the value of -output in the arguments, empty without
+func outputArg(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		switch {
+		case arg == "--":
+			return ""
+		case len(name) == len(arg):
+			continue
+		case strings.HasPrefix(name, "output="):
+			return strings.TrimPrefix(name, "output=")
+		case name == "output" && i+1 < len(args):
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// worstResult returns the exit code of a run
+func worstResult(results []*checkResult) int {
+	worst := 0
+	for _, res := range results {
+		worst = worstState(worst, res.State)
+	}
+	return worst
+}
+
+// checkName identifies a check in the machine readable formats
+func (res *checkResult) checkName() string {
+	if len(res.Profile) > 0 {
+		return res.Profile
+	}
+	return res.Name
+}
+
+// Render prints the classic plugin output, in profile mode a summary line and
+// one line per profile, in batch mode one line per target
+func (nagiosRenderer) Render(w io.Writer, results []*checkResult) error {
+	if len(results) == 1 && len(results[0].Profile) == 0 && !isBatch() {
+		_, err := fmt.Fprint(w, pluginOutput(statusLine(results[0].Status)+" "+results[0].Output, results[0].Perfdata))
+		return err
+	}
+	kind := "profiles"
+	if isBatch() {
+		kind = "targets"
+	}
+	lines := ""
+	numOk := 0
+	var perf []perfValue
+	for _, res := range results {
+		if res.State == 0 {
+			numOk++
+		}
+		name := res.Profile
+		if isBatch() {
+			name = res.Label
+		}
+		lines += "\n[" + name + "] " + res.Status + " - " + res.Output
+		for _, p := range res.Perfdata {
+			p.Label = name + ":" + p.Label
+			perf = append(perf, p)
+		}
+	}
+	text := fmt.Sprintf("%s Cisco UCS %s (%d of %d ok)%s", statusLine(statePrefix[worstResult(results)]), kind, numOk, len(results), lines)
+	_, err := fmt.Fprint(w, pluginOutput(text, perf))
+	return err
+}
+
+func (jsonRenderer) Render(w io.Writer, results []*checkResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if len(results) == 1 && len(results[0].Profile) == 0 && !isBatch() {
+		return enc.Encode(results[0])
+	}
+	return enc.Encode(results)
+}
+
+// Render prints one CSV row per object with a column per attribute, in
+// profile mode the columns of all profiles
+func (csvRenderer) Render(w io.Writer, results []*checkResult) error {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, res := range results {
+		for _, a := range res.Attributes {
+			if !seen[a] {
+				seen[a] = true
+				columns = append(columns, a)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"check", "status", "dn", "ok", "new"}, columns...))
+	for _, res := range results {
+		for _, obj := range res.Objects {
+			row := []string{res.checkName(), res.Status, obj.Dn, strconv.FormatBool(obj.Ok), strconv.FormatBool(obj.New)}
+			for _, a := range columns {
+				row = append(row, obj.Attrs[a])
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Render prints zabbix_sender input: <host> <key> <value>
+func (zabbixRenderer) Render(w io.Writer, results []*checkResult) error {
+	for _, res := range results {
+		host := zabbixParam(res.Label)
+		name := zabbixParam(res.checkName())
+		fmt.Fprintf(w, "%s ucs.check.state[%s] %d\n", host, name, res.State)
+		fmt.Fprintf(w, "%s ucs.check.ok[%s] %d\n", host, name, res.NumOk)
+		fmt.Fprintf(w, "%s ucs.check.total[%s] %d\n", host, name, res.Num)
+		for _, obj := range res.Objects {
+			fmt.Fprintf(w, "%s ucs.object.ok[%s,%s] %d\n", host, name, zabbixParam(obj.Dn), boolInt(obj.Ok))
+		}
+	}
+	return nil
+}
+
+func zabbixParam(s string) string {
+	if strings.ContainsAny(s, ",[]\" ") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Render prints checkmk local check lines: <state> "<service>" - <text>
+func (checkmkRenderer) Render(w io.Writer, results []*checkResult) error {
+	for _, res := range results {
+		output := strings.Replace(res.Output, "\n", "\\n", -1)
+		service := "Cisco UCS " + res.checkName()
+		if len(label) > 0 {
+			service = "Cisco UCS " + label + " " + res.checkName()
+		}
+		_, err := fmt.Fprintf(w, "%d \"%s\" - %s\n", res.State, service, output)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render prints InfluxDB line protocol
+func (influxRenderer) Render(w io.Writer, results []*checkResult) error {
+	host := influxTag(hostName())
+	for _, res := range results {
+		name := influxTag(res.checkName())
+		tags := "host=" + host + ",label=" + influxTag(res.Label) + ",check=" + name
+		fmt.Fprintf(w, "ucs_check,%s state=%di,ok=%di,total=%di\n", tags, res.State, res.NumOk, res.Num)
+		for _, obj := range res.Objects {
+			fields := fmt.Sprintf("ok=%t,new=%t", obj.Ok, obj.New)
+			for _, a := range res.Attributes {
+				if v, ok := obj.Attrs[a]; ok {
+					fields += "," + influxTag(a) + "=" + strconv.Quote(v)
+				}
+			}
+			fmt.Fprintf(w, "ucs_object,%s,dn=%s %s\n", tags, influxTag(obj.Dn), fields)
+		}
+	}
+	return nil
+}
+
+func influxTag(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ").Replace(s)
+}
+
+// Render prints the Prometheus text format, e.g. for the textfile collector
+func (prometheusRenderer) Render(w io.Writer, results []*checkResult) error {
+	fmt.Fprintf(w, "# HELP ucs_check_state plugin state, 0 OK, 1 WARN, 2 CRIT, 3 UNKNOWN\n# TYPE ucs_check_state gauge\n")
+	for _, res := range results {
+		fmt.Fprintf(w, "ucs_check_state{host=%q,label=%q,check=%q} %d\n", hostName(), res.Label, res.checkName(), res.State)
+	}
+	fmt.Fprintf(w, "# HELP ucs_object_ok 1 if the object matches the expect string\n# TYPE ucs_object_ok gauge\n")
+	for _, res := range results {
+		for _, obj := range res.Objects {
+			fmt.Fprintf(w, "ucs_object_ok{host=%q,label=%q,check=%q,dn=%q} %d\n", hostName(), res.Label, res.checkName(), obj.Dn, boolInt(obj.Ok))
+		}
+	}
+	fmt.Fprintf(w, "# HELP ucs_object_value numeric attribute of the object\n# TYPE ucs_object_value gauge\n")
+	for _, res := range results {
+		for _, obj := range res.Objects {
+			for _, a := range res.Attributes {
+				if v, err := strconv.ParseFloat(obj.Attrs[a], 64); err == nil {
+					fmt.Fprintf(w, "ucs_object_value{host=%q,label=%q,check=%q,dn=%q,attribute=%q} %g\n", hostName(), res.Label, res.checkName(), obj.Dn, a, v)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(w, "# HELP ucs_perfdata performance data of the check\n# TYPE ucs_perfdata gauge\n")
+	for _, res := range results {
+		for _, p := range res.Perfdata {
+			if v, err := strconv.ParseFloat(p.Value, 64); err == nil {
+				fmt.Fprintf(w, "ucs_perfdata{host=%q,label=%q,check=%q,name=%q,unit=%q} %g\n", hostName(), res.Label, res.checkName(), p.Label, p.Unit, v)
+			}
+		}
+	}
+	return nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,190 @@
+package main
+
+// Performance data of flag -perfdata: the numeric values of the given
+// attributes (queried or derived) of every object with the thresholds of -w
+// and -c, labeled <dn>:<attribute>, with -label <label>:<dn>:<attribute>.
+//
+//	-a "dn outputPower inputPower" -derive "efficiency=outputPower/inputPower*100" -perfdata "outputPower efficiency"
+//
+// Flag -perfdata-label sets the label template, short labels built from the
+// dn components are easier to graph than full dns:
+//
+//	{dn}	dn of the object, sys/chassis-3/psu-2
+//	{rn}	last component of the dn, psu-2
+//	{path}	components of the dn without sys and dashes joined by _, chassis3_psu2
+//	{1}, {2}, ...	component of the dn without dashes, {1}: sys, {2}: chassis3
+//	{attr}	attribute
+//	{label}	flag -label
+//
+//	-perfdata-label "{path}_{attr}"	->	chassis3_psu2_outputPower
+//
+// Labels of several objects which come out equal are made unique with the
+// suffix _2, _3, ... in the order of the objects.
+//
+// Values with a size unit like the size of a virtual drive (285148 MB) or its
+// strip size (64k) are reported with the unit of measurement, 285148MB, 64KB.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type perfValue struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+	Unit  string `json:"unit,omitempty"`
+	Warn  string `json:"warn,omitempty"`
+	Crit  string `json:"crit,omitempty"`
+}
+
+var (
+	perfdataAttrs         string
+	perfdataLabelTemplate string
+)
+
+var perfdataPlaceholder = regexp.MustCompile(`\{([a-z]+|[0-9]+)\}`)
+
+// perfdataUnit matches a value with a unit, perfdataUnits are the units of measurement
+var (
+	perfdataUnit  = regexp.MustCompile(`^(-?[0-9.]+) ?([A-Za-z%]+)$`)
+	perfdataUnits = map[string]string{"b": "B", "k": "KB", "kb": "KB", "m": "MB", "mb": "MB", "g": "GB", "gb": "GB", "t": "TB", "tb": "TB", "%": "%"}
+)
+
+// perfdataValue returns the number and the unit of measurement of a value
+func perfdataValue(s string) (string, string, bool) {
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s, "", true
+	}
+	m := perfdataUnit.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", false
+	}
+	if _, err := strconv.ParseFloat(m[1], 64); err != nil {
+		return "", "", false
+	}
+	unit, ok := perfdataUnits[strings.ToLower(m[2])]
+	return m[1], unit, ok
+}
+
+// String returns the value in the plugin perfdata format 'label'=value;warn;crit
+func (p perfValue) String() string {
+	s := "'" + strings.Replace(p.Label, "'", "''", -1) + "'=" + p.Value + p.Unit
+	if len(p.Warn) > 0 || len(p.Crit) > 0 {
+		s += ";" + p.Warn + ";" + p.Crit
+	}
+	return s
+}
+
+func perfdataLabel(dn, attr string) string {
+	if len(perfdataLabelTemplate) > 0 {
+		return expandPerfdataLabel(perfdataLabelTemplate, dn, attr)
+	}
+	l := attr
+	if len(dn) > 0 {
+		l = dn + ":" + attr
+	}
+	if len(label) > 0 {
+		l = label + ":" + l
+	}
+	return l
+}
+
+// expandPerfdataLabel replaces the placeholders of the label template,
+// unknown placeholders are kept
+func expandPerfdataLabel(template, dn, attr string) string {
+	var components []string
+	for _, c := range strings.Split(dn, "/") {
+		components = append(components, strings.Replace(c, "-", "", -1))
+	}
+	return perfdataPlaceholder.ReplaceAllStringFunc(template, func(p string) string {
+		name := p[1 : len(p)-1]
+		switch name {
+		case "dn":
+			return dn
+		case "rn":
+			return dn[strings.LastIndex(dn, "/")+1:]
+		case "path":
+			path := components
+			if len(path) > 1 && path[0] == "sys" {
+				path = path[1:]
+			}
+			return strings.Join(path, "_")
+		case "attr":
+			return attr
+		case "label":
+			return label
+		}
+		if i, err := strconv.Atoi(name); err == nil && i >= 1 && i <= len(components) {
+			return components[i-1]
+		}
+		return p
+	})
+}
+
+// uniquePerfdataLabels adds the suffix _2, _3, ... to repeated labels
+func uniquePerfdataLabels(perf []perfValue) {
+	seen := make(map[string]int)
+	for i := range perf {
+		l := perf[i].Label
+		seen[l]++
+		if seen[l] > 1 {
+			perf[i].Label = fmt.Sprintf("%s_%d", l, seen[l])
+			debugPrintf(2, "perfdata label %s repeated, renamed to %s\n", l, perf[i].Label)
+		}
+	}
+}
+
+// validatePerfdataLabel checks the placeholders of -perfdata-label
+func validatePerfdataLabel() error {
+	for _, p := range perfdataPlaceholder.FindAllStringSubmatch(perfdataLabelTemplate, -1) {
+		switch p[1] {
+		case "dn", "rn", "path", "attr", "label":
+			continue
+		}
+		if _, err := strconv.Atoi(p[1]); err != nil {
+			return fmt.Errorf("flag -perfdata-label: unknown placeholder %s, expected {dn}, {rn}, {path}, {1}, {2}, ..., {attr} or {label}", p[0])
+		}
+	}
+	if len(perfdataLabelTemplate) > 0 && !strings.Contains(perfdataLabelTemplate, "{attr}") && len(strings.Fields(perfdataAttrs)) > 1 {
+		return fmt.Errorf("flag -perfdata-label needs {attr} with several perfdata attributes")
+	}
+	return nil
+}
+
+// objectPerfdata returns the perfdata of the numeric attributes of -perfdata
+func objectPerfdata(obj managedObject) []perfValue {
+	var perf []perfValue
+	for _, attr := range strings.Fields(perfdataAttrs) {
+		s, ok := obj.Attrs[attr]
+		if !ok {
+			continue
+		}
+		v, unit, ok := perfdataValue(s)
+		if !ok {
+			continue
+		}
+		p := perfValue{Label: perfdataLabel(obj.Dn, attr), Value: v, Unit: unit}
+		if w, ok := warnThresholds.limit(attr); ok {
+			p.Warn = w.String()
+		}
+		if c, ok := critThresholds.limit(attr); ok {
+			p.Crit = c.String()
+		}
+		perf = append(perf, p)
+	}
+	return perf
+}
+
+// perfdataString returns the perfdata part of the status line, empty without perfdata
+func perfdataString(perf []perfValue) string {
+	if len(perf) == 0 {
+		return ""
+	}
+	var s []string
+	for _, p := range perf {
+		s = append(s, p.String())
+	}
+	return " | " + strings.Join(s, " ")
+}
@@ -0,0 +1,102 @@
+package main
+
+// Privilege errors of restricted monitoring accounts. A role without the
+// privilege of a class gets an XML API error about the missing privilege or,
+// depending on the firmware, an empty response, which showed up as a
+// misleading "0 of 0 ok" (or OK with -z). Both are reported as UNKNOWN:
+//
+//	UNKNOWN - Cisco UCS faultInst (code,severity): insufficient privileges for class faultInst (need role operations or read-only)
+//
+// An error is a privilege error if its code or description contains one of
+// privilegeMarkers. An empty response is one if the privileges of the session
+// (outPriv of aaaLogin, see -show-session) include neither admin, read-only
+// (or user of CIMC) nor the privilege of the class in classPrivileges. The privileges and roles
+// are the ones of UCS Manager, CIMC has only admin, user and read-only.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// privilegeMarkers are the parts of the XML API errors of missing privileges
+var privilegeMarkers = []string{"privilege", "permission", "not authorized", "access denied", "access-denied"}
+
+// readPrivileges are the privileges reading all classes, user of CIMC
+var readPrivileges = []string{"admin", "read-only", "user"}
+
+// classPrivileges are the privilege and the role of UCS Manager having it
+// by prefix of the class
+var classPrivileges = []struct {
+	prefix, priv, role string
+}{
+	{"fault", "fault", "operations"},
+	{"event", "fault", "operations"},
+	{"aaa", "aaa", "aaa"},
+	{"ls", "ls-server", "server-profile"},
+	{"vnic", "ls-network", "network"},
+	{"ether", "ext-lan-config", "network"},
+	{"fabric", "ext-lan-config", "network"},
+	{"sw", "ext-lan-config", "network"},
+	{"fc", "ext-san-config", "storage"},
+	{"equipment", "pn-equipment", "server-equipment"},
+	{"compute", "pn-equipment", "server-equipment"},
+	{"storage", "pn-equipment", "server-equipment"},
+	{"processor", "pn-equipment", "server-equipment"},
+	{"memory", "pn-equipment", "server-equipment"},
+	{"adaptor", "pn-equipment", "server-equipment"},
+	{"firmware", "pn-maintenance", "server-equipment"},
+	{"power", "power-mgmt", "facility-manager"},
+}
+
+// classPrivilege returns the privilege and role of a class, empty for the
+// classes only readable with read-only
+func classPrivilege(class string) (priv, role string) {
+	for _, p := range classPrivileges {
+		if strings.HasPrefix(class, p.prefix) {
+			return p.priv, p.role
+		}
+	}
+	return "", ""
+}
+
+// privilegeMessage returns the message of missing privileges for a class
+func privilegeMessage(class string) string {
+	if _, role := classPrivilege(class); len(role) > 0 {
+		return fmt.Sprintf("insufficient privileges for class %s (need role %s or read-only)", class, role)
+	}
+	return fmt.Sprintf("insufficient privileges for class %s (need role read-only)", class)
+}
+
+// privilegeError returns the error of a query as privilege error, other
+// errors unchanged
+func privilegeError(err error, class string) error {
+	var apiErr *ucsxml.APIError
+	if !errors.As(err, &apiErr) || ucsxml.ErrorClass(err) == ucsxml.ErrAuth {
+		return err
+	}
+	s := strings.ToLower(apiErr.Code + " " + apiErr.Descr)
+	for _, m := range privilegeMarkers {
+		if strings.Contains(s, m) {
+			return fmt.Errorf("%s: %v", privilegeMessage(class), err)
+		}
+	}
+	return err
+}
+
+// missingPrivileges returns the message of missing privileges if the session
+// can't read the class, empty if it can or the privileges aren't known
+func missingPrivileges(session *sessionDetails, class string) string {
+	if session == nil || len(session.Priv) == 0 || len(class) == 0 {
+		return ""
+	}
+	priv, _ := classPrivilege(class)
+	for _, p := range session.Priv {
+		if p == priv || findIndex(p, readPrivileges) >= 0 {
+			return ""
+		}
+	}
+	return privilegeMessage(class)
+}
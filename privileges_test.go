@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+func TestPrivilegeError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{&ucsxml.APIError{Method: "configResolveClass", Code: "122", Descr: "Insufficient privileges"},
+			"insufficient privileges for class faultInst (need role operations or read-only): configResolveClass error 122: Insufficient privileges"},
+		{&ucsxml.APIError{Method: "configResolveClass", Code: "ERR-Access-Denied", Descr: "operation not permitted"},
+			"insufficient privileges for class faultInst (need role operations or read-only): configResolveClass error ERR-Access-Denied: operation not permitted"},
+		// refused session, not a privilege
+		{&ucsxml.APIError{Method: "configResolveClass", Code: "552", Descr: "Authorization required"},
+			"configResolveClass error 552: Authorization required"},
+		{&ucsxml.APIError{Method: "configResolveClass", Code: "103", Descr: "class not found"},
+			"configResolveClass error 103: class not found"},
+		{errors.New("permission denied"), "permission denied"},
+	} {
+		if got := privilegeError(tt.err, "faultInst").Error(); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestMissingPrivileges(t *testing.T) {
+	for _, tt := range []struct {
+		priv  []string
+		class string
+		want  string
+	}{
+		{[]string{"read-only"}, "faultInst", ""},
+		{[]string{"admin"}, "aaaUser", ""},
+		{[]string{"fault", "operations"}, "faultInst", ""},
+		{[]string{"pn-equipment"}, "equipmentPsu", ""},
+		{nil, "faultInst", ""}, // unknown privileges
+		{[]string{"pn-equipment"}, "faultInst", "insufficient privileges for class faultInst (need role operations or read-only)"},
+		{[]string{"fault"}, "lsServer", "insufficient privileges for class lsServer (need role server-profile or read-only)"},
+		{[]string{"fault"}, "topSystem", "insufficient privileges for class topSystem (need role read-only)"},
+	} {
+		if got := missingPrivileges(&sessionDetails{Priv: tt.priv}, tt.class); got != tt.want {
+			t.Errorf("%v %s: got %q, want %q", tt.priv, tt.class, got, tt.want)
+		}
+	}
+	if got := missingPrivileges(nil, "faultInst"); got != "" {
+		t.Errorf("without session: %q", got)
+	}
+}
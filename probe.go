@@ -0,0 +1,120 @@
+package main
+
+// Reachability probe of flag -probe <attribute>[:<port>]: the address in an
+// attribute of every object is probed with a TCP connection (default port
+// 443) and the result is added as attribute "reachable" (yes or no) after the
+// attributes of -a, so the expect string can require it. Example: the
+// management interfaces of both fabric interconnects (template fi-mgmt), a
+// dead management port of one fabric interconnect silently removes the high
+// availability of the management plane.
+//
+//	-q mgmtIf -a "dn extIp operState" -probe extIp -e ",up,yes$" -require "1 of 2"
+//
+// The port of -probe applies to plain addresses, the address may also be a
+// share or a URL with the port of its protocol, e.g. the remote share of a
+// vMedia mapping (template vmedia-cimc): //host/share (CIFS, 445),
+// host:/path (NFS, 2049), http://host/path, https://host:8443/path.
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// schemePorts are the ports of the protocols of URL addresses
+var schemePorts = map[string]string{"http": "80", "https": "443", "nfs": "2049", "cifs": "445", "smb": "445"}
+
+// probeAttr is the attribute added by -probe
+const probeAttr = "reachable"
+
+var probeSpec string
+
+// parseProbe returns the attribute and the port of -probe
+func parseProbe() (string, string, error) {
+	attr, port := probeSpec, "443"
+	if i := strings.LastIndex(probeSpec, ":"); i >= 0 {
+		attr, port = probeSpec[:i], probeSpec[i+1:]
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", "", fmt.Errorf("flag -probe: invalid port %q, expected <attribute>[:<port>]", port)
+		}
+	}
+	if len(attr) == 0 {
+		return "", "", fmt.Errorf("flag -probe: attribute missing, expected <attribute>[:<port>]")
+	}
+	return attr, port, nil
+}
+
+// probeAddress returns the host and port to probe of an attribute value,
+// port is the port of plain addresses
+func probeAddress(value, port string) (string, string) {
+	switch {
+	case strings.Contains(value, "://"):
+		u, err := url.Parse(value)
+		if err != nil {
+			return "", ""
+		}
+		if len(u.Port()) > 0 {
+			return u.Hostname(), u.Port()
+		}
+		if p, ok := schemePorts[strings.ToLower(u.Scheme)]; ok {
+			port = p
+		}
+		return u.Hostname(), port
+	case strings.HasPrefix(value, "//") || strings.HasPrefix(value, `\\`):
+		host := strings.FieldsFunc(value, func(r rune) bool { return r == '/' || r == '\\' })
+		if len(host) == 0 {
+			return "", ""
+		}
+		return host[0], "445"
+	case strings.Contains(value, ":/"):
+		return value[:strings.Index(value, ":/")], "2049"
+	}
+	return value, port
+}
+
+// probeAttrs returns the attribute added by -probe
+func probeAttrs() []string {
+	if len(probeSpec) == 0 {
+		return nil
+	}
+	return []string{probeAttr}
+}
+
+// probeObjects probes the addresses of the objects, -parallel at the same time
+func probeObjects(objects []managedObject) {
+	if len(probeSpec) == 0 {
+		return
+	}
+	attr, port, _ := parseProbe()
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range objects {
+		obj := &objects[i]
+		obj.Keys = append(append([]string{}, obj.Keys...), probeAttr)
+		host, port := probeAddress(obj.Attrs[attr], port)
+		if len(host) == 0 || host == "0.0.0.0" {
+			obj.Attrs[probeAttr] = "no"
+			continue
+		}
+		addr := net.JoinHostPort(host, port)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(addr string, attrs map[string]string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result := "yes"
+			conn, err := net.DialTimeout("tcp", addr, failoverTimeout)
+			if err != nil {
+				debugPrintf(1, "probe %s: %v\n", addr, err)
+				result = "no"
+			} else {
+				conn.Close()
+			}
+			attrs[probeAttr] = result
+		}(addr, obj.Attrs)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestProbeAddress(t *testing.T) {
+	tests := []struct {
+		value, host, port string
+	}{
+		{"10.1.1.5", "10.1.1.5", "443"},
+		{"//10.1.1.5/iso", "10.1.1.5", "445"},
+		{`\\fileserver\iso`, "fileserver", "445"},
+		{"10.1.1.5:/export/iso", "10.1.1.5", "2049"},
+		{"http://repo.example.com/iso", "repo.example.com", "80"},
+		{"https://repo.example.com:8443/iso", "repo.example.com", "8443"},
+		{"", "", "443"},
+	}
+	for _, tt := range tests {
+		if host, port := probeAddress(tt.value, "443"); host != tt.host || port != tt.port {
+			t.Errorf("%q: %s %s, want %s %s", tt.value, host, port, tt.host, tt.port)
+		}
+	}
+}
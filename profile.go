@@ -0,0 +1,306 @@
+package main
+
+// Profile mode: several checks defined in a config file are run with a single
+// login. A profile may depend on other profiles, if one of them is not OK the
+// profile is skipped and reported as DEPENDENT with state OK. This avoids
+// alert storms, e.g. one failed controller producing an alert for every disk.
+// The profiles depending on a skipped profile are skipped as well.
+//
+// config file format, keys are the check flags without dash:
+//
+//	# storage controller and disks of a rack server
+//	[controller]
+//	q = storageController
+//	a = id health
+//	e = Good
+//
+//	[disks]
+//	q = storageLocalDisk
+//	a = "id pdStatus"
+//	e = Online
+//	depends = controller
+//
+//	# built-in template, see templates.go
+//	[psu]
+//	check = psu
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// profileSkipped is the state of a profile skipped as DEPENDENT, its
+// dependents are skipped too
+const profileSkipped = -1
+
+type profile struct {
+	name    string
+	flags   [][2]string
+	depends []string
+}
+
+// profileFlags are the flags which can be set per profile, all other flags
+// (host, credentials, TLS, ...) apply to the whole run.
+var profileFlags = map[string]bool{
+	"t": true, "q": true, "o": true, "s": true, "a": true, "e": true,
+	"z": true, "F": true, "f": true, "org": true, "require": true, "warn-failed": true, "crit-failed": true, "suppress-if": true,
+	"alert-only-new": true, "auto-ack": true, "collect-techsupport-on-crit": true,
+	"validate": true, "ignore-attr-case": true, "crawl": true, "chunk-by": true, "check": true,
+	"soft-during-upgrade": true, "w": true, "c": true, "crit-sev": true, "warn-sev": true, "descr-normalize": true, "sla": true, "hysteresis": true,
+	"join": true, "derive": true, "host-firmware": true, "hfp": true, "expect-file": true, "boot-order": true, "layout-file": true, "hot-spares": true, "imbalance": true, "consistent": true, "probe": true, "perfdata": true, "perfdata-label": true, "samples": true, "sample-interval": true, "sample-aggregate": true,
+	"max-instances": true, "sample": true, "limit": true, "rotate-group": true,
+}
+
+func parseProfiles(filename string) ([]*profile, error) {
+	f, err := openConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		profiles []*profile
+		p        *profile
+	)
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if len(name) == 0 || names[name] {
+				return nil, fmt.Errorf("%s:%d: empty or duplicate profile name %q", filename, lineNo, name)
+			}
+			names[name] = true
+			p = &profile{name: name}
+			profiles = append(profiles, p)
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || p == nil {
+			return nil, fmt.Errorf("%s:%d: expected \"<key> = <value>\" inside a [profile] section", filename, lineNo)
+		}
+		key := strings.TrimSpace(kv[0])
+		value := strings.Trim(strings.TrimSpace(kv[1]), "\"")
+		switch {
+		case key == "depends":
+			for _, d := range strings.Split(value, ",") {
+				if d = strings.TrimSpace(d); len(d) > 0 {
+					p.depends = append(p.depends, d)
+				}
+			}
+		case profileFlags[key]:
+			p.flags = append(p.flags, [2]string{key, value})
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown or global flag %q in profile %s", filename, lineNo, key, p.name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range profiles {
+		for _, d := range p.depends {
+			if !names[d] {
+				return nil, fmt.Errorf("profile %s depends on unknown profile %s", p.name, d)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// orderProfiles returns the selected profiles and all their dependencies,
+// dependencies first.
+func orderProfiles(profiles []*profile, selected []string) ([]*profile, error) {
+	byName := make(map[string]*profile)
+	for _, p := range profiles {
+		byName[p.name] = p
+	}
+	if len(selected) == 0 {
+		for _, p := range profiles {
+			selected = append(selected, p.name)
+		}
+	}
+
+	var (
+		ordered []*profile
+		visit   func(name string, path []string) error
+	)
+	done := make(map[string]bool)
+	visit = func(name string, path []string) error {
+		p, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("unknown profile %s", name)
+		}
+		if done[name] {
+			return nil
+		}
+		for _, v := range path {
+			if v == name {
+				return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+			}
+		}
+		for _, d := range p.depends {
+			if err := visit(d, append(path, name)); err != nil {
+				return err
+			}
+		}
+		done[name] = true
+		ordered = append(ordered, p)
+		return nil
+	}
+	for _, name := range selected {
+		if err := visit(strings.TrimSpace(name), nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// applyProfile sets the check flags of a profile, restoreFlags resets all
+// flags to the values given on the command line. The flags of the profile
+// take precedence over its template (key check).
+func applyProfile(p *profile) error {
+	keep := make(map[string]bool)
+	for _, kv := range p.flags {
+		keep[kv[0]] = true
+	}
+	for _, kv := range p.flags {
+		if kv[0] != "check" {
+			continue
+		}
+		t, err := findTemplate(kv[1])
+		if err != nil {
+			return fmt.Errorf("profile %s: %v", p.name, err)
+		}
+		if err := t.apply(keep); err != nil {
+			return fmt.Errorf("profile %s: %v", p.name, err)
+		}
+	}
+	for _, kv := range p.flags {
+		if err := flag.Set(kv[0], kv[1]); err != nil {
+			return fmt.Errorf("profile %s: invalid value %q for flag -%s: %v", p.name, kv[1], kv[0], err)
+		}
+	}
+	return nil
+}
+
+func restoreFlags(saved map[string]string) {
+	for name, value := range saved {
+		f := flag.Lookup(name)
+		if f.Value.String() == value {
+			continue
+		}
+		// repeatable flags add values, they are emptied first
+		if l, ok := f.Value.(interface{ reset() }); ok {
+			l.reset()
+			if len(value) == 0 {
+				continue
+			}
+		}
+		flag.Set(name, value)
+	}
+}
+
+// runProfiles runs the profiles of the config file and returns the plugin exit code
+func runProfiles() int {
+	results, err := profileResults()
+	if err != nil {
+		exitUnknown("config error: " + err.Error())
+	}
+	renderers[outputFormat].Render(os.Stdout, results)
+	touchAfterRun(results)
+	return worstResult(results)
+}
+
+// profileResults runs the profiles of the config file, an error is an error
+// of the config file
+func profileResults() ([]*checkResult, error) {
+	profiles, err := parseProfiles(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var selected []string
+	if len(profileNames) > 0 {
+		selected = strings.Split(profileNames, ",")
+	}
+	ordered, err := orderProfiles(profiles, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		saved[f.Name] = f.Value.String()
+	})
+
+	for _, p := range ordered {
+		err = applyProfile(p)
+		if err == nil {
+			err = validateCheckFlags()
+		}
+		restoreFlags(saved)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %v", p.name, err)
+		}
+	}
+
+	if res := leaseHeld(hostName()); res != nil {
+		return []*checkResult{res}, nil
+	}
+	b := openBackend()
+
+	states := make(map[string]int)
+	var results []*checkResult
+	for _, p := range ordered {
+		var res *checkResult
+		failed, reason := "", ""
+		for _, d := range p.depends {
+			if states[d] != 0 {
+				failed, reason = d, "is not OK"
+				if states[d] == profileSkipped {
+					reason = "was skipped"
+				}
+				break
+			}
+		}
+		applyProfile(p)
+		switch {
+		case len(failed) > 0:
+			res = newResult()
+			res.State, res.Status, res.Output = 0, "OK", "DEPENDENT - profile "+failed+" "+reason+", check skipped"
+		case skipCheck(p.name):
+			res = skippedResult()
+		default:
+			res = check(b)
+			incompleteResult(res)
+		}
+		restoreFlags(saved)
+		res.Profile = p.name
+		res.Perfdata = append(res.Perfdata, errorPerfdata()...)
+		debugPrintf(2, "profile %s: %s\n", p.name, res.Status)
+
+		states[p.name] = res.State
+		if len(failed) > 0 {
+			states[p.name] = profileSkipped
+		}
+		results = append(results, res)
+	}
+
+	b.Close()
+	if len(results) > 0 {
+		results[len(results)-1].Output += addressNote + skippedNote()
+		if showSession {
+			results[len(results)-1].Session = loginSession
+			results[len(results)-1].Output += loginSession.String()
+		}
+		results[len(results)-1].Output += phases.String()
+	}
+	addScore(results)
+	return results, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDependentChain checks that the dependents of a skipped profile are
+// skipped as well
+func TestDependentChain(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "ucs.cfg")
+	os.WriteFile(config, []byte(`[controller]
+q = equipmentPsu
+a = dn operState
+e = ,operable$
+
+[disks]
+q = equipmentPsu
+a = dn operState
+e = .
+depends = controller
+
+[virtual-drives]
+q = equipmentPsu
+a = dn operState
+e = .
+depends = disks
+
+[psu]
+q = equipmentPsu
+a = dn operState
+e = .
+`), 0644)
+	backends["fake"] = func() Backend { return &fakeBackend{body: psuResponse("operable", "inoperable")} }
+	defer delete(backends, "fake")
+	defer func(b, c string) { backendName, configFile = b, c }(backendName, configFile)
+	backendName, configFile = "fake", config
+
+	results, err := profileResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"controller":     "CRIT",
+		"disks":          "DEPENDENT - profile controller is not OK",
+		"virtual-drives": "DEPENDENT - profile disks was skipped",
+		"psu":            "OK",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d", len(results), len(want))
+	}
+	for _, res := range results {
+		got := res.Status
+		if strings.HasPrefix(res.Output, "DEPENDENT") {
+			got = res.Output
+		}
+		if !strings.HasPrefix(got, want[res.Profile]) {
+			t.Errorf("profile %s: %s %s, want %s", res.Profile, res.Status, res.Output, want[res.Profile])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+// Shared query cache of flag -shared-query-cache: the response of a query is
+// kept in a file per host, user and query (type, dn or class, hierarchical,
+// filter, -crawl, -chunk-by) for -shared-query-ttl. The services of an Icinga2
+// apply rule fanning out over one class (a service per PSU, fan, disk, ...)
+// send a single query, every invocation evaluates its own attributes, expect
+// string and thresholds against the cached response.
+//
+// The first invocation creates a lock file, queries and writes the response,
+// the invocations started meanwhile wait for it. A run answered from the cache
+// doesn't log in. Error responses are not cached. XML API only.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	sharedQueryCache string
+	sharedQueryTTL   time.Duration
+)
+
+type queryCache struct {
+	filename string
+	lock     string
+}
+
+// newQueryCache returns the cache file of the query of the check flags
+func newQueryCache(dir string) (*queryCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	key := strings.Join([]string{hostName(), username, password, backendName, queryType, dnOrClass, hierarchical, serverFilter(), crawl, chunkBy}, "|")
+	hash := sha256.Sum256([]byte(key))
+	filename := filepath.Join(dir, "query-"+hex.EncodeToString(hash[:16])+".xml")
+	return &queryCache{filename: filename, lock: filename + ".lock"}, nil
+}
+
+// get returns the cached response if it is younger than the ttl
+func (c *queryCache) get() ([]byte, bool) {
+	fi, err := os.Stat(c.filename)
+	if err != nil || time.Since(fi.ModTime()) > sharedQueryTTL {
+		return nil, false
+	}
+	buf, err := ioutil.ReadFile(c.filename)
+	if err != nil || len(buf) == 0 {
+		return nil, false
+	}
+	debugPrintf(2, "shared query cache: response of %s, %s old\n", fi.ModTime().Format(time.RFC3339), time.Since(fi.ModTime()).Round(time.Millisecond))
+	return buf, true
+}
+
+// load returns the cached response or the response of query. Only the holder
+// of the lock file queries, the others wait for the response at most the ttl
+// and query themselves if it doesn't show up. A lock file older than the ttl
+// is left over by a killed run and removed.
+func (c *queryCache) load(query func() ([]byte, error)) ([]byte, error) {
+	deadline := time.Now().Add(sharedQueryTTL)
+	for {
+		if body, ok := c.get(); ok {
+			return body, nil
+		}
+		f, err := os.OpenFile(c.lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			defer os.Remove(c.lock)
+			body, err := query()
+			if err != nil {
+				return body, err
+			}
+			if err := writeFileAtomic(c.filename, body, 0600); err != nil {
+				debugPrintf(1, "shared query cache error: %v\n", err)
+			}
+			return body, nil
+		}
+		if !os.IsExist(err) || time.Now().After(deadline) {
+			debugPrintf(1, "shared query cache not used: %v\n", err)
+			return query()
+		}
+		if fi, err := os.Stat(c.lock); err == nil && time.Since(fi.ModTime()) > sharedQueryTTL {
+			debugPrintf(2, "shared query cache: stale lock file %s removed\n", c.lock)
+			os.Remove(c.lock)
+			continue
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// cachedQuery returns the response of the query, with -shared-query-cache
+// from the cache if it is fresh
+func (b *xmlBackend) cachedQuery() ([]byte, error) {
+	if len(sharedQueryCache) == 0 {
+		return b.query()
+	}
+	c, err := newQueryCache(sharedQueryCache)
+	if err != nil {
+		debugPrintf(1, "shared query cache error, not used: %v\n", err)
+		return b.query()
+	}
+	return c.load(b.query)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestBuildRequest(t *testing.T) {
+	req := &ConfigConfMo{Cookie: "1/abc", Dn: "sys/rack-unit-1/locator-led", InHierarchical: "false", InConfig: InConfig{
+		Mo: &EquipmentLocatorLed{Dn: "sys/rack-unit-1/locator-led", AdminState: "on"},
+	}}
+	want := `<configConfMo cookie="1/abc" dn="sys/rack-unit-1/locator-led" inHierarchical="false"><inConfig><equipmentLocatorLed dn="sys/rack-unit-1/locator-led" adminState="on" /></inConfig></configConfMo>`
+	got, err := ucsxml.BuildRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("ucsxml.BuildRequest(%T)\n got: %s\nwant: %s", req, got, want)
+	}
+}
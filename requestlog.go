@@ -0,0 +1,108 @@
+package main
+
+// Request log of the agent, flag -request-log <n>: the last n requests and
+// their responses per UCS domain are kept in memory and returned by
+// GET /debug/requests[?host=<ip_addr>] as JSON, so an intermittent parsing
+// problem can be investigated after the fact without running the agent with
+// debug output. The bodies are truncated to maxLoggedBody bytes, passwords
+// and cookies are masked.
+//
+//	$ curl -s --unix-socket /run/check_ucs.sock 'http://agent/debug/requests?host=10.10.1.5'
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxLoggedBody limits the size of a logged request or response body
+const maxLoggedBody = 64 << 10
+
+// secretAttrs matches the attributes masked in the request log
+var secretAttrs = regexp.MustCompile(`\b(inPassword|inCookie|outCookie|cookie)="[^"]*"`)
+
+type (
+	loggedRequest struct {
+		Time     time.Time `json:"time"`
+		Host     string    `json:"host"`
+		Method   string    `json:"method"`
+		Duration float64   `json:"duration_seconds"`
+		Request  string    `json:"request"`
+		Response string    `json:"response"`
+		Error    string    `json:"error,omitempty"`
+	}
+
+	// requestRing keeps the last requests of one UCS domain
+	requestRing struct {
+		entries []loggedRequest
+		next    int
+	}
+
+	requestLog struct {
+		mu    sync.Mutex
+		size  int
+		rings map[string]*requestRing // key: host
+	}
+)
+
+func newRequestLog(size int) *requestLog {
+	return &requestLog{size: size, rings: make(map[string]*requestRing)}
+}
+
+// loggedBody returns a body with masked secrets, truncated to maxLoggedBody
+func loggedBody(body []byte) string {
+	s := secretAttrs.ReplaceAllString(string(body), `$1="***"`)
+	if len(s) > maxLoggedBody {
+		s = s[:maxLoggedBody] + fmt.Sprintf("... (%d bytes truncated)", len(s)-maxLoggedBody)
+	}
+	return s
+}
+
+// add logs a request, the oldest request of the host is dropped if the ring is full
+func (l *requestLog) add(host, method string, req, resp []byte, d time.Duration, err error) {
+	entry := loggedRequest{
+		Time:     time.Now().Add(-d),
+		Host:     host,
+		Method:   method,
+		Duration: d.Seconds(),
+		Request:  loggedBody(req),
+		Response: loggedBody(resp),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.rings[host]
+	if !ok {
+		r = &requestRing{}
+		l.rings[host] = r
+	}
+	if len(r.entries) < l.size {
+		r.entries = append(r.entries, entry)
+		return
+	}
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % l.size
+}
+
+// requests returns the logged requests of a host or of all hosts, oldest first
+func (l *requestLog) requests(host string) []loggedRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := []loggedRequest{}
+	for h, r := range l.rings {
+		if len(host) > 0 && h != host {
+			continue
+		}
+		entries = append(entries, r.entries[r.next:]...)
+		entries = append(entries, r.entries[:r.next]...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
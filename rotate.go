@@ -0,0 +1,127 @@
+package main
+
+// Round robin of flag -rotate-group k/N: the chassis and rack servers are
+// split into N groups by their number (chassis 1, N+1, 2N+1, ... form the
+// first group), every run checks one group and the next run the next one.
+// Invocation k starts with group k, so N services of the same check with
+// k = 1..N check different groups in the same cycle and together the whole
+// domain, each of them alerts within N cycles. This spreads the load of
+// gigantic domains:
+//
+//	-q equipmentPsu -a "dn operState" -e ",operable$" -crawl chassis -rotate-group 1/4 -state-file /var/tmp/ucs.state
+//
+// With -crawl and -chunk-by only the chassis or rack servers of the group are
+// queried, else the objects are filtered after the query. Objects outside of
+// chassis and rack servers (e.g. fabric interconnects) are checked every run.
+// A group without objects is OK (like -z), if there are objects in the others.
+// The cycle is counted in -state-file, which is required, the state of the
+// objects (new objects, hysteresis, -sample) is kept per group.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var rotateGroup string
+
+var (
+	rotateIndex   int  // group of the current run, 0-based
+	rotateCount   int  // number of groups, 0: no rotation
+	rotationEmpty bool // no chassis or rack server in the group, e.g. 3 chassis in 4 groups
+)
+
+// rotateUnit matches the number of the chassis or rack server of a dn
+var rotateUnit = regexp.MustCompile(`^sys/(?:chassis|rack-unit)-([0-9]+)(?:/|$)`)
+
+// parseRotateGroup parses k/N of -rotate-group
+func parseRotateGroup(s string) (int, int, error) {
+	var k, n int
+	if _, err := fmt.Sscanf(s, "%d/%d", &k, &n); err != nil || n < 1 || k < 1 || k > n {
+		return 0, 0, fmt.Errorf("invalid -rotate-group %q, expected k/N with 1 <= k <= N, e.g. 1/4", s)
+	}
+	return k, n, nil
+}
+
+// validateRotation checks flag -rotate-group
+func validateRotation() error {
+	if len(rotateGroup) == 0 {
+		return nil
+	}
+	if _, _, err := parseRotateGroup(rotateGroup); err != nil {
+		return err
+	}
+	if len(stateFile) == 0 {
+		return fmt.Errorf("flag -rotate-group requires -state-file, it counts the cycles")
+	}
+	return nil
+}
+
+// startRotation selects the group of the run and counts the cycle in the state file
+func startRotation() error {
+	rotateIndex, rotateCount, rotationEmpty = 0, 0, false
+	if len(rotateGroup) == 0 {
+		return nil
+	}
+	k, n, _ := parseRotateGroup(rotateGroup)
+	key := checkStateKey() + "|rotate-group " + rotateGroup
+	cs, err := loadCheckState(stateFile, key)
+	if err != nil {
+		return fmt.Errorf("state file error: %v", err)
+	}
+	rotateIndex, rotateCount = (k-1+cs.Cycle)%n, n
+	cs.Cycle++
+	debugPrintf(2, "rotation: cycle %d, group %d of %d\n", cs.Cycle, rotateIndex+1, n)
+	if err := saveCheckState(stateFile, key, cs); err != nil {
+		return fmt.Errorf("state file error: %v", err)
+	}
+	return nil
+}
+
+// inRotation returns true if the object of the dn is checked in this run
+func inRotation(dn string) bool {
+	if rotateCount == 0 {
+		return true
+	}
+	m := rotateUnit.FindStringSubmatch(dn)
+	if m == nil {
+		return true
+	}
+	unit, _ := strconv.Atoi(m[1])
+	return (unit+rotateCount-1)%rotateCount == rotateIndex
+}
+
+// rotationDns returns the dns of the group, e.g. the chassis of -crawl
+func rotationDns(dns []string) []string {
+	var group []string
+	for _, dn := range dns {
+		if inRotation(dn) {
+			group = append(group, dn)
+		}
+	}
+	rotationEmpty = rotationEmpty || len(dns) > 0 && len(group) == 0
+	return group
+}
+
+// rotationObjects returns the objects of the group
+func rotationObjects(objects []managedObject) []managedObject {
+	if rotateCount == 0 {
+		return objects
+	}
+	var group []managedObject
+	for _, obj := range objects {
+		if inRotation(obj.Dn) {
+			group = append(group, obj)
+		}
+	}
+	rotationEmpty = rotationEmpty || len(objects) > 0 && len(group) == 0
+	return group
+}
+
+// rotationNote returns the long output line of the group
+func rotationNote() string {
+	if rotateCount == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\nrotation: group %d of %d checked (chassis and rack servers %d, %d, ...)", rotateIndex+1, rotateCount, rotateIndex+1, rotateIndex+1+rotateCount)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestInRotation(t *testing.T) {
+	defer func() { rotateIndex, rotateCount = 0, 0 }()
+	rotateIndex, rotateCount = 1, 3 // group 2 of 3
+
+	tests := []struct {
+		dn   string
+		want bool
+	}{
+		{"sys/chassis-2/psu-1", true},
+		{"sys/chassis-5", true},
+		{"sys/chassis-1/blade-2", false},
+		{"sys/chassis-3/psu-2", false},
+		{"sys/rack-unit-8/psu-1", true},
+		{"sys/rack-unit-12/psu-1", false},
+		{"sys/switch-A/psu-1", true}, // not in a chassis, every run
+	}
+	for _, tt := range tests {
+		if got := inRotation(tt.dn); got != tt.want {
+			t.Errorf("inRotation(%q) = %v, want %v", tt.dn, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"0/4", "5/4", "1/0", "1", "a/b"} {
+		if _, _, err := parseRotateGroup(s); err == nil {
+			t.Errorf("parseRotateGroup(%q) succeeded", s)
+		}
+	}
+}
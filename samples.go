@@ -0,0 +1,81 @@
+package main
+
+// Flags -samples and -sample-interval: the objects are read several times
+// within one run and the numeric attribute values are replaced by their
+// average or median (-sample-aggregate), smoothing out the spikes of
+// instantaneous readings like outputPower or ambientTemp. Objects missing in
+// a sample and values which are not numbers are taken from the last sample.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// sampleObjects returns the objects of the last sample with the aggregated values
+func sampleObjects(b Backend) ([]managedObject, []byte, error) {
+	var (
+		objects []managedObject
+		body    []byte
+		err     error
+	)
+	values := make(map[string]map[string][]float64) // dn and attribute
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			time.Sleep(sampleInterval)
+		}
+		objects, body, err = b.Objects()
+		if err == nil {
+			err = joinObjects(b, objects)
+		}
+		if err != nil && samples > 1 {
+			err = fmt.Errorf("sample %d: %v", i+1, err)
+		}
+		if err != nil {
+			return nil, body, err
+		}
+		for _, obj := range objects {
+			if values[obj.Dn] == nil {
+				values[obj.Dn] = make(map[string][]float64)
+			}
+			for attr, s := range obj.Attrs {
+				if v, err := strconv.ParseFloat(s, 64); err == nil {
+					values[obj.Dn][attr] = append(values[obj.Dn][attr], v)
+				}
+			}
+		}
+	}
+	if samples <= 1 {
+		return objects, body, nil
+	}
+
+	for _, obj := range objects {
+		for attr, s := range obj.Attrs {
+			if _, err := strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+			v := aggregate(values[obj.Dn][attr], sampleAggregate)
+			obj.Attrs[attr] = strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+		}
+	}
+	debugPrintf(2, "%d samples, %s of the numeric values\n", samples, sampleAggregate)
+	return objects, body, nil
+}
+
+func aggregate(v []float64, method string) float64 {
+	if method == "median" {
+		s := append([]float64{}, v...)
+		sort.Float64s(s)
+		if len(s)%2 == 1 {
+			return s[len(s)/2]
+		}
+		return (s[len(s)/2-1] + s[len(s)/2]) / 2
+	}
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
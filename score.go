@@ -0,0 +1,58 @@
+package main
+
+// Health score of flag -score: one number from 0 to 100 per UCS domain and
+// run, reported as perfdata health_score, a trendable value for management
+// dashboards instead of the states of many services. It is computed from all
+// results of the run, the profiles of -config or the check of the flags:
+//
+//	health_score = 100 * (1 - failed/objects) * max(0, 1 - sum of the fault weights/100)
+//
+// Objects with the attribute severity (faultInst, -a "... severity ...") are
+// faults weighted by their severity, the other objects are instances, failed
+// if their state isn't OK. A critical fault costs 25 points of 100, a domain
+// with half of the power supplies failed scores 50.
+//
+//	critical 25, major 10, minor 3, warning 1, info, condition and cleared 0
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+var healthScore bool
+
+// severityWeights are the weights of the fault severities in the score
+var severityWeights = map[string]float64{"critical": 25, "major": 10, "minor": 3, "warning": 1}
+
+// scoreOf returns the health score of the objects of the results
+func scoreOf(results []*checkResult) int {
+	weight := 0.0
+	objects, failed := 0, 0
+	for _, res := range results {
+		for _, obj := range res.Objects {
+			if sev, ok := obj.Attrs["severity"]; ok {
+				weight += severityWeights[strings.ToLower(sev)]
+				continue
+			}
+			objects++
+			if obj.State != 0 {
+				failed++
+			}
+		}
+	}
+	score := 100 * math.Max(0, 1-weight/100)
+	if objects > 0 {
+		score *= 1 - float64(failed)/float64(objects)
+	}
+	return int(math.Round(score))
+}
+
+// addScore appends the health score to the perfdata of the last result
+func addScore(results []*checkResult) {
+	if !healthScore || len(results) == 0 {
+		return
+	}
+	last := results[len(results)-1]
+	last.Perfdata = append(last.Perfdata, perfValue{Label: "health_score", Value: strconv.Itoa(scoreOf(results))})
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestScoreOf(t *testing.T) {
+	fault := func(sev string) checkObject {
+		return checkObject{Attrs: map[string]string{"severity": sev}, State: 2}
+	}
+	psu := func(state int) checkObject {
+		return checkObject{Attrs: map[string]string{"operState": "x"}, State: state}
+	}
+	tests := []struct {
+		name    string
+		results []*checkResult
+		want    int
+	}{
+		{"empty", nil, 100},
+		{"all ok", []*checkResult{{Objects: []checkObject{psu(0), psu(0)}}}, 100},
+		{"half failed", []*checkResult{{Objects: []checkObject{psu(0), psu(2), psu(1), psu(0)}}}, 50},
+		{"faults", []*checkResult{{Objects: []checkObject{fault("critical"), fault("Major"), fault("cleared"), fault("info")}}}, 65},
+		{"too many faults", []*checkResult{{Objects: []checkObject{fault("critical"), fault("critical"), fault("critical"), fault("critical"), fault("critical")}}}, 0},
+		{"profiles", []*checkResult{
+			{Objects: []checkObject{psu(0), psu(0), psu(0), psu(2)}},
+			{Objects: []checkObject{fault("major"), fault("minor")}},
+		}, 65},
+	}
+	for _, tt := range tests {
+		if got := scoreOf(tt.results); got != tt.want {
+			t.Errorf("%s: score %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// isWindowsService is true if the process was started by the Windows service
+// control manager, see service_windows.go
+func isWindowsService() bool {
+	return false
+}
+
+func runService(name string, serve func() error, stop func()) error {
+	return fmt.Errorf("Windows services are not supported on this platform")
+}
+
+func openEventLog(source string) (io.Writer, error) {
+	return nil, fmt.Errorf("the Windows event log is not supported on this platform")
+}
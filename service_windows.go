@@ -0,0 +1,91 @@
+//go:build windows
+
+package main
+
+// Windows service support of the agent: started by the service control
+// manager the agent reports its state and stops on Stop and Shutdown, the
+// log can go to the Windows event log. The event source has to be registered
+// once, e.g. New-EventLog -LogName Application -Source check_cisco_ucs
+//
+//	sc.exe create check_cisco_ucs start= auto binPath= "C:\monitoring\check_cisco_ucs.exe agent -listen 127.0.0.1:9711 -event-log check_cisco_ucs"
+
+import (
+	"io"
+	"log"
+	"strings"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// isWindowsService is true if the process was started by the service control manager
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+type agentService struct {
+	serve func() error
+	stop  func()
+}
+
+func (s *agentService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.serve()
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Printf("agent: %v\n", err)
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Printf("agent: service stop requested\n")
+				changes <- svc.Status{State: svc.StopPending}
+				s.stop()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// runService runs the agent as the Windows service name until it is stopped
+func runService(name string, serve func() error, stop func()) error {
+	return svc.Run(name, &agentService{serve: serve, stop: stop})
+}
+
+type eventLogWriter struct {
+	l *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	var err error
+	switch {
+	case strings.Contains(msg, "failed") || strings.Contains(msg, "error"):
+		err = w.l.Warning(2, msg)
+	default:
+		err = w.l.Info(1, msg)
+	}
+	return len(p), err
+}
+
+// openEventLog returns a writer to the Windows event log of the event source
+func openEventLog(source string) (io.Writer, error) {
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return eventLogWriter{l: l}, nil
+}
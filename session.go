@@ -0,0 +1,166 @@
+package main
+
+// Session cache of flag -session-cache: the cookie and the TLS session
+// tickets of an XML API session are kept in a file per host and user, so
+// repeated invocations neither log in nor do a full TLS handshake again.
+// A cached cookie is checked and refreshed with aaaKeepAlive, the session is
+// not logged out at the end of the run.
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+type tlsTicket struct {
+	Ticket []byte `json:"ticket"`
+	State  []byte `json:"state"`
+}
+
+type cachedSession struct {
+	Host          string               `json:"host"`
+	User          string               `json:"user"`
+	Since         time.Time            `json:"since"` // first login
+	Cookie        string               `json:"cookie"`
+	RefreshPeriod time.Duration        `json:"refreshPeriod"`
+	Created       time.Time            `json:"created"`
+	Refreshed     time.Time            `json:"refreshed"`
+	Refreshes     int                  `json:"refreshes"`
+	Logins        int                  `json:"logins"`
+	TLSTickets    map[string]tlsTicket `json:"tlsTickets,omitempty"` // key: TLS session cache key
+	Details       *sessionDetails      `json:"details,omitempty"`    // aaaLogin response
+
+	mu       sync.Mutex
+	filename string
+}
+
+// session is the cached session of the run, nil without -session-cache
+var session *cachedSession
+
+// sessionMaxAge is the age of a cached session after which it is logged out, 0: never
+var sessionMaxAge time.Duration
+
+// loadSession reads the cached session of the host and user, the file name
+// is derived from host, user and password
+func loadSession(dir string) (*cachedSession, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(ipAddr + "|" + username + "|" + password))
+	s := &cachedSession{Host: ipAddr, User: username, filename: filepath.Join(dir, hex.EncodeToString(hash[:16])+".json")}
+
+	buf, err := ioutil.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) > 0 {
+		if err := json.Unmarshal(buf, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *cachedSession) save() error {
+	s.mu.Lock()
+	buf, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.filename, buf, 0600)
+}
+
+// Get and Put implement tls.ClientSessionCache
+func (s *cachedSession) Get(key string) (*tls.ClientSessionState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.TLSTickets[key]
+	if !ok {
+		return nil, false
+	}
+	state, err := tls.ParseSessionState(t.State)
+	if err != nil {
+		return nil, false
+	}
+	cs, err := tls.NewResumptionState(t.Ticket, state)
+	if err != nil {
+		return nil, false
+	}
+	debugPrintf(2, "TLS session ticket found for %s\n", key)
+	return cs, true
+}
+
+func (s *cachedSession) Put(key string, cs *tls.ClientSessionState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cs == nil {
+		delete(s.TLSTickets, key)
+		return
+	}
+	ticket, state, err := cs.ResumptionState()
+	if err != nil || state == nil {
+		return
+	}
+	buf, err := state.Bytes()
+	if err != nil {
+		return
+	}
+	if s.TLSTickets == nil {
+		s.TLSTickets = make(map[string]tlsTicket)
+	}
+	s.TLSTickets[key] = tlsTicket{Ticket: ticket, State: buf}
+}
+
+// resume returns the cached cookie if it is still valid, it is refreshed with
+// aaaKeepAlive
+func (s *cachedSession) resume(client *http.Client, url string) (string, bool) {
+	if len(s.Cookie) == 0 || time.Since(s.Refreshed) > s.RefreshPeriod {
+		return "", false
+	}
+	body, err := configRequest(client, url, &AaaKeepAlive{Cookie: s.Cookie})
+	if err != nil {
+		debugPrintf(1, "cached session: %v\n", err)
+		s.Cookie = ""
+		return "", false
+	}
+	debugPrintf(3, "keepalive response: %s\n", body)
+	s.Refreshed = time.Now()
+	s.Refreshes++
+	if s.Details != nil {
+		d := *s.Details
+		d.Cached = true
+		loginSession = &d
+	}
+	return s.Cookie, true
+}
+
+// loggedIn stores the cookie of a new session
+func (s *cachedSession) loggedIn(resp *ucsxml.AaaLoginResp) {
+	period, _ := strconv.Atoi(resp.OutRefreshPeriod)
+	if period <= 0 {
+		period = 600
+	}
+	s.Cookie = resp.OutCookie
+	s.Details = loginSession
+	s.RefreshPeriod = time.Duration(period) * time.Second
+	s.Created = time.Now()
+	s.Refreshed = s.Created
+	if s.Since.IsZero() {
+		s.Since = s.Created
+	}
+	s.Logins++
+}
@@ -0,0 +1,184 @@
+package main
+
+// Subcommand *session stats*: the sessions of the session cache (-session-cache)
+// and of the agent (-via-agent, GET /sessions) with their age, keepalive
+// refreshes and logins. The login rate per UCS domain shows if sessions are
+// lost and created again, every login takes one of the web sessions UCS
+// Manager allows per user and in total until it times out.
+//
+//	$ ./check_cisco_ucs session stats -session-cache /var/tmp/check_ucs -via-agent /run/check_ucs.sock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type sessionInfo struct {
+	Host      string    `json:"host"`
+	User      string    `json:"user"`
+	Active    bool      `json:"active"`
+	Since     time.Time `json:"since"`             // first login
+	Created   time.Time `json:"created,omitempty"` // login of the current session
+	Refreshed time.Time `json:"refreshed,omitempty"`
+	Refreshes int       `json:"refreshes"`
+	Logins    int       `json:"logins"`
+}
+
+// loginRate returns the logins per hour since the first login
+func (s sessionInfo) loginRate() float64 {
+	hours := time.Since(s.Since).Hours()
+	if hours < 1 {
+		hours = 1
+	}
+	return float64(s.Logins) / hours
+}
+
+// cachedSessions returns the sessions of the session cache directory
+func cachedSessions(dir string) ([]sessionInfo, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var infos []sessionInfo
+	for _, f := range files {
+		buf, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var s cachedSession
+		if err := json.Unmarshal(buf, &s); err != nil {
+			debugPrintf(1, "session stats: %s: %v\n", f, err)
+			continue
+		}
+		info := sessionInfo{
+			Host:      s.Host,
+			User:      s.User,
+			Active:    len(s.Cookie) > 0 && time.Since(s.Refreshed) <= s.RefreshPeriod,
+			Since:     s.Since,
+			Created:   s.Created,
+			Refreshed: s.Refreshed,
+			Refreshes: s.Refreshes,
+			Logins:    s.Logins,
+		}
+		if len(info.Host) == 0 {
+			// written by an older version
+			info.Host = strings.TrimSuffix(filepath.Base(f), ".json")
+		}
+		if info.Since.IsZero() {
+			info.Since = s.Created
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// sessionInfos returns the pooled sessions of the agent
+func (a *agent) sessionInfos() []sessionInfo {
+	a.mu.Lock()
+	var sessions []*agentSession
+	for _, s := range a.sessions {
+		sessions = append(sessions, s)
+	}
+	a.mu.Unlock()
+
+	var infos []sessionInfo
+	for _, s := range sessions {
+		s.mu.Lock()
+		infos = append(infos, sessionInfo{
+			Host:      s.host,
+			User:      s.username,
+			Active:    len(s.cookie) > 0,
+			Since:     s.since,
+			Created:   s.created,
+			Refreshed: s.refreshed,
+			Refreshes: s.refreshes,
+			Logins:    s.logins,
+		})
+		s.mu.Unlock()
+	}
+	return infos
+}
+
+// agentSessions returns the sessions of the agent listening on addr
+func agentSessions(addr string) ([]sessionInfo, error) {
+	client, base := agentClient(addr)
+	resp, err := client.Get(base + "/sessions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /sessions: %s", resp.Status)
+	}
+	var infos []sessionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// printSessions prints the sessions and the summary per UCS domain
+func printSessions(source string, infos []sessionInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Host != infos[j].Host {
+			return infos[i].Host < infos[j].Host
+		}
+		return infos[i].User < infos[j].User
+	})
+	fmt.Printf("%s: %d sessions\n", source, len(infos))
+	active := make(map[string]int)
+	logins := make(map[string]float64)
+	var hosts []string
+	for _, s := range infos {
+		state, age := "inactive", "-"
+		if s.Active {
+			state = "active"
+			age = time.Since(s.Created).Round(time.Second).String()
+			active[s.Host]++
+		}
+		if _, ok := logins[s.Host]; !ok {
+			hosts = append(hosts, s.Host)
+		}
+		logins[s.Host] += s.loginRate()
+		fmt.Printf("  %s %s: %s, age %s, %d refreshes, %d logins (%.1f/h)\n", s.User, s.Host, state, age, s.Refreshes, s.Logins, s.loginRate())
+	}
+	for _, h := range hosts {
+		fmt.Printf("  domain %s: %d active sessions, %.1f logins/h\n", h, active[h], logins[h])
+	}
+}
+
+func runSession(args []string) int {
+	fs := newFlagSet("session")
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 || pos[0] != "stats" || (len(sessionCache) == 0 && len(viaAgent) == 0) {
+		fs.Usage()
+		return 3
+	}
+
+	ret := 0
+	if len(sessionCache) > 0 {
+		infos, err := cachedSessions(sessionCache)
+		if err != nil {
+			fmt.Printf("UNKNOWN - session cache %s: %v\n", sessionCache, err)
+			ret = 3
+		} else {
+			printSessions("session cache "+sessionCache, infos)
+		}
+	}
+	if len(viaAgent) > 0 {
+		infos, err := agentSessions(viaAgent)
+		if err != nil {
+			fmt.Printf("UNKNOWN - agent %s: %v\n", viaAgent, err)
+			ret = 3
+		} else {
+			printSessions("agent "+viaAgent, infos)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,82 @@
+package main
+
+// Severity mapping of flags -crit-sev and -warn-sev: the Cisco severity of a
+// fault (attribute severity of faultInst) is mapped to the Nagios state
+// directly instead of matching the output line with a regex expect string,
+// e.g. -crit-sev critical,major -warn-sev minor,warning. Severities in neither
+// list are OK. With the mapping the expect string isn't evaluated, the
+// attribute severity has to be one of the attributes of -a:
+//
+//	check_cisco_ucs -t class -q faultInst -a "code severity ack descr" -z -F -crit-sev critical,major -warn-sev minor,warning
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	critSeverities string
+	warnSeverities string
+)
+
+// faultSeverities are the severities of UCS Manager and CIMC faults
+var faultSeverities = []string{"critical", "major", "minor", "warning", "info", "condition", "cleared"}
+
+// parseSeverities parses a comma separated list of severities
+func parseSeverities(s string) ([]string, error) {
+	var sevs []string
+	for _, sev := range strings.Split(s, ",") {
+		sev = strings.ToLower(strings.TrimSpace(sev))
+		if len(sev) == 0 {
+			continue
+		}
+		if findIndex(sev, faultSeverities) < 0 {
+			return nil, fmt.Errorf("unknown severity %q, expected %s", sev, strings.Join(faultSeverities, ", "))
+		}
+		sevs = append(sevs, sev)
+	}
+	return sevs, nil
+}
+
+// severityStates returns the Nagios state of the mapped severities, nil
+// without -crit-sev and -warn-sev
+func severityStates() (map[string]int, error) {
+	if len(critSeverities) == 0 && len(warnSeverities) == 0 {
+		return nil, nil
+	}
+	states := make(map[string]int)
+	for _, l := range []struct {
+		flag  string
+		s     string
+		state int
+	}{{"warn-sev", warnSeverities, 1}, {"crit-sev", critSeverities, 2}} {
+		sevs, err := parseSeverities(l.s)
+		if err != nil {
+			return nil, fmt.Errorf("flag -%s: %v", l.flag, err)
+		}
+		for _, sev := range sevs {
+			if states[sev] > 0 && states[sev] != l.state {
+				return nil, fmt.Errorf("severity %s is in -crit-sev and -warn-sev", sev)
+			}
+			states[sev] = l.state
+		}
+	}
+	return states, nil
+}
+
+// validateSeverities checks flags -crit-sev and -warn-sev
+func validateSeverities(attributeArray []string) error {
+	states, err := severityStates()
+	if err != nil || states == nil {
+		return err
+	}
+	if findIndex("severity", attributeArray) < 0 {
+		return fmt.Errorf("flags -crit-sev and -warn-sev require the attribute severity (-a)")
+	}
+	return nil
+}
+
+// objectSeverity returns the state of the severity of an object
+func objectSeverity(states map[string]int, attrs map[string]string) int {
+	return states[strings.ToLower(attrs["severity"])]
+}
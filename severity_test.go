@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSeverityStates(t *testing.T) {
+	defer func(c, w string) { critSeverities, warnSeverities = c, w }(critSeverities, warnSeverities)
+
+	critSeverities, warnSeverities = "", ""
+	if states, err := severityStates(); states != nil || err != nil {
+		t.Errorf("no mapping: got %v, %v", states, err)
+	}
+
+	critSeverities, warnSeverities = "critical, Major", "minor,warning"
+	states, err := severityStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sev, want := range map[string]int{"critical": 2, "major": 2, "minor": 1, "warning": 1, "info": 0, "cleared": 0} {
+		if got := objectSeverity(states, map[string]string{"severity": sev}); got != want {
+			t.Errorf("severity %s: got %d, want %d", sev, got, want)
+		}
+	}
+
+	for _, tc := range [][2]string{{"critical,fatal", ""}, {"major", "major"}} {
+		critSeverities, warnSeverities = tc[0], tc[1]
+		if _, err := severityStates(); err == nil {
+			t.Errorf("-crit-sev %q -warn-sev %q: no error", tc[0], tc[1])
+		}
+	}
+}
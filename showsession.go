@@ -0,0 +1,74 @@
+package main
+
+// Session details of flag -show-session: the attributes of the aaaLogin
+// response (privileges, refresh period, domains, version, ...) are appended
+// to the long output and added to the JSON output. The privileges of a
+// restricted monitoring role explain why a class returns no objects or a
+// permission error. With -d 2 the details are printed as JSON at login.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+type sessionDetails struct {
+	Priv          []string `json:"priv"`
+	RefreshPeriod int      `json:"refreshPeriod"` // seconds
+	Domains       string   `json:"domains,omitempty"`
+	SessionId     string   `json:"sessionId,omitempty"`
+	Version       string   `json:"version,omitempty"`
+	Channel       string   `json:"channel,omitempty"`
+	EvtChannel    string   `json:"evtChannel,omitempty"`
+	Cached        bool     `json:"cached,omitempty"` // resumed session of -session-cache
+}
+
+var showSession bool
+
+// loginSession is the session of the run, nil before the login or if the
+// run didn't log in (-shared-query-cache)
+var loginSession *sessionDetails
+
+func newSessionDetails(resp *ucsxml.AaaLoginResp) *sessionDetails {
+	d := &sessionDetails{
+		Domains:    resp.OutDomains,
+		SessionId:  resp.OutSessionId,
+		Version:    resp.OutVersion,
+		Channel:    resp.OutChannel,
+		EvtChannel: resp.OutEvtChannel,
+	}
+	d.RefreshPeriod, _ = strconv.Atoi(resp.OutRefreshPeriod)
+	for _, p := range strings.Split(resp.OutPriv, ",") {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			d.Priv = append(d.Priv, p)
+		}
+	}
+	if buf, err := json.Marshal(d); err == nil {
+		debugPrintf(2, "aaaLogin session: %s\n", buf)
+	}
+	return d
+}
+
+// String returns the long output line of the session, empty without a session
+func (d *sessionDetails) String() string {
+	if d == nil {
+		return ""
+	}
+	s := fmt.Sprintf("\nsession: priv %s, refresh period %ds", strings.Join(d.Priv, ","), d.RefreshPeriod)
+	if len(d.Domains) > 0 {
+		s += ", domains " + d.Domains
+	}
+	if len(d.Version) > 0 {
+		s += ", version " + d.Version
+	}
+	if len(d.SessionId) > 0 {
+		s += ", session id " + d.SessionId
+	}
+	if d.Cached {
+		s += ", cached"
+	}
+	return s
+}
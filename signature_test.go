@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestSignature(t *testing.T) {
+	// printf "1760520000\nPOST\nucs\n/nuova\n$(printf '<aaaLogin/>' | sha256sum | cut -d' ' -f1)" | openssl dgst -sha256 -hmac secret
+	got := requestSignature([]byte("secret"), time.Unix(1760520000, 0), "POST", "ucs", "/nuova", []byte("<aaaLogin/>"))
+	if want := "t=1760520000,sig=009cc63b77e1b2468b86c7720865873d95d4f755b318518b5f483e1fa7d69ab3"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if other := requestSignature([]byte("secret"), time.Unix(1760520000, 0), "POST", "ucs", "/nuova", []byte("<aaaLogout/>")); other == got {
+		t.Errorf("same signature of another body")
+	}
+}
+
+func TestSigningTransport(t *testing.T) {
+	defer func(f, h string) { hmacFile, hmacHeader = f, h }(hmacFile, hmacHeader)
+	hmacFile = filepath.Join(t.TempDir(), "hmac.secret")
+	hmacHeader = "X-Monitoring-Signature"
+	if err := os.WriteFile(hmacFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var header, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Monitoring-Signature")
+		buf := make([]byte, 64)
+		n, _ := r.Body.Read(buf)
+		body = string(buf[:n])
+	}))
+	defer srv.Close()
+
+	rt, err := withSignature(http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: rt}).Post(srv.URL+"/nuova", "text/xml", strings.NewReader("<aaaLogin/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	ts, _ := strconv.ParseInt(strings.TrimPrefix(strings.SplitN(header, ",", 2)[0], "t="), 10, 64)
+	want := requestSignature([]byte("secret"), time.Unix(ts, 0), "POST", host, "/nuova", []byte("<aaaLogin/>"))
+	if header != want || body != "<aaaLogin/>" {
+		t.Errorf("got header %q body %q, want %q", header, body, want)
+	}
+}
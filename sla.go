@@ -0,0 +1,237 @@
+package main
+
+// Availability of the objects tracked in the state of flag -sla: every run
+// records when an object (dn) turns non-OK and when it is OK again, the
+// outages of the last 400 days are kept in the state of the check (-state-file,
+// file, Redis or SQLite). Subcommand *report sla* sums them up per object over
+// a period, evidence of the failures of a component for an RMA or a support
+// escalation:
+//
+//	check_cisco_ucs -q equipmentPsu -a "dn operState" -e operable -state-file /var/lib/nagios/ucs.state -sla
+//	check_cisco_ucs report sla -state-file /var/lib/nagios/ucs.state -period 30d
+//
+// An outage starts at the first run which finds the object non-OK and ends at
+// the first run which finds it OK again or doesn't find it anymore, so its
+// length is accurate to the check interval. The availability of an object is
+// the time without outage of the time it was observed in the period.
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var slaTracking bool
+
+// slaRetention is the age of the outages removed from the state
+const slaRetention = 400 * 24 * time.Hour
+
+type (
+	// slaRecord is the availability of an object
+	slaRecord struct {
+		Since   time.Time `json:"since"` // first run which found the object
+		Outages []outage  `json:"outages,omitempty"`
+	}
+
+	// outage is a time the object wasn't OK, without end if it still isn't
+	outage struct {
+		Start time.Time  `json:"start"`
+		End   *time.Time `json:"end,omitempty"`
+		State int        `json:"state"` // worst state of the outage
+	}
+
+	// slaLine is a line of report sla
+	slaLine struct {
+		host, dn     string
+		availability float64 // percent
+		down         time.Duration
+		outages      int
+	}
+)
+
+// validateSLA checks flag -sla
+func validateSLA() error {
+	if slaTracking && len(stateFile) == 0 {
+		return fmt.Errorf("flag -sla requires -state-file")
+	}
+	return nil
+}
+
+// trackSLA records the outages of the objects of a run, dns are all objects
+// found by the run, also the ones not evaluated (-sample, -limit)
+func (cs *checkState) trackSLA(objects []checkObject, dns []string, now time.Time) {
+	if cs.SLA == nil {
+		cs.SLA = make(map[string]*slaRecord)
+	}
+	found := make(map[string]bool)
+	for _, dn := range dns {
+		found[dn] = true
+	}
+	for _, obj := range objects {
+		rec := cs.SLA[obj.Dn]
+		if rec == nil {
+			rec = &slaRecord{Since: now}
+			cs.SLA[obj.Dn] = rec
+		}
+		open := rec.open()
+		switch {
+		case obj.State != 0 && open == nil:
+			rec.Outages = append(rec.Outages, outage{Start: now, State: obj.State})
+		case obj.State != 0:
+			open.State = worstState(open.State, obj.State)
+		case open != nil:
+			open.End = &now
+		}
+	}
+	for dn, rec := range cs.SLA {
+		if open := rec.open(); open != nil && !found[dn] {
+			open.End = &now
+		}
+		rec.prune(now.Add(-slaRetention))
+		if len(rec.Outages) == 0 && !found[dn] {
+			delete(cs.SLA, dn)
+		}
+	}
+}
+
+// open returns the outage without end, nil if there is none
+func (rec *slaRecord) open() *outage {
+	if n := len(rec.Outages); n > 0 && rec.Outages[n-1].End == nil {
+		return &rec.Outages[n-1]
+	}
+	return nil
+}
+
+// prune removes the outages ended before t
+func (rec *slaRecord) prune(t time.Time) {
+	var kept []outage
+	for _, o := range rec.Outages {
+		if o.End == nil || o.End.After(t) {
+			kept = append(kept, o)
+		}
+	}
+	rec.Outages = kept
+}
+
+// availability returns the time of the outages and the observed time of the
+// period from..to, open outages last until to
+func (rec *slaRecord) availability(from, to time.Time) (down, observed time.Duration, outages int) {
+	if rec.Since.After(from) {
+		from = rec.Since
+	}
+	if !to.After(from) {
+		return 0, 0, 0
+	}
+	for _, o := range rec.Outages {
+		start, end := o.Start, to
+		if o.End != nil && o.End.Before(to) {
+			end = *o.End
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(start) {
+			down += end.Sub(start)
+			outages++
+		}
+	}
+	return down, to.Sub(from), outages
+}
+
+// slaLines returns the availability of the objects of the state of a check,
+// observed until its last run
+func slaLines(key string, cs *checkState, from time.Time) []slaLine {
+	var lines []slaLine
+	host := strings.SplitN(key, "|", 2)[0]
+	for dn, rec := range cs.SLA {
+		down, observed, n := rec.availability(from, cs.Updated)
+		if observed <= 0 {
+			continue
+		}
+		lines = append(lines, slaLine{host: host, dn: dn, availability: 100 * (1 - down.Seconds()/observed.Seconds()), down: down, outages: n})
+	}
+	return lines
+}
+
+// parsePeriod parses a duration with the unit d (days) as well, e.g. 30d
+func parsePeriod(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid period %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period %q", s)
+	}
+	return d, nil
+}
+
+func runReport(args []string) int {
+	fs := newFlagSet("report")
+	fs.StringVar(&stateFile, "state-file", "", "state of the checks with -sla, file, redis://... or sqlite:...")
+	period := fs.String("period", "30d", "period of the report, days (30d) or a duration (12h)")
+	host := fs.String("host", "", "only the objects of this UCS domain (-H or -label of the checks)")
+	all := fs.Bool("all", false, "also the objects without outage in the period")
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 || pos[0] != "sla" || len(stateFile) == 0 {
+		fs.Usage()
+		return 3
+	}
+	d, err := parsePeriod(*period)
+	if err != nil {
+		exitUnknown("report sla: " + err.Error())
+	}
+	to := time.Now()
+	from := to.Add(-d)
+
+	store, err := openStateStore(stateFile)
+	if err != nil {
+		exitUnknown("report sla: " + err.Error())
+	}
+	defer store.Close()
+	keys, err := store.Keys()
+	if err != nil {
+		exitUnknown("report sla: " + err.Error())
+	}
+	var lines []slaLine
+	for _, key := range keys {
+		if len(*host) > 0 && !strings.HasPrefix(key, *host+"|") {
+			continue
+		}
+		cs, err := store.Load(key)
+		if err != nil {
+			exitUnknown(fmt.Sprintf("report sla: %s: %v", key, err))
+		}
+		for _, l := range slaLines(key, cs, from) {
+			if l.outages > 0 || *all {
+				lines = append(lines, l)
+			}
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].availability != lines[j].availability {
+			return lines[i].availability < lines[j].availability
+		}
+		return lines[i].host+lines[i].dn < lines[j].host+lines[j].dn
+	})
+
+	fmt.Printf("availability %s - %s (%s)\n", from.Format("2006-01-02 15:04"), to.Format("2006-01-02 15:04"), *period)
+	if len(lines) == 0 {
+		fmt.Println("no outages")
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "domain\tdn\tavailability\tdowntime\toutages")
+	for _, l := range lines {
+		fmt.Fprintf(w, "%s\t%s\t%.3f%%\t%s\t%d\n", l.host, l.dn, l.availability, l.down.Round(time.Second), l.outages)
+	}
+	w.Flush()
+	return 0
+}
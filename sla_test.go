@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackSLA(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	psu := func(dn string, state int) checkObject {
+		return checkObject{Dn: dn, State: state}
+	}
+	dns := []string{"psu-1", "psu-2"}
+	cs := &checkState{}
+	for _, run := range []struct {
+		hours   int
+		objects []checkObject
+		dns     []string
+	}{
+		{0, []checkObject{psu("psu-1", 0), psu("psu-2", 0)}, dns},
+		{10, []checkObject{psu("psu-1", 0), psu("psu-2", 1)}, dns},
+		{12, []checkObject{psu("psu-1", 0), psu("psu-2", 2)}, dns},
+		{16, []checkObject{psu("psu-1", 0), psu("psu-2", 0)}, dns},
+		{20, []checkObject{psu("psu-1", 2)}, []string{"psu-1"}},
+		{24, nil, nil}, // psu-1 removed: end of its outage
+	} {
+		cs.trackSLA(run.objects, run.dns, t0.Add(time.Duration(run.hours)*time.Hour))
+	}
+
+	rec := cs.SLA["psu-2"]
+	if len(rec.Outages) != 1 || rec.Outages[0].State != 2 || rec.Outages[0].End == nil {
+		t.Fatalf("psu-2: outages %+v", rec.Outages)
+	}
+	down, observed, n := rec.availability(t0, t0.Add(24*time.Hour))
+	if down != 6*time.Hour || observed != 24*time.Hour || n != 1 {
+		t.Errorf("psu-2: down %s of %s, %d outages, want 6h0m0s of 24h0m0s, 1", down, observed, n)
+	}
+	// period starting in the middle of the outage
+	if down, _, _ := rec.availability(t0.Add(14*time.Hour), t0.Add(24*time.Hour)); down != 2*time.Hour {
+		t.Errorf("psu-2: down %s from 14h, want 2h0m0s", down)
+	}
+	if down, _, _ := cs.SLA["psu-1"].availability(t0, t0.Add(48*time.Hour)); down != 4*time.Hour {
+		t.Errorf("psu-1: down %s, want 4h0m0s", down)
+	}
+
+	// outages older than the retention are removed, then the object
+	cs.trackSLA(nil, nil, t0.Add(slaRetention+25*time.Hour))
+	if len(cs.SLA) != 0 {
+		t.Errorf("after the retention: %d objects", len(cs.SLA))
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for s, want := range map[string]time.Duration{"30d": 720 * time.Hour, "12h": 12 * time.Hour, "0d": 0, "x": 0, "-1h": 0} {
+		got, err := parsePeriod(s)
+		if got != want || (want == 0) != (err != nil) {
+			t.Errorf("parsePeriod(%q) = %s, %v, want %s", s, got, err, want)
+		}
+	}
+}
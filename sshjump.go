@@ -0,0 +1,64 @@
+package main
+
+// SSH jump host of flag -ssh-jump [<user>@]<host>[:<port>]: the HTTPS
+// requests of the XML API and Redfish are tunneled through an SSH connection
+// to the bastion host (like ssh -J), for CIMC and UCS Manager networks only
+// reachable through a bastion host without a HTTP proxy. The TLS connection
+// is end to end, the bastion host only forwards TCP (AllowTcpForwarding).
+//
+//	check_cisco_ucs -H 10.10.1.7 -ssh-jump nagios@bastion.example.com -ssh-key /etc/nagios/bastion_ed25519 ...
+//
+// Authentication with the key of -ssh-key, default ~/.ssh/id_ed25519,
+// id_ecdsa or id_rsa (without passphrase), and the keys of the ssh-agent of
+// SSH_AUTH_SOCK. The host key of the bastion host has to be in
+// ~/.ssh/known_hosts. The default user is the user running the check, the
+// default port 22. Needs the build tag sshjump, golang.org/x/crypto is only
+// linked into this build:
+//
+//	go build -tags sshjump
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"strings"
+)
+
+var (
+	sshJump    string
+	sshJumpKey string
+)
+
+// parseSSHJump returns the user and the address of -ssh-jump
+func parseSSHJump(spec string) (string, string, error) {
+	name, host := "", spec
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		name, host = spec[:i], spec[i+1:]
+	}
+	if len(host) == 0 {
+		return "", "", fmt.Errorf("flag -ssh-jump: no host in %q, expected [<user>@]<host>[:<port>]", spec)
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+	}
+	if len(name) == 0 {
+		u, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("flag -ssh-jump: no user: %v", err)
+		}
+		name = u.Username
+	}
+	return name, host, nil
+}
+
+// validateSSHJump checks flag -ssh-jump
+func validateSSHJump() error {
+	if len(sshJump) == 0 {
+		return nil
+	}
+	if !sshJumpBuild {
+		return fmt.Errorf("flag -ssh-jump: built without SSH support, build with -tags sshjump")
+	}
+	_, _, err := parseSSHJump(sshJump)
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !sshjump
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// sshJumpBuild is false without the build tag sshjump, see sshjump.go
+const sshJumpBuild = false
+
+func sshJumpDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	return nil, fmt.Errorf("built without SSH support, build with -tags sshjump")
+}
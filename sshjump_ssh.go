@@ -0,0 +1,126 @@
+//go:build sshjump
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	sshagent "golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshJumpBuild is true with the build tag sshjump, see sshjump.go
+const sshJumpBuild = true
+
+// the SSH connection to the jump host, shared by the requests of the run (and
+// the runs of -daemon and the agent), reconnected after an error
+var (
+	jumpMu     sync.Mutex
+	jumpClient *ssh.Client
+)
+
+// sshSigners returns the keys of -ssh-key or the default key files and of the ssh-agent
+func sshSigners() ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	home, _ := os.UserHomeDir()
+	files := []string{sshJumpKey}
+	if len(sshJumpKey) == 0 {
+		files = []string{
+			filepath.Join(home, ".ssh", "id_ed25519"),
+			filepath.Join(home, ".ssh", "id_ecdsa"),
+			filepath.Join(home, ".ssh", "id_rsa"),
+		}
+	}
+	for _, f := range files {
+		buf, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) && len(sshJumpKey) == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(buf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f, err)
+		}
+		signers = append(signers, signer)
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); len(sock) > 0 {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			debugPrintf(1, "ssh-agent %s not reachable: %v\n", sock, err)
+		} else {
+			agentSigners, err := sshagent.NewClient(conn).Signers()
+			if err != nil {
+				debugPrintf(1, "ssh-agent %s: %v\n", sock, err)
+			}
+			signers = append(signers, agentSigners...)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no SSH key, use -ssh-key or an ssh-agent")
+	}
+	return signers, nil
+}
+
+// connectJump connects and authenticates to the jump host of -ssh-jump
+func connectJump() (*ssh.Client, error) {
+	name, addr, err := parseSSHJump(sshJump)
+	if err != nil {
+		return nil, err
+	}
+	signers, err := sshSigners()
+	if err != nil {
+		return nil, err
+	}
+	home, _ := os.UserHomeDir()
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+	debugPrintf(2, "ssh jump host %s@%s\n", name, addr)
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            name,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostKeys,
+		Timeout:         10 * time.Second,
+	})
+}
+
+// sshJumpDial opens a TCP connection through the jump host
+func sshJumpDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	jumpMu.Lock()
+	if jumpClient == nil {
+		client, err := connectJump()
+		if err != nil {
+			jumpMu.Unlock()
+			return nil, fmt.Errorf("ssh jump host %s: %v", sshJump, err)
+		}
+		jumpClient = client
+	}
+	client := jumpClient
+	jumpMu.Unlock()
+
+	conn, err := client.DialContext(ctx, network, addr)
+	if _, rejected := err.(*ssh.OpenChannelError); err != nil && !rejected {
+		// the connection to the jump host failed, not only the forwarding
+		jumpMu.Lock()
+		if jumpClient == client {
+			client.Close()
+			jumpClient = nil
+		}
+		jumpMu.Unlock()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ssh jump host %s: %v", sshJump, err)
+	}
+	return conn, nil
+}
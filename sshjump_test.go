@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestParseSSHJump(t *testing.T) {
+	for _, tc := range []struct{ spec, user, addr string }{
+		{"nagios@bastion.example.com", "nagios", "bastion.example.com:22"},
+		{"nagios@bastion.example.com:2222", "nagios", "bastion.example.com:2222"},
+		{"nagios@[2001:db8::1]", "nagios", "[2001:db8::1]:22"},
+		{"nagios@[2001:db8::1]:2222", "nagios", "[2001:db8::1]:2222"},
+	} {
+		user, addr, err := parseSSHJump(tc.spec)
+		if err != nil || user != tc.user || addr != tc.addr {
+			t.Errorf("%s: got %s %s %v, want %s %s", tc.spec, user, addr, err, tc.user, tc.addr)
+		}
+	}
+	if _, _, err := parseSSHJump("nagios@"); err == nil {
+		t.Errorf("no host: no error")
+	}
+	if user, _, err := parseSSHJump("bastion"); err != nil || len(user) == 0 {
+		t.Errorf("default user: got %q, %v", user, err)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+// State kept between plugin runs in the JSON file given by flag -state-file.
+// One file can be shared by several checks, every check has its own entry
+// keyed by host and query. A check saving its entry holds the lock file
+// <file>.lock, so checks running at the same time don't overwrite each
+// others entries, a lock file older than stateLockTimeout is left over by a
+// killed run and removed.
+//
+// Instead of a file -state-file can name a Redis server or SQLite database,
+// so the pollers of a HA pair or distributed pollers share the state and
+// compute the same NEW objects, hysteresis and rotations:
+//
+//	-state-file redis://:secret@redis.example.com:6379/2
+//	-state-file sqlite:///var/lib/nagios/check_cisco_ucs.db
+//
+// Redis keeps every check in its own key (check_cisco_ucs:<check key>),
+// SQLite in a row of table check_state, see stateredis.go and statesqlite.go.
+// The SQLite store needs the build tag sqlite (go build -tags sqlite, cgo).
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateLockTimeout is the maximum wait for the lock file of the state file
+const stateLockTimeout = 10 * time.Second
+
+type checkState struct {
+	Updated time.Time `json:"updated"`
+	Seen    []string  `json:"seen"` // dn of the objects found in the last run
+
+	TechSupport time.Time `json:"techSupport,omitempty"` // last tech-support collection
+
+	Thresholds map[string]map[string]int `json:"thresholds,omitempty"` // dn and attribute: WARN or CRIT of the last run
+
+	Cursor string `json:"cursor,omitempty"` // last dn of the subset of -sample or -limit
+	Cycle  int    `json:"cycle,omitempty"`  // runs of -rotate-group
+
+	SLA map[string]*slaRecord `json:"sla,omitempty"` // outages per dn of -sla
+}
+
+type pluginState map[string]*checkState
+
+// StateStore loads and saves the state of the checks
+type StateStore interface {
+	// Load returns the state of a check, a new empty state if there is none
+	Load(key string) (*checkState, error)
+	Save(key string, cs *checkState) error
+	// Keys returns the keys of all checks in the store
+	Keys() ([]string, error)
+	Close()
+}
+
+// fileStore is the state file
+type fileStore struct {
+	filename string
+}
+
+// stateStores open the state stores of the URL schemes of -state-file, a
+// location without one of them is a file
+var stateStores = map[string]func(location string) (StateStore, error){
+	"redis":  openRedisStore,
+	"sqlite": openSqliteStore,
+}
+
+// openStateStore opens the state store of the location
+func openStateStore(location string) (StateStore, error) {
+	if i := strings.Index(location, ":"); i > 0 {
+		if open, ok := stateStores[location[:i]]; ok {
+			return open(location)
+		}
+	}
+	return &fileStore{filename: location}, nil
+}
+
+// loadCheckState returns the state of a check of the store at location
+func loadCheckState(location, key string) (*checkState, error) {
+	store, err := openStateStore(location)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.Load(key)
+}
+
+// checkStateKey identifies the current check in the state file, with -org
+// the organization, with -rotate-group the group of the run
+func checkStateKey() string {
+	key := strings.Join([]string{hostName(), queryType, dnOrClass, class, hierarchical, propertyFilter}, "|")
+	if len(orgScope) > 0 {
+		key += "|" + orgScope
+	}
+	if rotateCount > 0 {
+		key += fmt.Sprintf("|group %d/%d", rotateIndex+1, rotateCount)
+	}
+	return key
+}
+
+func loadState(filename string) (pluginState, error) {
+	st := make(pluginState)
+	buf, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return st, nil
+	}
+	if err := json.Unmarshal(buf, &st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// get returns the state of a check, a new empty state if there is none
+func (st pluginState) get(key string) *checkState {
+	if cs, ok := st[key]; ok && cs != nil {
+		return cs
+	}
+	return &checkState{}
+}
+
+// newObjects returns the objects not seen in the previous run. In the first
+// run there is nothing to compare with and no object is new.
+func (cs *checkState) newObjects(dns []string) map[string]bool {
+	newObjs := make(map[string]bool)
+	if cs.Updated.IsZero() {
+		return newObjs
+	}
+	seen := make(map[string]bool)
+	for _, dn := range cs.Seen {
+		seen[dn] = true
+	}
+	for _, dn := range dns {
+		if !seen[dn] {
+			newObjs[dn] = true
+		}
+	}
+	return newObjs
+}
+
+// saveCheckState writes the state of one check to the store at location
+func saveCheckState(location, key string, cs *checkState) error {
+	store, err := openStateStore(location)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	cs.Updated = time.Now()
+	return store.Save(key, cs)
+}
+
+func (s *fileStore) Load(key string) (*checkState, error) {
+	st, err := loadState(s.filename)
+	if err != nil {
+		return nil, err
+	}
+	return st.get(key), nil
+}
+
+// Save writes the state of one check. The file is read again right before
+// writing, under the lock file, so that checks sharing the file don't lose
+// each others state.
+func (s *fileStore) Save(key string, cs *checkState) error {
+	unlock, err := lockStateFile(s.filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	st, err := loadState(s.filename)
+	if err != nil {
+		return err
+	}
+	st[key] = cs
+
+	buf, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.filename, buf, 0644)
+}
+
+func (s *fileStore) Keys() ([]string, error) {
+	st, err := loadState(s.filename)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *fileStore) Close() {}
+
+// lockStateFile creates the lock file of the state file and returns the
+// function removing it, waiting at most stateLockTimeout for another check
+func lockStateFile(filename string) (func(), error) {
+	lock := filename + ".lock"
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > stateLockTimeout {
+			debugPrintf(2, "state file: stale lock file %s removed\n", lock)
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("state file %s locked by another check, lock file %s", filename, lock)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// writeFileAtomic writes a file via a temporary file and rename, so readers
+// never see a partially written file
+func writeFileAtomic(filename string, buf []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
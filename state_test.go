@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStoreConcurrentSave(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store := &fileStore{filename: filename}
+			if err := store.Save(fmt.Sprintf("ucs-%d|class|equipmentPsu", i), &checkState{Cycle: i}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	keys, err := (&fileStore{filename: filename}).Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 20 {
+		t.Errorf("%d checks in the state file, want 20: %v", len(keys), keys)
+	}
+	if _, err := os.Stat(filename + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left: %v", err)
+	}
+}
+
+func TestFileStoreStaleLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(filename+".lock", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * stateLockTimeout)
+	os.Chtimes(filename+".lock", old, old)
+	if err := (&fileStore{filename: filename}).Save("ucs|class|equipmentPsu", &checkState{}); err != nil {
+		t.Fatal(err)
+	}
+}
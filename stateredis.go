@@ -0,0 +1,222 @@
+package main
+
+// State store Redis of -state-file redis://[:<password>@]<host>[:<port>][/<db>],
+// shared by the pollers of a HA pair or distributed pollers. The state of a
+// check is a JSON string in the key check_cisco_ucs:<check key>, each run
+// reads and writes only its own key. Speaks the Redis protocol (RESP) itself,
+// AUTH with the password (and the user of Redis 6 ACLs) of the URL, SELECT of
+// the database number.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redisKeyPrefix = "check_cisco_ucs:"
+
+type redisStore struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func openRedisStore(location string) (StateStore, error) {
+	s, err := dialRedis(location)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// dialRedis connects to the Redis server of the URL, also used by the leases
+// of -lease
+func dialRedis(location string) (*redisStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL %q: %v", location, err)
+	}
+	addr := u.Host
+	if len(u.Port()) == 0 {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	s := &redisStore{conn: conn, r: bufio.NewReader(conn)}
+
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if name := u.User.Username(); len(name) > 0 {
+			args = []string{"AUTH", name, password}
+		}
+		if _, err := s.do(args...); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); len(db) > 0 {
+		if _, err := strconv.Atoi(db); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("invalid Redis database %q of %s", db, u.Redacted())
+		}
+		if _, err := s.do("SELECT", db); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// do sends a command and returns the reply, nil if the reply is nil
+func (s *redisStore) do(args ...string) ([]byte, error) {
+	if err := s.send(args...); err != nil {
+		return nil, err
+	}
+	return s.reply(args[0])
+}
+
+// send sends a command
+func (s *redisStore) send(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := s.conn.Write([]byte(b.String()))
+	return err
+}
+
+// readLine returns the next line of a reply to the command
+func (s *redisStore) readLine(cmd string) (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return "", fmt.Errorf("redis: empty reply to %s", cmd)
+	}
+	return line, nil
+}
+
+// arrayLen returns the number of elements of an array reply to the command
+func (s *redisStore) arrayLen(cmd string) (int, error) {
+	line, err := s.readLine(cmd)
+	if err != nil {
+		return 0, err
+	}
+	if line[0] == '-' {
+		return 0, fmt.Errorf("redis: %s", line[1:])
+	}
+	n, err := strconv.Atoi(line[1:])
+	if line[0] != '*' || err != nil {
+		return 0, fmt.Errorf("redis: unexpected reply %q to %s", line, cmd)
+	}
+	return n, nil
+}
+
+// reply returns the next reply to the command, nil if the reply is nil
+func (s *redisStore) reply(cmd string) ([]byte, error) {
+	line, err := s.readLine(cmd)
+	if err != nil {
+		return nil, err
+	}
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply %q to %s", line, cmd)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(s.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q to %s", line, cmd)
+}
+
+func (s *redisStore) Load(key string) (*checkState, error) {
+	buf, err := s.do("GET", redisKeyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		return &checkState{}, nil
+	}
+	cs := &checkState{}
+	if err := json.Unmarshal(buf, cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func (s *redisStore) Save(key string, cs *checkState) error {
+	buf, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+	_, err = s.do("SET", redisKeyPrefix+key, string(buf))
+	return err
+}
+
+// Keys returns the checks of the keys check_cisco_ucs:<check key>, the
+// leases of -lease are no checks
+func (s *redisStore) Keys() ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		if err := s.send("SCAN", cursor, "MATCH", redisKeyPrefix+"*", "COUNT", "1000"); err != nil {
+			return nil, err
+		}
+		n, err := s.arrayLen("SCAN")
+		if err != nil {
+			return nil, err
+		}
+		if n != 2 {
+			return nil, fmt.Errorf("redis: unexpected reply to SCAN, %d elements", n)
+		}
+		next, err := s.reply("SCAN")
+		if err != nil {
+			return nil, err
+		}
+		n, err = s.arrayLen("SCAN")
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			k, err := s.reply("SCAN")
+			if err != nil {
+				return nil, err
+			}
+			if key := strings.TrimPrefix(string(k), redisKeyPrefix); !strings.HasPrefix(key, "lease:") {
+				keys = append(keys, key)
+			}
+		}
+		if cursor = string(next); cursor == "0" {
+			break
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *redisStore) Close() {
+	s.conn.Close()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeRedis answers AUTH, SELECT, GET, SET and SCAN of one connection at a time
+func fakeRedis(t *testing.T, password string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	keys := make(map[string]string)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			for {
+				var n int
+				if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+					break
+				}
+				args := make([]string, n)
+				for i := range args {
+					var l int
+					fmt.Fscanf(r, "$%d\r\n", &l)
+					buf := make([]byte, l+2)
+					io.ReadFull(r, buf)
+					args[i] = string(buf[:l])
+				}
+				switch {
+				case args[0] == "AUTH" && args[len(args)-1] != password:
+					io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+				case args[0] == "GET":
+					v, ok := keys[args[1]]
+					if !ok {
+						io.WriteString(conn, "$-1\r\n")
+						continue
+					}
+					io.WriteString(conn, "$"+strconv.Itoa(len(v))+"\r\n"+v+"\r\n")
+				case args[0] == "SCAN":
+					var matched []string
+					for k := range keys {
+						if strings.HasPrefix(k, strings.TrimSuffix(args[3], "*")) {
+							matched = append(matched, "$"+strconv.Itoa(len(k))+"\r\n"+k+"\r\n")
+						}
+					}
+					io.WriteString(conn, "*2\r\n$1\r\n0\r\n*"+strconv.Itoa(len(matched))+"\r\n"+strings.Join(matched, ""))
+				case args[0] == "SET":
+					keys[args[1]] = args[2]
+					fallthrough
+				default:
+					io.WriteString(conn, "+OK\r\n")
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisStore(t *testing.T) {
+	addr := fakeRedis(t, "secret")
+	location := "redis://:secret@" + addr + "/2"
+
+	cs, err := loadCheckState(location, "ucs|class|equipmentPsu")
+	if err != nil || !cs.Updated.IsZero() {
+		t.Fatalf("first run: got %+v, %v", cs, err)
+	}
+	if err := saveCheckState(location, "ucs|class|equipmentPsu", &checkState{Seen: []string{"sys/chassis-1/psu-1"}, Cycle: 3}); err != nil {
+		t.Fatal(err)
+	}
+	cs, err = loadCheckState(location, "ucs|class|equipmentPsu")
+	if err != nil || cs.Cycle != 3 || strings.Join(cs.Seen, " ") != "sys/chassis-1/psu-1" {
+		t.Errorf("got %+v, %v", cs, err)
+	}
+
+	store, err := dialRedis(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.do("SET", redisKeyPrefix+"lease:ucs", "poller-1")
+	keys, err := store.Keys()
+	store.Close()
+	if err != nil || strings.Join(keys, " ") != "ucs|class|equipmentPsu" {
+		t.Errorf("keys %q, %v, want the check without the lease", keys, err)
+	}
+
+	if _, err := loadCheckState("redis://:wrong@"+addr, "ucs"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("wrong password: got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+//go:build sqlite
+
+package main
+
+// State store SQLite of -state-file sqlite:<file> (sqlite:///var/lib/... for
+// an absolute path), a database on a shared file system or of several checks
+// of a poller. The state of a check is a JSON row of table check_state,
+// created on the first run. Waits up to 5s for a lock of another process.
+// Needs cgo and the build tag sqlite, the driver is only linked into this
+// build:
+//
+//	go build -tags sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSqliteStore(location string) (StateStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQLite URL %q: %v", location, err)
+	}
+	filename := u.Opaque
+	if len(filename) == 0 {
+		filename = u.Path
+	}
+	if len(filename) == 0 {
+		return nil, fmt.Errorf("invalid SQLite URL %q, expected sqlite:<file>", location)
+	}
+	db, err := sql.Open("sqlite3", "file:"+filename+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS check_state (key TEXT PRIMARY KEY, state TEXT NOT NULL)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Load(key string) (*checkState, error) {
+	var buf string
+	err := s.db.QueryRow(`SELECT state FROM check_state WHERE key = ?`, key).Scan(&buf)
+	if err == sql.ErrNoRows {
+		return &checkState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cs := &checkState{}
+	if err := json.Unmarshal([]byte(buf), cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func (s *sqliteStore) Save(key string, cs *checkState) error {
+	buf, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO check_state (key, state) VALUES (?, ?)`, key, string(buf))
+	return err
+}
+
+func (s *sqliteStore) Keys() ([]string, error) {
+	rows, err := s.db.Query(`SELECT key FROM check_state ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *sqliteStore) Close() {
+	s.db.Close()
+}
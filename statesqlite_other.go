@@ -0,0 +1,10 @@
+//go:build !sqlite
+
+package main
+
+import "fmt"
+
+// openSqliteStore fails without the build tag sqlite, see statesqlite.go
+func openSqliteStore(location string) (StateStore, error) {
+	return nil, fmt.Errorf("state store %s: built without SQLite support, build with -tags sqlite", location)
+}
@@ -0,0 +1,196 @@
+package main
+
+// Subcommands: check_cisco_ucs <command> [<args>] [flags]
+// Without a subcommand the plugin runs a check as before.
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+)
+
+type subcommand struct {
+	usage string
+	descr string
+	run   func(args []string) int
+}
+
+var subcommands map[string]*subcommand
+
+// connectionFlags are the global flags available in all subcommands
+var connectionFlags = []string{"H", "u", "p", "ufile", "pfile", "d", "M", "tls-min", "k", "cafile", "fips", "ciphers", "P", "ssh-jump", "ssh-key", "via-agent", "user-agent", "header", "hmac-file", "hmac-header",
+	"audit-log", "audit-syslog", "session-cache"}
+
+func init() {
+	subcommands = map[string]*subcommand{
+		"led": {
+			usage: "led on|off -H <ip_addr> -u <username> -p <password> -dn <dn>",
+			descr: "switch the locator LED of a rack server or blade on or off",
+			run:   runLed,
+		},
+		"power": {
+			usage: "power status|cycle|reset -H <ip_addr> -u <username> -p <password> -dn <dn> [-yes]",
+			descr: "show the power state of a rack server or blade, cycle or reset it (needs -yes)",
+			run:   runPower,
+		},
+		"agent": {
+			usage: "agent -listen <socket_or_addr> [-idle-timeout <duration>] [-max-concurrent-scrapes <n>] [-scrape-timeout <duration>] [-service-name <name>] [-event-log <source>] [-heartbeat-interval <duration> -command-file <file>] [-request-log <n>] [-pprof <addr>]",
+			descr: "run the agent keeping one XML API session per UCS domain for the checks, see agent.go",
+			run:   runAgent,
+		},
+		"session": {
+			usage: "session stats [-session-cache <dir>] [-via-agent <socket_or_addr>]",
+			descr: "show the sessions of the session cache and the agent: age, refreshes, logins and login rate per UCS domain",
+			run:   runSession,
+		},
+		"faults": {
+			usage: "faults -H <ip_addr> -u <username> -p <password> [-follow] [-interval <duration>] [-no-color]",
+			descr: "print the active faults, with -follow the new, changed and cleared faults as they happen, see faults.go",
+			run:   runFaults,
+		},
+		"sign-config": {
+			usage: "sign-config -key <private_key> <file> ...",
+			descr: "write the Ed25519 signature <file>.sig of config files for -config-sig, see configsig.go",
+			run:   runSignConfig,
+		},
+		"report": {
+			usage: "report sla -state-file <file> [-period <days>d] [-host <domain>] [-all]",
+			descr: "print the availability of the objects tracked with -sla: downtime and outages per dn in the period, see sla.go",
+			run:   runReport,
+		},
+		"examples": {
+			usage: "examples [<template> ...]",
+			descr: "print the class, attributes, expect string and states of the built-in check templates (-check)",
+			run:   runExamples,
+		},
+		"classes": {
+			usage: "classes",
+			descr: "print the classes of the check templates with a description, the suggested attributes and expect string",
+			run:   runClasses,
+		},
+		"completion": {
+			usage: "completion bash|zsh|fish",
+			descr: "print the shell completion script of flags, subcommands, check templates and UCS class names",
+			run:   runCompletion,
+		},
+	}
+
+	flag.Usage = func() {
+		name := path.Base(os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", name)
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nsubcommands:\n")
+		var names []string
+		for n := range subcommands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %s %s\n    \t%s\n", name, subcommands[n].usage, subcommands[n].descr)
+		}
+	}
+}
+
+// newFlagSet returns the flag set of a subcommand including the connection flags
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(path.Base(os.Args[0])+" "+name, flag.ContinueOnError)
+	for _, n := range connectionFlags {
+		f := flag.Lookup(n)
+		fs.Var(f.Value, f.Name, f.Usage)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s\n", path.Base(os.Args[0]), subcommands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags and positional arguments in any order and returns
+// the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		parseFlags(fs, args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func runLed(args []string) int {
+	fs := newFlagSet("led")
+	ledDn := fs.String("dn", "", "dn of the rack server or blade, examples: sys/rack-unit-1 or sys/chassis-1/blade-2")
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 || (pos[0] != "on" && pos[0] != "off") || len(*ledDn) == 0 {
+		fs.Usage()
+		return 3
+	}
+
+	client, url := apiClient()
+	cookie := login(client, url)
+	err := setLocatorLed(client, url, cookie, *ledDn, pos[0])
+	logout(client, url, cookie)
+
+	if err != nil {
+		fmt.Printf("UNKNOWN - locator LED of %s: %v\n", *ledDn, err)
+		return 3
+	}
+	fmt.Printf("OK - locator LED of %s switched %s\n", *ledDn, pos[0])
+	return 0
+}
+
+// powerActions maps the power subcommand actions to adminPower values
+var powerActions = map[string]string{
+	"cycle": "cycle-immediate",
+	"reset": "hard-reset-immediate",
+}
+
+func runPower(args []string) int {
+	fs := newFlagSet("power")
+	powerDn := fs.String("dn", "", "dn of the rack server or blade, examples: sys/rack-unit-1 or sys/chassis-1/blade-2")
+	yes := fs.Bool("yes", false, "confirm power cycle or reset, without it the action is only shown")
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 || len(*powerDn) == 0 {
+		fs.Usage()
+		return 3
+	}
+	action := pos[0]
+	if _, ok := powerActions[action]; !ok && action != "status" {
+		fs.Usage()
+		return 3
+	}
+	if action != "status" && !*yes {
+		fmt.Printf("UNKNOWN - power %s of %s not confirmed, add flag -yes to set adminPower %s\n", action, *powerDn, powerActions[action])
+		return 3
+	}
+
+	client, url := apiClient()
+	cookie := login(client, url)
+
+	var (
+		operPower, adminPower string
+		err                   error
+	)
+	if action == "status" {
+		operPower, adminPower, err = powerState(client, url, cookie, *powerDn)
+	} else {
+		err = setAdminPower(client, url, cookie, *powerDn, powerActions[action])
+	}
+	logout(client, url, cookie)
+
+	if err != nil {
+		fmt.Printf("UNKNOWN - power %s of %s: %v\n", action, *powerDn, err)
+		return 3
+	}
+	if action == "status" {
+		fmt.Printf("OK - power state of %s: operPower %s, adminPower %s\n", *powerDn, operPower, adminPower)
+	} else {
+		fmt.Printf("OK - power %s of %s: adminPower set to %s\n", action, *powerDn, powerActions[action])
+	}
+	return 0
+}
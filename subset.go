@@ -0,0 +1,118 @@
+package main
+
+// Subset of flags -sample <percent>% and -limit <n>: only a part of the
+// objects is evaluated per run, for trending and perfdata checks of very
+// large classes (e.g. the storageLocalDisk or memoryUnit objects of a big
+// domain) where the full coverage every run is unnecessary. The objects are
+// ordered by dn and the window rotates, every run continues after the last
+// dn of the previous run, so all objects are covered over time:
+//
+//	-q memoryUnit -a "dn operState temperature" -perfdata temperature -limit 100 -state-file /var/tmp/ucs.state
+//	-q storageLocalDisk -a "dn pdStatus" -sample 10% -state-file /var/tmp/ucs.state
+//
+// With 10% every object is evaluated at least every 10 runs. The position of
+// the window is kept in -state-file, which is required. New or removed
+// objects don't disturb the rotation. The subset is taken after the attributes
+// comparing the objects (-imbalance, -consistent), before -probe. Not to be
+// confused with -samples, several readings of all objects.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	samplePercent string
+	limitObjects  int
+
+	subsetDns    []string // dn of all objects if a subset was evaluated
+	subsetCursor string   // last dn of the subset, the next run continues after it
+	subsetNote   string   // long output line of the subset
+)
+
+// parseSamplePercent parses the percentage of -sample, e.g. 10%
+func parseSamplePercent(s string) (float64, error) {
+	p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || !strings.HasSuffix(s, "%") || p <= 0 || p > 100 {
+		return 0, fmt.Errorf("invalid -sample %q, expected a percentage above 0, e.g. 10%%", s)
+	}
+	return p, nil
+}
+
+// validateSubset checks flags -sample and -limit
+func validateSubset() error {
+	if len(samplePercent) == 0 && limitObjects == 0 {
+		return nil
+	}
+	if len(samplePercent) > 0 {
+		if _, err := parseSamplePercent(samplePercent); err != nil {
+			return err
+		}
+	}
+	switch {
+	case limitObjects < 0:
+		return fmt.Errorf("flag -limit must not be negative")
+	case len(samplePercent) > 0 && limitObjects > 0:
+		return fmt.Errorf("flags -sample and -limit can't be used together")
+	case len(stateFile) == 0:
+		return fmt.Errorf("flags -sample and -limit require -state-file, it keeps the rotation")
+	case len(requireQuorum) > 0:
+		return fmt.Errorf("flags -sample and -limit can't be used with -require, the quorum needs all objects")
+	case len(autoAck) > 0:
+		return fmt.Errorf("flags -sample and -limit can't be used with -auto-ack, faults not evaluated would be acknowledged")
+	}
+	return nil
+}
+
+// subsetSize returns the number of objects of -sample or -limit evaluated of n
+func subsetSize(n int) int {
+	if limitObjects > 0 {
+		return limitObjects
+	}
+	p, _ := parseSamplePercent(samplePercent)
+	return int(math.Ceil(float64(n) * p / 100))
+}
+
+// subsetObjects returns the objects of the window continuing after the last dn
+// of the previous run, in their original order
+func subsetObjects(objects []managedObject) ([]managedObject, error) {
+	subsetDns, subsetCursor, subsetNote = nil, "", ""
+	if len(samplePercent) == 0 && limitObjects == 0 {
+		return objects, nil
+	}
+	size := subsetSize(len(objects))
+	if size >= len(objects) {
+		return objects, nil
+	}
+	cs, err := loadCheckState(stateFile, checkStateKey())
+	if err != nil {
+		return nil, fmt.Errorf("state file error: %v", err)
+	}
+	cursor := cs.Cursor
+
+	dns := make([]string, len(objects))
+	for i, obj := range objects {
+		dns[i] = obj.Dn
+	}
+	sort.Strings(dns)
+	start := sort.Search(len(dns), func(i int) bool { return dns[i] > cursor })
+	selected := make(map[string]bool)
+	for i := 0; i < size; i++ {
+		subsetCursor = dns[(start+i)%len(dns)]
+		selected[subsetCursor] = true
+	}
+	debugPrintf(2, "subset: %d of %d objects after %q, up to %q\n", size, len(dns), cursor, subsetCursor)
+
+	var subset []managedObject
+	for _, obj := range objects {
+		if selected[obj.Dn] {
+			subset = append(subset, obj)
+		}
+	}
+	subsetDns = dns
+	subsetNote = fmt.Sprintf("\nsubset: %d of %d objects evaluated, %s to %s, rotating", len(subset), len(dns), dns[start%len(dns)], subsetCursor)
+	return subset, nil
+}
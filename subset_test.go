@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubsetRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(s string, l int) { stateFile, limitObjects = s, l }(stateFile, limitObjects)
+	stateFile, limitObjects = filepath.Join(dir, "state.json"), 2
+
+	var objects []managedObject
+	for _, dn := range []string{"sys/chassis-1/psu-3", "sys/chassis-1/psu-1", "sys/chassis-1/psu-4", "sys/chassis-1/psu-2", "sys/chassis-1/psu-5"} {
+		objects = append(objects, managedObject{Dn: dn})
+	}
+	want := [][]string{
+		{"sys/chassis-1/psu-1", "sys/chassis-1/psu-2"},
+		{"sys/chassis-1/psu-3", "sys/chassis-1/psu-4"},
+		{"sys/chassis-1/psu-1", "sys/chassis-1/psu-5"}, // original order
+	}
+	for run, w := range want {
+		subset, err := subsetObjects(objects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, obj := range subset {
+			got = append(got, obj.Dn)
+		}
+		if strings.Join(got, " ") != strings.Join(w, " ") {
+			t.Errorf("run %d: got %v, want %v", run+1, got, w)
+		}
+		if err := saveCheckState(stateFile, checkStateKey(), &checkState{Cursor: subsetCursor}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+// Output format table (-output table) for manual runs: the status line and
+// the objects as an aligned table with a column per attribute, the rows
+// colored by their state. It is the default if stdout is a terminal and
+// -output isn't given, Nagios never runs the plugin on a terminal. Colors are
+// off with the environment variable NO_COLOR (https://no-color.org).
+//
+//	STATE  dn                   model     operState
+//	OK     sys/chassis-1/psu-1  UCSB-PSU  operable
+//	CRIT   sys/chassis-1/psu-2  UCSB-PSU  inoperable
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// stateColors are the ANSI colors of the states
+var stateColors = map[int]string{0: "\x1b[32m", 1: "\x1b[33m", 2: "\x1b[1;31m", 3: "\x1b[35m"}
+
+// useColor returns true if the output is colored: a terminal without NO_COLOR
+func useColor() bool {
+	return isTerminal() && len(os.Getenv("NO_COLOR")) == 0
+}
+
+// isFlagSet returns true if the flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// colored returns s in the color of the state
+func colored(s string, state int, color bool) string {
+	if !color {
+		return s
+	}
+	return stateColors[state] + s + "\x1b[0m"
+}
+
+// Render prints the status line and the table of the objects of every result
+func (tableRenderer) Render(w io.Writer, results []*checkResult) error {
+	color := useColor()
+	for i, res := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		name := ""
+		if len(res.Profile) > 0 {
+			name = "[" + res.Profile + "] "
+		} else if isBatch() {
+			name = "[" + res.Label + "] "
+		}
+		head, notes := splitOutput(res)
+		fmt.Fprintln(w, colored(name+statusLine(res.Status)+" "+head, res.State, color))
+		if len(notes) > 0 {
+			fmt.Fprintln(w, notes)
+		}
+		if len(res.Objects) == 0 {
+			continue
+		}
+
+		columns := append([]string{"STATE"}, res.Attributes...)
+		rows := [][]string{columns}
+		for _, obj := range res.Objects {
+			row := []string{statePrefix[obj.State]}
+			if obj.New {
+				row[0] += " NEW"
+			}
+			for _, a := range res.Attributes {
+				row = append(row, obj.Attrs[a])
+			}
+			rows = append(rows, row)
+		}
+		widths := make([]int, len(columns))
+		for _, row := range rows {
+			for c, v := range row {
+				if n := utf8.RuneCountInString(v); n > widths[c] {
+					widths[c] = n
+				}
+			}
+		}
+		fmt.Fprintln(w)
+		for r, row := range rows {
+			var cells []string
+			for c, v := range row {
+				if c < len(row)-1 {
+					v += strings.Repeat(" ", widths[c]-utf8.RuneCountInString(v))
+				}
+				cells = append(cells, v)
+			}
+			line := strings.Join(cells, "  ")
+			if r > 0 {
+				line = colored(line, res.Objects[r-1].State, color)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+// splitOutput returns the status line with the summary and the notes of the
+// plugin output, without the object lines shown in the table
+func splitOutput(res *checkResult) (string, string) {
+	lines := strings.SplitN(res.Output, "\n", 2)
+	i := strings.LastIndex(res.Output, fmt.Sprintf(" (%d of %d ok", res.NumOk, res.Num))
+	if i < 0 {
+		if len(lines) == 1 {
+			return lines[0], ""
+		}
+		return lines[0], lines[1]
+	}
+	// the summary follows the last object line
+	summary := res.Output[i:]
+	notes := ""
+	if j := strings.Index(summary, "\n"); j >= 0 {
+		summary, notes = summary[:j], summary[j+1:]
+	}
+	return lines[0] + summary, notes
+}
@@ -0,0 +1,369 @@
+package main
+
+// Built-in check templates of flag -check. A template sets the check flags
+// (-t, -q, -a, -e, ...) of a common check, flags given on the command line or
+// in a profile take precedence. Subcommand *examples* and flag -explain print
+// the templates from these definitions, subcommand *classes* the classes of
+// the templates with their suggested attributes and expect strings.
+//
+// The expect strings anchor the state at the end of the line: "operable" also
+// matches "inoperable".
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type checkTemplate struct {
+	name   string
+	descr  string
+	flags  [][2]string // flag name and value, like a profile
+	expect string      // meaning of the expect string
+	needs  []string    // flags to be given with the template
+}
+
+var templates = []*checkTemplate{
+	{
+		name:   "psu",
+		descr:  "power supplies of chassis, fabric interconnects and rack servers",
+		flags:  [][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn operState"}, {"e", ",operable$"}},
+		expect: "operState operable, a removed power supply is a fault",
+	},
+	{
+		name:   "psu-imbalance",
+		descr:  "input current of the power supplies of a chassis, rack server or fabric interconnect compared to each other",
+		flags:  [][2]string{{"t", "class"}, {"q", "equipmentPsuInputStats"}, {"a", "dn current"}, {"imbalance", "current"}, {"e", "."}, {"w", "imbalance=20"}, {"c", "imbalance=35"}, {"perfdata", "current imbalance"}},
+		expect: "any value, the state is given by the thresholds of the deviation from the average of the chassis in percent",
+	},
+	{
+		name:   "psu-consistency",
+		descr:  "model and firmware of the power supplies of a chassis, rack server or fabric interconnect compared to each other",
+		flags:  [][2]string{{"t", "class"}, {"q", "equipmentPsu"}, {"a", "dn model"}, {"host-firmware", "true"}, {"consistent", "model fwVersion"}, {"e", ",yes$"}},
+		expect: "model and firmware version of the majority of the power supplies of the chassis, mixed power supplies are unsupported",
+	},
+	{
+		name:   "fan",
+		descr:  "fans of chassis, fabric interconnects and rack servers",
+		flags:  [][2]string{{"t", "class"}, {"q", "equipmentFan"}, {"a", "dn operState"}, {"e", ",operable$"}},
+		expect: "operState operable",
+	},
+	{
+		name:   "chassis",
+		descr:  "blade chassis of a UCS Manager domain",
+		flags:  [][2]string{{"t", "class"}, {"q", "equipmentChassis"}, {"a", "dn operState"}, {"e", ",operable$"}},
+		expect: "operState operable",
+	},
+	{
+		name:   "blade",
+		descr:  "blade servers of a UCS Manager domain",
+		flags:  [][2]string{{"t", "class"}, {"q", "computeBlade"}, {"a", "dn operability"}, {"e", ",operable$"}},
+		expect: "operability operable",
+	},
+	{
+		name:   "rack-unit",
+		descr:  "rack servers, CIMC or managed by UCS Manager",
+		flags:  [][2]string{{"t", "class"}, {"q", "computeRackUnit"}, {"a", "dn operability"}, {"e", ",operable$"}},
+		expect: "operability operable",
+	},
+	{
+		name:   "vic",
+		descr:  "VIC adapters of blades and rack servers, state, temperature and firmware package",
+		flags:  [][2]string{{"t", "class"}, {"q", "adaptorUnit"}, {"a", "dn model operState thermal"}, {"host-firmware", "true"}, {"e", ",operable,ok,[^,]*,[^,]*,yes$"}},
+		expect: "operState operable, thermal ok and the firmware package of the adapter is the one of its server",
+	},
+	{
+		name:   "fi",
+		descr:  "fabric interconnects of a UCS Manager domain",
+		flags:  [][2]string{{"t", "class"}, {"q", "networkElement"}, {"a", "dn operability"}, {"e", ",operable$"}},
+		expect: "operability operable",
+	},
+	{
+		name:   "fi-mgmt",
+		descr:  "management interfaces of both fabric interconnects, state and reachability of the OOB address",
+		flags:  [][2]string{{"t", "class"}, {"q", "mgmtIf"}, {"a", "dn extIp operState"}, {"probe", "extIp"}, {"e", ",up,yes$"}, {"require", "1 of 2"}},
+		expect: "operState up and the OOB address reachable, one fabric interconnect down is WARN (no management HA)",
+	},
+	{
+		name:   "controller",
+		descr:  "storage controllers of a rack server",
+		flags:  [][2]string{{"t", "class"}, {"q", "storageController"}, {"a", "id health"}, {"e", ",Good$"}},
+		expect: "health Good",
+	},
+	{
+		name:   "controller-cache",
+		descr:  "cache, patrol read, battery and foreign configuration of the storage controllers of a rack server",
+		flags:  [][2]string{{"t", "class"}, {"q", "storageController"}, {"a", "id health cacheState patrolReadState bbuDependency foreignConfigPresent"}, {"e", ",Good,(Optimal|Write Back),(Active|Stopped|Completed|Disabled),(Not Required|Satisfied),(false|no)$"}},
+		expect: "health Good, write back cache, patrol read not failed or stuck (Paused), the battery the cache depends on is present and no foreign configuration",
+	},
+	{
+		name:   "disks",
+		descr:  "physical disks of a rack server",
+		flags:  [][2]string{{"t", "class"}, {"q", "storageLocalDisk"}, {"a", "id pdStatus driveSerialNumber"}, {"e", ",(Online|Unconfigured Good|JBOD|Hot Spare),"}},
+		expect: "pdStatus Online, Unconfigured Good, JBOD or Hot Spare",
+	},
+	{
+		name:   "disk-attention",
+		descr:  "physical disks needing operator action after a prior failure, often without a fault: foreign configuration, unconfigured bad or blocked",
+		flags:  [][2]string{{"t", "class"}, {"q", "storageLocalDisk"}, {"a", "dn pdStatus health"}, {"f", "wcard:pdStatus:(?i)foreign|unconfigured[ -]bad|blocked"}, {"client-filter", "true"}, {"e", "^$"}, {"z", "true"}},
+		expect: "no disk in these states, every listed disk needs operator action (import or clear the foreign configuration, replace or make good)",
+	},
+	{
+		name:   "disk-layout",
+		descr:  "states of the physical disks of rack servers compared with the layout of -layout-file per server model",
+		flags:  [][2]string{{"t", "class"}, {"q", "storageLocalDisk"}, {"a", "dn pdStatus"}, {"e", ",compliant$"}},
+		expect: "the state of every slot as expected (e.g. Online in a RAID group), the expected state is shown otherwise",
+		needs:  []string{"layout-file"},
+	},
+	{
+		name:   "virtual-drives",
+		descr:  "RAID virtual drives of a rack server, size and hot spares of the controller",
+		flags:  [][2]string{{"t", "class"}, {"q", "storageVirtualDrive"}, {"a", "id raidLevel size stripSize vdStatus health"}, {"hot-spares", "true"}, {"e", ",Optimal,Good,"}, {"w", "hotSpares=1:"}, {"perfdata", "size stripSize hotSpares"}},
+		expect: "vdStatus Optimal and health Good, the state is also given by the lower limit of the hot spares of the controller",
+	},
+	{
+		name:   "temperature",
+		descr:  "inlet temperature of rack servers, CIMC or managed by UCS Manager",
+		flags:  [][2]string{{"t", "class"}, {"q", "computeRackUnitMbTempStats"}, {"a", "dn ambientTemp"}, {"e", "."}, {"w", "ambientTemp=35"}, {"c", "ambientTemp=40"}},
+		expect: "any value, the state is given by the thresholds in degrees Celsius",
+	},
+	{
+		name:   "firmware",
+		descr:  "running firmware versions of all components, an inventory",
+		flags:  [][2]string{{"t", "class"}, {"q", "firmwareRunning"}, {"a", "dn type version"}, {"e", "."}},
+		expect: "any version",
+	},
+	{
+		name:   "pools",
+		descr:  "usage of the MAC address pools of a UCS Manager domain",
+		flags:  [][2]string{{"t", "class"}, {"q", "macpoolPool"}, {"a", "dn size assigned"}, {"e", "."}, {"derive", "usage=assigned/size*100"}, {"w", "usage=80"}, {"c", "usage=95"}},
+		expect: "any pool, the state is given by the thresholds of the usage in percent",
+	},
+	{
+		name:   "hfp-compliance",
+		descr:  "running firmware of the blades compared with the host firmware package of their service profiles, -q computeRackUnit for rack servers",
+		flags:  [][2]string{{"t", "class"}, {"q", "computeBlade"}, {"a", "dn assignedToDn"}, {"hfp", "true"}, {"e", ",(compliant|none)$"}, {"F", "true"}},
+		expect: "hfpStatus compliant or none (no service profile or package), only the blades pending activation are listed",
+	},
+	{
+		name:   "bios-policy",
+		descr:  "BIOS tokens of the rack servers compared with the baseline of -expect-file, -q computeBlade for blades",
+		flags:  [][2]string{{"t", "class"}, {"q", "computeRackUnit"}, {"a", "dn"}, {"e", ",compliant$"}},
+		expect: "all tokens of the baseline as expected, the drifted tokens are listed",
+		needs:  []string{"expect-file"},
+	},
+	{
+		name:   "boot-order",
+		descr:  "boot order of the boot policies of service profiles and rack servers compared with the sequence of -boot-order",
+		flags:  [][2]string{{"t", "class"}, {"q", "lsbootDef"}, {"s", "true"}, {"a", "dn"}, {"e", ",yes$"}},
+		expect: "bootMatch yes, the boot devices in order are shown (bootOrder)",
+		needs:  []string{"boot-order"},
+	},
+	{
+		name:   "vmedia",
+		descr:  "vMedia mappings of the servers managed by UCS Manager (vMedia policies), mounted, e.g. the ISO of an OS deployment",
+		flags:  [][2]string{{"t", "class"}, {"q", "cimcvmediaActualMountEntry"}, {"a", "dn mappingName imageFileName operState"}, {"e", ",mounted$"}},
+		expect: "operState mounted, CRIT if a mapping failed (mount-failed) or is not mounted yet",
+	},
+	{
+		name:   "vmedia-cimc",
+		descr:  "vMedia mappings of standalone rack servers (CIMC), mapped and the remote share reachable",
+		flags:  [][2]string{{"t", "class"}, {"q", "commVMediaMap"}, {"a", "dn volumeName remoteShare remoteFile mappingStatus"}, {"probe", "remoteShare"}, {"e", ",OK,yes$"}},
+		expect: "mappingStatus OK and the remote share reachable (CIFS, NFS or HTTP(S) port), CRIT if a mapping is stale or in error",
+	},
+	{
+		name:   "tpm",
+		descr:  "TPM modules of blades and rack servers, present, enabled and activated (compliance audit)",
+		flags:  [][2]string{{"t", "class"}, {"q", "equipmentTpm"}, {"a", "dn presence enabledStatus activeStatus ownership"}, {"e", ",equipped,enabled,activated,"}},
+		expect: "presence equipped, enabledStatus enabled and activeStatus activated, the ownership is shown",
+	},
+	{
+		name:   "secure-boot",
+		descr:  "UEFI secure boot of the boot policies of blades and rack servers (compliance audit)",
+		flags:  [][2]string{{"t", "class"}, {"q", "lsbootBootSecurity"}, {"a", "dn secureBoot"}, {"e", ",(yes|enabled)$"}},
+		expect: "secureBoot yes (UCS Manager) or enabled (CIMC)",
+	},
+	{
+		name:   "faults",
+		descr:  "open faults of severity minor or higher",
+		flags:  [][2]string{{"t", "class"}, {"q", "faultInst"}, {"a", "code severity ack descr"}, {"descr-normalize", "strip"}, {"e", "^[^,]*,(cleared|info|condition|warning),|^[^,]*,[^,]*,yes,"}, {"z", "true"}, {"F", "true"}},
+		expect: "severity cleared, info, condition or warning, or the fault is acknowledged",
+	},
+}
+
+func findTemplate(name string) (*checkTemplate, error) {
+	for _, t := range templates {
+		if t.name == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown check template %q, expected one of %s", name, templateNames())
+}
+
+func templateNames() string {
+	var names []string
+	for _, t := range templates {
+		names = append(names, t.name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// apply sets the flags of the template except the flags in keep
+func (t *checkTemplate) apply(keep map[string]bool) error {
+	for _, kv := range t.flags {
+		if keep[kv[0]] {
+			continue
+		}
+		if err := flag.Set(kv[0], kv[1]); err != nil {
+			return fmt.Errorf("template %s: invalid value %q for flag -%s: %v", t.name, kv[1], kv[0], err)
+		}
+	}
+	return nil
+}
+
+func (t *checkTemplate) value(name string) string {
+	for _, kv := range t.flags {
+		if kv[0] == name {
+			return kv[1]
+		}
+	}
+	return flag.Lookup(name).DefValue
+}
+
+// needed returns an error if a flag needed by the template is missing
+func (t *checkTemplate) needed() error {
+	for _, n := range t.needs {
+		if len(flag.Lookup(n).Value.String()) == 0 {
+			return fmt.Errorf("template %s needs flag -%s", t.name, n)
+		}
+	}
+	return nil
+}
+
+// states describes the plugin states of the template
+func (t *checkTemplate) states() string {
+	s := "OK if all objects match the expect string, CRIT otherwise"
+	if t.value("require") != "" {
+		s = "quorum " + t.value("require") + ": CRIT if less than k objects match, WARN if less than n objects match"
+	}
+	if w := t.value("w"); len(w) > 0 {
+		s += ", WARN if " + thresholdDescr(w)
+	}
+	if c := t.value("c"); len(c) > 0 {
+		s += ", CRIT if " + thresholdDescr(c)
+	}
+	if t.value("z") == "true" {
+		s += ", OK if no objects are found"
+	} else {
+		s += ", CRIT if no objects are found"
+	}
+	if t.value("F") == "true" {
+		s += ", only faults are listed"
+	}
+	return s
+}
+
+// thresholdDescr describes a threshold <attribute>=<value>[:]
+func thresholdDescr(s string) string {
+	if strings.HasSuffix(s, ":") {
+		return "below " + strings.TrimSuffix(s, ":")
+	}
+	return "above " + s
+}
+
+// explain prints the class, attributes, expect string and states of the template
+func (t *checkTemplate) explain(w io.Writer) {
+	var args []string
+	for _, kv := range t.flags {
+		if b, ok := flag.Lookup(kv[0]).Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && kv[1] == "true" {
+			args = append(args, "-"+kv[0])
+		} else {
+			args = append(args, "-"+kv[0]+" "+quoteArg(kv[1]))
+		}
+	}
+	query := t.value("q")
+	if o := t.value("o"); len(o) > 0 {
+		query += ", object class " + o
+	}
+	fmt.Fprintf(w, "%s: %s\n", t.name, t.descr)
+	fmt.Fprintf(w, "  query:      %s %s, hierarchical %s\n", t.value("t"), query, t.value("s"))
+	fmt.Fprintf(w, "  attributes: %s\n", t.value("a"))
+	fmt.Fprintf(w, "  expect:     %s (%s)\n", t.value("e"), t.expect)
+	fmt.Fprintf(w, "  states:     %s\n", t.states())
+	fmt.Fprintf(w, "  flags:      %s\n", strings.Join(args, " "))
+	var needs string
+	for _, n := range t.needs {
+		needs += " -" + n + " <" + n + ">"
+	}
+	fmt.Fprintf(w, "  example:    %s -H <ip_addr> -u <username> -p <password> -check %s%s\n", path.Base(os.Args[0]), t.name, needs)
+}
+
+func quoteArg(s string) string {
+	if strings.ContainsAny(s, " |$^()*?[]\\") {
+		return "'" + s + "'"
+	}
+	return s
+}
+
+// runClasses prints the classes of the templates with the description and
+// the suggested attributes and expect string
+func runClasses(args []string) int {
+	fs := flag.NewFlagSet(path.Base(os.Args[0])+" classes", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s\n", path.Base(os.Args[0]), subcommands["classes"].usage)
+	}
+	parseFlags(fs, args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 3
+	}
+
+	sorted := append([]*checkTemplate{}, templates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].value("q") < sorted[j].value("q")
+	})
+	width := 0
+	for _, t := range sorted {
+		if n := len(t.value("q")); n > width {
+			width = n
+		}
+	}
+	for _, t := range sorted {
+		fmt.Printf("%-*s  %s\n", width, t.value("q"), t.descr)
+		fmt.Printf("%-*s  -a %s -e %s (%s), -check %s\n", width, "", quoteArg(t.value("a")), quoteArg(t.value("e")), t.expect, t.name)
+	}
+	return 0
+}
+
+// runExamples prints all templates or the templates given as arguments
+func runExamples(args []string) int {
+	fs := flag.NewFlagSet(path.Base(os.Args[0])+" examples", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s\n", path.Base(os.Args[0]), subcommands["examples"].usage)
+	}
+	parseFlags(fs, args)
+
+	selected := templates
+	if fs.NArg() > 0 {
+		selected = nil
+		for _, name := range fs.Args() {
+			t, err := findTemplate(name)
+			if err != nil {
+				exitUnknown(err.Error())
+			}
+			selected = append(selected, t)
+		}
+	}
+	for i, t := range selected {
+		if i > 0 {
+			fmt.Println()
+		}
+		t.explain(os.Stdout)
+	}
+	return 0
+}
@@ -0,0 +1,251 @@
+package main
+
+// Numeric thresholds of flags -w and -c: an attribute value above the
+// threshold is WARN or CRIT, e.g. -w ambientTempAvg=30 -c ambientTempAvg=35.
+// The attribute has to be one of the attributes of -a, the expect string is
+// evaluated as before (-e . matches all objects). The thresholds are Nagios
+// ranges, a value outside of the range is WARN or CRIT:
+//
+//	30	below 0 or above 30, like 0:30
+//	1:	below 1, a lower limit, e.g. -w hotSpares=1: for the hot spares of a controller
+//	~:30	above 30
+//	10:30	below 10 or above 30
+//	@10:30	10 to 30 (inside)
+//
+// With -hysteresis and -state-file an object only returns from WARN or CRIT
+// after its value dropped the margin below the threshold, e.g. with
+// -c ambientTempAvg=35 -hysteresis 5% a CRIT object stays CRIT until the value
+// is 33.25 or lower. The threshold state of every object is kept in the state file.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type threshold struct {
+	attr    string
+	low     float64
+	high    float64
+	hasLow  bool // <low>:
+	hasHigh bool
+	inside  bool // @<low>:<high>
+}
+
+// String returns the threshold in the Nagios range format of the perfdata
+func (t threshold) String() string {
+	s := ""
+	if t.inside {
+		s = "@"
+	}
+	if !t.hasLow {
+		s += "~:"
+	} else if t.low != 0 || !t.hasHigh || t.inside {
+		s += strconv.FormatFloat(t.low, 'f', -1, 64) + ":"
+	}
+	if t.hasHigh {
+		s += strconv.FormatFloat(t.high, 'f', -1, 64)
+	}
+	return s
+}
+
+// parseRange parses a Nagios range, [@][<low>|~:][<high>], without a start
+// the start is 0
+func parseRange(s string) (threshold, error) {
+	var t threshold
+	if strings.HasPrefix(s, "@") {
+		t.inside = true
+		s = s[1:]
+	}
+	high := s
+	if i := strings.Index(s, ":"); i < 0 {
+		t.hasLow = true
+	} else {
+		low := s[:i]
+		high = s[i+1:]
+		if low != "~" {
+			v, err := strconv.ParseFloat(low, 64)
+			if err != nil {
+				return t, err
+			}
+			t.low, t.hasLow = v, true
+		}
+	}
+	if len(high) > 0 {
+		v, err := strconv.ParseFloat(high, 64)
+		if err != nil {
+			return t, err
+		}
+		t.high, t.hasHigh = v, true
+	}
+	switch {
+	case !t.hasHigh && (!t.hasLow || !strings.Contains(s, ":")):
+		return t, fmt.Errorf("empty range")
+	case t.hasLow && t.hasHigh && t.low > t.high:
+		return t, fmt.Errorf("start %s above end %s", strconv.FormatFloat(t.low, 'f', -1, 64), strconv.FormatFloat(t.high, 'f', -1, 64))
+	case t.inside && (!t.hasLow || !t.hasHigh):
+		return t, fmt.Errorf("inside range @<start>:<end> needs start and end")
+	}
+	return t, nil
+}
+
+// alert returns the violated bound of the value, empty if the value is ok.
+// margin widens the range of an alert of the previous run (hysteresis).
+func (t threshold) alert(v float64, margin func(float64) float64, prev bool) string {
+	low, high := t.low, t.high
+	if prev {
+		// the state of the previous run is kept until the value is the margin inside of the range, or
+		// outside of an inside range
+		if t.inside {
+			low, high = low-margin(low), high+margin(high)
+		} else {
+			low, high = low+margin(low), high-margin(high)
+		}
+	}
+	format := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	switch {
+	case t.inside && v >= low && v <= high:
+		return "in " + format(low) + ":" + format(high)
+	case t.inside:
+		return ""
+	case t.hasLow && v < low:
+		return "< " + format(low)
+	case t.hasHigh && v > high:
+		return "> " + format(high)
+	}
+	return ""
+}
+
+// thresholdList collects the values of the repeatable flags -w and -c
+type thresholdList []threshold
+
+var (
+	warnThresholds thresholdList
+	critThresholds thresholdList
+)
+
+func (l *thresholdList) String() string {
+	var s []string
+	for _, t := range *l {
+		s = append(s, t.attr+"="+t.String())
+	}
+	return strings.Join(s, ",")
+}
+
+// Set adds thresholds <attribute>=<range>, several separated by comma
+func (l *thresholdList) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return fmt.Errorf("expected <attribute>=<range>, e.g. ambientTemp=30, hotSpares=1: or ambientTemp=10:30")
+		}
+		t, err := parseRange(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("threshold %s: %v", s, err)
+		}
+		t.attr = strings.TrimSpace(kv[0])
+		*l = append(*l, t)
+	}
+	return nil
+}
+
+// reset empties the list before restoreFlags sets it again
+func (l *thresholdList) reset() {
+	*l = nil
+}
+
+func (l thresholdList) limit(attr string) (threshold, bool) {
+	for _, t := range l {
+		if t.attr == attr {
+			return t, true
+		}
+	}
+	return threshold{}, false
+}
+
+// thresholdAttrs returns the attributes with a threshold
+func thresholdAttrs() []string {
+	var attrs []string
+	for _, l := range []thresholdList{critThresholds, warnThresholds} {
+		for _, t := range l {
+			if findIndex(t.attr, attrs) < 0 {
+				attrs = append(attrs, t.attr)
+			}
+		}
+	}
+	return attrs
+}
+
+// thresholdWord returns the word of the exceeded thresholds in the summary:
+// above, outside with lower limits or ranges
+func thresholdWord() string {
+	for _, l := range []thresholdList{critThresholds, warnThresholds} {
+		for _, t := range l {
+			if t.hasLow || t.inside {
+				return "outside"
+			}
+		}
+	}
+	return "above"
+}
+
+// parseHysteresis returns the margin below a threshold: a percentage of the
+// threshold (5%) or an absolute value (2)
+func parseHysteresis(s string) (func(limit float64) float64, error) {
+	if len(s) == 0 {
+		return func(float64) float64 { return 0 }, nil
+	}
+	percent := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || v < 0 {
+		return nil, fmt.Errorf("invalid hysteresis %q, expected a percentage like 5%% or a value like 2", s)
+	}
+	if percent {
+		return func(limit float64) float64 {
+			if limit < 0 {
+				return -limit * v / 100
+			}
+			return limit * v / 100
+		}, nil
+	}
+	return func(float64) float64 { return v }, nil
+}
+
+// objectThresholds returns the threshold state of an object and the exceeded
+// thresholds. prev returns the state of an attribute in the previous run.
+func objectThresholds(attrs map[string]string, prev func(attr string) int, margin func(float64) float64) (int, map[string]int, []string) {
+	state := 0
+	states := make(map[string]int)
+	var exceeded []string
+	for _, attr := range thresholdAttrs() {
+		s, ok := attrs[attr]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			debugPrintf(2, "threshold %s: value %q is not a number\n", attr, s)
+			continue
+		}
+		p := prev(attr)
+		st := 0
+		for _, t := range []struct {
+			state int
+			list  thresholdList
+		}{{2, critThresholds}, {1, warnThresholds}} {
+			th, ok := t.list.limit(attr)
+			if !ok || st > 0 {
+				continue
+			}
+			if a := th.alert(v, margin, p >= t.state); len(a) > 0 {
+				st = t.state
+				exceeded = append(exceeded, fmt.Sprintf("%s %s %s", attr, s, a))
+			}
+		}
+		if st > 0 {
+			states[attr] = st
+			state = worstState(state, st)
+		}
+	}
+	return state, states, exceeded
+}
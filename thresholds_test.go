@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestLowerLimitThresholds(t *testing.T) {
+	defer func() { warnThresholds, critThresholds = nil, nil }()
+	warnThresholds, critThresholds = nil, nil
+	if err := warnThresholds.Set("hotSpares=2:"); err != nil {
+		t.Fatal(err)
+	}
+	if err := critThresholds.Set("hotSpares=1:,ambientTemp=40"); err != nil {
+		t.Fatal(err)
+	}
+	noPrev := func(string) int { return 0 }
+	noMargin := func(float64) float64 { return 0 }
+	tests := []struct {
+		attrs map[string]string
+		want  int
+	}{
+		{map[string]string{"hotSpares": "2"}, 0},
+		{map[string]string{"hotSpares": "1"}, 1},
+		{map[string]string{"hotSpares": "0"}, 2},
+		{map[string]string{"hotSpares": "3", "ambientTemp": "41"}, 2},
+	}
+	for _, tt := range tests {
+		if got, _, exceeded := objectThresholds(tt.attrs, noPrev, noMargin); got != tt.want {
+			t.Errorf("%v: state %d %v, want %d", tt.attrs, got, exceeded, tt.want)
+		}
+	}
+	if s := warnThresholds.String(); s != "hotSpares=2:" {
+		t.Errorf("String %q", s)
+	}
+}
+
+func TestThresholdRanges(t *testing.T) {
+	tests := []struct {
+		s      string
+		values map[float64]bool // value: alert
+	}{
+		{"30", map[float64]bool{0: false, 30: false, 30.5: true, -5: true}},
+		{"~:30", map[float64]bool{30: false, 31: true}},
+		{"1:", map[float64]bool{1: false, 0: true, 100: false}},
+		{"10:30", map[float64]bool{9: true, 10: false, 30: false, 31: true}},
+		{"@10:30", map[float64]bool{9: false, 10: true, 30: true, 31: false}},
+	}
+	noMargin := func(float64) float64 { return 0 }
+	for _, tt := range tests {
+		th, err := parseRange(tt.s)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.s, err)
+		}
+		if s := th.String(); s != tt.s {
+			t.Errorf("%s: String %q", tt.s, s)
+		}
+		for v, want := range tt.values {
+			if got := th.alert(v, noMargin, false); (len(got) > 0) != want {
+				t.Errorf("%s: value %v alert %q, want %v", tt.s, v, got, want)
+			}
+		}
+	}
+	for _, s := range []string{"", ":", "30:10", "@10:", "abc", "1:x"} {
+		if _, err := parseRange(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestPerfdataValue(t *testing.T) {
+	tests := []struct {
+		s, value, unit string
+		ok             bool
+	}{
+		{"42.5", "42.5", "", true},
+		{"285148 MB", "285148", "MB", true},
+		{"64k", "64", "KB", true},
+		{"12 %", "12", "%", true},
+		{"6.0 Gb/s", "", "", false},
+		{"Optimal", "", "", false},
+	}
+	for _, tt := range tests {
+		if value, unit, ok := perfdataValue(tt.s); value != tt.value || unit != tt.unit || ok != tt.ok {
+			t.Errorf("%q: %q %q %v, want %q %q %v", tt.s, value, unit, ok, tt.value, tt.unit, tt.ok)
+		}
+	}
+}
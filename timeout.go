@@ -0,0 +1,88 @@
+package main
+
+// Timeout of flag -timeout <seconds>: the requests of the run (login, queries,
+// logout) share a context with the deadline, a request still running after
+// it is canceled and the check is UNKNOWN with "timeout after <n>s" instead
+// of being killed by Nagios without output, e.g. by a hung CIMC. If the
+// plugin doesn't end shortly after the timeout, e.g. waiting for something
+// else than a request, it exits UNKNOWN itself.
+//
+// The timeout covers the whole run, all profiles of -config. In batch mode it
+// applies to every target, in daemon mode to every run. Unlike -deadline the
+// running requests are canceled, set -deadline below -timeout to report the
+// results so far, e.g. -deadline 40s -timeout 50.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// timeoutGrace is the time after the timeout for the output of the canceled check
+const timeoutGrace = 2 * time.Second
+
+var (
+	timeout       timeoutValue
+	timeoutCtx    = context.Background()
+	timeoutCancel = context.CancelFunc(func() {})
+)
+
+// timeoutValue is the value of -timeout, seconds or a duration like 1m30s
+type timeoutValue time.Duration
+
+func (t *timeoutValue) String() string {
+	return time.Duration(*t).String()
+}
+
+func (t *timeoutValue) Set(s string) error {
+	if n, err := strconv.Atoi(s); err == nil {
+		*t = timeoutValue(time.Duration(n) * time.Second)
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("expected seconds or a duration like 1m30s")
+		}
+		*t = timeoutValue(d)
+	}
+	if *t < 0 {
+		return fmt.Errorf("negative timeout")
+	}
+	return nil
+}
+
+// startTimeout starts the timeout of a run, in batch mode the targets have
+// their own timeout
+func startTimeout() {
+	timeoutCancel()
+	if timeout == 0 || isBatch() {
+		timeoutCtx, timeoutCancel = context.Background(), func() {}
+		return
+	}
+	timeoutCtx, timeoutCancel = context.WithTimeout(context.Background(), time.Duration(timeout))
+	if !daemonMode {
+		time.AfterFunc(time.Duration(timeout)+timeoutGrace, func() {
+			exitUnknown(timeoutError().Error())
+		})
+	}
+}
+
+// timeoutError returns the error of a request canceled by the timeout
+func timeoutError() error {
+	return fmt.Errorf("timeout after %s", time.Duration(timeout))
+}
+
+// withTimeout returns the context of a request, canceled at the timeout
+func withTimeout(ctx context.Context) context.Context {
+	if timeout == 0 {
+		return ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	context.AfterFunc(timeoutCtx, cancel)
+	return ctx
+}
+
+// timedOut returns true if the timeout of the run is reached
+func timedOut() bool {
+	return timeoutCtx.Err() != nil
+}
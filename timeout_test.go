@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeoutValue(t *testing.T) {
+	for s, want := range map[string]time.Duration{"50": 50 * time.Second, "1m30s": 90 * time.Second, "0": 0} {
+		var v timeoutValue
+		if err := v.Set(s); err != nil || time.Duration(v) != want {
+			t.Errorf("%s: got %s, %v, want %s", s, time.Duration(v), err, want)
+		}
+	}
+	for _, s := range []string{"-5", "soon", "10x"} {
+		var v timeoutValue
+		if err := v.Set(s); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+// Certificate verification of the UCS Manager, CIMC or Redfish endpoint. The
+// certificate is verified against the system CA certificates, flag -cafile
+// <file> verifies it against the CA certificates (PEM) of the file instead,
+// e.g. the internal CA which signed the certificates of the management
+// interfaces. Flag -k skips the verification, needed for the self-signed
+// factory certificates. -H has to match the certificate (DNS name or IP
+// address in the subject alternative names).
+//
+//	-H ucs-a.example.com -cafile /etc/pki/ucs-ca.pem
+//	-H 10.10.1.7 -k
+//
+// FIPS mode, flag -fips or the build tag fips (go build -tags fips, the flag
+// can't turn it off): TLS 1.2 only (-M and -tls-min are ignored) with the FIPS 140 approved
+// cipher suites ECDHE with AES-GCM and the curves P-256 and P-384 (NIST SP
+// 800-52r2), -k is refused. Build with GOFIPS140 (Go 1.24 or later) to use the
+// validated Go Cryptographic Module, -V reports the mode and the module.
+//
+// Flag -ciphers selects the cipher suites of TLS 1.0 to 1.2, a comma separated
+// list of Go names of cipher suites and presets, e.g. for old CIMC firmware
+// without ECDHE, only for the checks of these CIMCs:
+//
+//	modern		ECDHE with AES-GCM or ChaCha20-Poly1305
+//	intermediate	modern and ECDHE with AES-CBC
+//	legacy-cimc	intermediate and RSA key exchange with AES and 3DES
+//
+//	-ciphers legacy-cimc
+//	-ciphers TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,TLS_RSA_WITH_AES_256_CBC_SHA
+//
+// In FIPS mode only the FIPS cipher suites can be selected.
+//
+// TLS versions: -M is the maximum version (1.0 to 1.3, default 1.1), -tls-min
+// the minimum, default 1.2 (the default of Go clients), lowered to -M if -M is
+// below it, so -M 1.1 works with the firmware supporting TLS 1.1 only.
+// Firmware speaking TLS 1.0 only needs -M 1.0 or -tls-min 1.0:
+//
+//	-M 1.3 -tls-min 1.2
+//	-M 1.1 -tls-min 1.0
+
+import (
+	"crypto/fips140"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+var (
+	insecureSkipVerify  bool
+	caFile              string
+	fipsMode            bool
+	cipherSpec          string
+	minTlsVersionString string
+)
+
+// tlsVersions are the versions of -M and -tls-min
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersionRange returns the minimum (-tls-min) and maximum (-M) TLS version
+func tlsVersionRange() (min, max uint16, err error) {
+	max, ok := tlsVersions[maxTlsVersionString]
+	if !ok {
+		return 0, 0, fmt.Errorf("flag -M: unknown TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", maxTlsVersionString)
+	}
+	if len(minTlsVersionString) == 0 {
+		min = tls.VersionTLS12
+		if min > max {
+			min = max
+		}
+		return min, max, nil
+	}
+	if min, ok = tlsVersions[minTlsVersionString]; !ok {
+		return 0, 0, fmt.Errorf("flag -tls-min: unknown TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", minTlsVersionString)
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("flag -tls-min %s is above the maximum TLS version %s (-M)", minTlsVersionString, maxTlsVersionString)
+	}
+	return min, max, nil
+}
+
+// cipherPresets are the presets of -ciphers
+var cipherPresets = map[string][]uint16{
+	"modern": {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	},
+	"intermediate": {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	},
+	"legacy-cimc": {
+		tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	},
+}
+
+// cipherPresetBase is the preset a preset extends
+var cipherPresetBase = map[string]string{"intermediate": "modern", "legacy-cimc": "intermediate"}
+
+// presetCiphers returns the cipher suites of a preset and the ones it extends
+func presetCiphers(name string) []uint16 {
+	var ids []uint16
+	if base, ok := cipherPresetBase[name]; ok {
+		ids = presetCiphers(base)
+	}
+	return append(ids, cipherPresets[name]...)
+}
+
+// parseCiphers returns the cipher suites of -ciphers
+func parseCiphers(spec string) ([]uint16, error) {
+	byName := make(map[string]uint16)
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		byName[s.Name] = s.ID
+	}
+	var ids []uint16
+	seen := make(map[uint16]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		suites := presetCiphers(name)
+		if id, ok := byName[name]; ok {
+			suites = []uint16{id}
+		}
+		if len(suites) == 0 {
+			var presets []string
+			for p := range cipherPresets {
+				presets = append(presets, p)
+			}
+			sort.Strings(presets)
+			return nil, fmt.Errorf("flag -ciphers: unknown cipher suite %q, expected a preset (%s) or a Go name like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", name, strings.Join(presets, ", "))
+		}
+		for _, id := range suites {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// tlsCiphers returns the cipher suites of -ciphers, nil without -ciphers: the
+// Go defaults
+func tlsCiphers() ([]uint16, error) {
+	if len(cipherSpec) == 0 {
+		return nil, nil
+	}
+	ids, err := parseCiphers(cipherSpec)
+	if err != nil || !fipsMode {
+		return ids, err
+	}
+	approved := make(map[uint16]bool)
+	for _, id := range fipsCipherSuites {
+		approved[id] = true
+	}
+	var fips []uint16
+	for _, id := range ids {
+		if approved[id] {
+			fips = append(fips, id)
+		}
+	}
+	if len(fips) < len(ids) {
+		return nil, fmt.Errorf("flag -ciphers: FIPS mode allows only %s", cipherNames(fipsCipherSuites))
+	}
+	return fips, nil
+}
+
+// cipherNames returns the names of cipher suites
+func cipherNames(ids []uint16) string {
+	var names []string
+	for _, id := range ids {
+		names = append(names, tls.CipherSuiteName(id))
+	}
+	return strings.Join(names, ", ")
+}
+
+// fipsCipherSuites are the FIPS 140 approved cipher suites of TLS 1.2
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// validateFips checks the flags of FIPS mode
+func validateFips() error {
+	switch {
+	case fipsBuild && !fipsMode:
+		return fmt.Errorf("flag -fips: FIPS build, FIPS mode can't be turned off")
+	case fipsMode && insecureSkipVerify:
+		return fmt.Errorf("flag -k isn't allowed in FIPS mode, the certificate has to be verified (-cafile)")
+	}
+	return nil
+}
+
+// fipsConfig restricts the TLS configuration to FIPS 140
+func fipsConfig(c *tls.Config) {
+	c.MinVersion, c.MaxVersion = tls.VersionTLS12, tls.VersionTLS12
+	c.CipherSuites = fipsCipherSuites
+	c.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+}
+
+// cryptoPosture returns the crypto mode for -V
+func cryptoPosture() string {
+	if !fipsMode {
+		return "crypto: standard"
+	}
+	module := "not used (build with GOFIPS140)"
+	if fips140.Enabled() {
+		module = "enabled"
+	}
+	return "crypto: FIPS mode (TLS 1.2, ECDHE with AES-GCM, P-256 and P-384), Go Cryptographic Module: " + module
+}
+
+// rootCAs returns the CA certificates of -cafile, nil without -cafile: the
+// system CA certificates
+func rootCAs() (*x509.CertPool, error) {
+	if len(caFile) == 0 {
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no PEM certificate found", caFile)
+	}
+	return pool, nil
+}
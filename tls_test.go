@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestParseCiphers(t *testing.T) {
+	ids, err := parseCiphers("legacy-cimc")
+	if err != nil || len(ids) != len(cipherPresets["modern"])+len(cipherPresets["intermediate"])+len(cipherPresets["legacy-cimc"]) {
+		t.Errorf("legacy-cimc: got %d suites, %v", len(ids), err)
+	}
+	// duplicates are left out
+	ids, err = parseCiphers("TLS_RSA_WITH_AES_256_CBC_SHA, modern,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	if err != nil || len(ids) != 7 || ids[0] != tls.TLS_RSA_WITH_AES_256_CBC_SHA {
+		t.Errorf("list: got %v, %v", cipherNames(ids), err)
+	}
+	for _, s := range []string{"ancient", "TLS_RSA_WITH_RC5", "modern,"} {
+		if _, err := parseCiphers(s); err == nil {
+			t.Errorf("parseCiphers(%q) succeeded", s)
+		}
+	}
+}
+
+func TestTLSVersionRange(t *testing.T) {
+	defer func(max, min string) { maxTlsVersionString, minTlsVersionString = max, min }(maxTlsVersionString, minTlsVersionString)
+	for _, tt := range []struct {
+		max, min         string
+		wantMin, wantMax uint16
+		ok               bool
+	}{
+		{"1.1", "", tls.VersionTLS11, tls.VersionTLS11, true},
+		{"1.2", "", tls.VersionTLS12, tls.VersionTLS12, true},
+		{"1.3", "", tls.VersionTLS12, tls.VersionTLS13, true},
+		{"1.3", "1.3", tls.VersionTLS13, tls.VersionTLS13, true},
+		{"1.1", "1.0", tls.VersionTLS10, tls.VersionTLS11, true},
+		{"1.2", "1.3", 0, 0, false},
+		{"1.4", "", 0, 0, false},
+		{"v1.2", "", 0, 0, false},
+		{"1.2", "1", 0, 0, false},
+	} {
+		maxTlsVersionString, minTlsVersionString = tt.max, tt.min
+		min, max, err := tlsVersionRange()
+		if (err == nil) != tt.ok || min != tt.wantMin || max != tt.wantMax {
+			t.Errorf("-M %s -tls-min %s: %x-%x, %v", tt.max, tt.min, min, max, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+// Dead man's switch of flag -touch-file <path>: the file is written after
+// every run with at least one check result other than UNKNOWN, the check
+// reached the UCS domain and evaluated its objects. An external watchdog (cron
+// job, systemd timer, file age check of another monitoring) alerts if the file
+// gets old, e.g. scheduled checks silently stopped because of a wedged NRPE,
+// independent of the freshness checking of Nagios. The placeholder {label} is
+// replaced by the name of the UCS domain (-label or the host), so one path
+// serves all domains:
+//
+//	-touch-file /var/lib/check_cisco_ucs/{label}.alive
+//
+// The file contains the time and the state of the run, e.g. "1760521500 CRIT".
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var touchFile string
+
+// touchFilePath returns the path of -touch-file with the placeholder replaced
+func touchFilePath() string {
+	return strings.Replace(touchFile, "{label}", resultLabel(), -1)
+}
+
+// touchAfterRun writes the file of -touch-file if a check succeeded, errors
+// are only logged, the watchdog notices the missing update
+func touchAfterRun(results []*checkResult) {
+	if len(touchFile) == 0 {
+		return
+	}
+	succeeded := false
+	for _, res := range results {
+		succeeded = succeeded || res.State != 3
+	}
+	if !succeeded {
+		debugPrintf(2, "touch file %s not updated, all results UNKNOWN\n", touchFilePath())
+		return
+	}
+	path := touchFilePath()
+	content := fmt.Sprintf("%d %s\n", time.Now().Unix(), statePrefix[worstResult(results)])
+	// write and rename, the watchdog never reads a partial file
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	err := ioutil.WriteFile(tmp, []byte(content), 0644)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		debugPrintf(1, "touch file: %v\n", err)
+	}
+}
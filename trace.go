@@ -0,0 +1,108 @@
+package main
+
+// Phase timeline of flag -trace. The phases of a run (dns, connect, tls,
+// login, query, parse, evaluate, logout) are appended to the long output with
+// their offset from the start of the run and their duration. The phases
+// overlap, e.g. login contains dns, connect and tls of the first request.
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+type tracePhase struct {
+	name       string
+	start, end time.Time
+}
+
+type phaseTrace struct {
+	mu     sync.Mutex
+	start  time.Time
+	phases []tracePhase
+}
+
+// phases is the timeline of the run, nil without -trace
+var phases *phaseTrace
+
+func newPhaseTrace() *phaseTrace {
+	return &phaseTrace{start: time.Now()}
+}
+
+func (t *phaseTrace) add(name string, start, end time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases = append(t.phases, tracePhase{name: name, start: start, end: end})
+}
+
+// begin starts a phase and returns the function ending it, usable with a nil
+// timeline: defer phases.begin("query")()
+func (t *phaseTrace) begin(name string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.add(name, start, time.Now())
+	}
+}
+
+// withClientTrace adds the dns, connect and tls phases of the HTTP requests
+func (t *phaseTrace) withClientTrace(ctx context.Context) context.Context {
+	var (
+		mu                 sync.Mutex
+		dnsStart, tlsStart time.Time
+		connectStart       = make(map[string]time.Time) // key: address, dialed in parallel
+	)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.add("dns", dnsStart, time.Now())
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connectStart[addr] = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			start := connectStart[addr]
+			mu.Unlock()
+			t.add("connect", start, time.Now())
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.add("tls", tlsStart, time.Now())
+		},
+	})
+}
+
+// String returns the timeline for the long output
+func (t *phaseTrace) String() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sort.SliceStable(t.phases, func(i, j int) bool {
+		return t.phases[i].start.Before(t.phases[j].start)
+	})
+	s := "\ntrace:"
+	for _, p := range t.phases {
+		s += fmt.Sprintf("\n  %-8s +%-9s %s", p.name, offset(p.start.Sub(t.start)), offset(p.end.Sub(p.start)))
+	}
+	s += fmt.Sprintf("\n  %-8s  %-9s %s", "total", "", offset(time.Since(t.start)))
+	return s
+}
+
+func offset(d time.Duration) string {
+	return d.Round(100 * time.Microsecond).String()
+}
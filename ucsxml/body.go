@@ -0,0 +1,74 @@
+package ucsxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Limits of the responses of buggy or compromised devices
+const (
+	MaxResponseSize = 256 << 20
+	MaxDepth        = 256
+	MaxAttrs        = 1024
+	MaxValueLen     = 64 << 10
+	MaxObjects      = 1000000
+)
+
+// ReadBody reads a complete XML API response. If the connection drops in the
+// middle of the body the partial XML may still parse with fewer objects, so
+// the length and the end of the XML document are checked.
+func ReadBody(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxResponseSize+1))
+	if err != nil {
+		return body, &Error{ErrNet, fmt.Errorf("response truncated after %d bytes: %v", len(body), err)}
+	}
+	if len(body) > MaxResponseSize {
+		return nil, fmt.Errorf("response larger than %d bytes", MaxResponseSize)
+	}
+	if resp.ContentLength >= 0 && int64(len(body)) != resp.ContentLength {
+		return body, &Error{ErrNet, fmt.Errorf("response truncated, %d of %d bytes received", len(body), resp.ContentLength)}
+	}
+	body, converted := ToUTF8(body)
+	if converted > 0 {
+		Debugf(1, "response not UTF-8, %d bytes converted from Latin-1\n", converted)
+	}
+	if err := Complete(body); err != nil {
+		return body, &Error{ErrNet, err}
+	}
+	return body, nil
+}
+
+// Complete returns an error if the root element of the XML document is not closed
+func Complete(body []byte) error {
+	depth := 0
+	root := false
+	decoder := NewDecoder(body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if strings.Contains(err.Error(), "unexpected EOF") {
+				return fmt.Errorf("response truncated at byte %d", decoder.InputOffset())
+			}
+			// no truncation, syntax errors are reported by the parser
+			return nil
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+			root = true
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if !root || depth > 0 {
+		return fmt.Errorf("response truncated, XML document incomplete after %d bytes", len(body))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package ucsxml
+
+// Character encodings of XML API responses. Some firmware emits Latin-1
+// characters in descr attributes, declared or not, which encoding/xml rejects.
+// Responses are converted to UTF-8 before parsing: bytes which are not valid
+// UTF-8 are read as Windows-1252 (a superset of the printable Latin-1), the
+// encodings ISO-8859-1 and Windows-1252 declared in the XML header are
+// decoded by charsetReader.
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+// cp1252 maps the bytes 0x80 to 0x9f of Windows-1252, the other bytes are
+// the same as in Latin-1
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+func cp1252Rune(b byte) rune {
+	if b >= 0x80 && b < 0xa0 {
+		return cp1252[b-0x80]
+	}
+	return rune(b)
+}
+
+// ToUTF8 returns body with all bytes which are not valid UTF-8 converted
+// from Windows-1252 and the number of converted bytes
+func ToUTF8(body []byte) ([]byte, int) {
+	if utf8.Valid(body) {
+		return body, 0
+	}
+	converted := 0
+	out := bytes.NewBuffer(make([]byte, 0, len(body)+len(body)/8))
+	for len(body) > 0 {
+		r, size := utf8.DecodeRune(body)
+		if r == utf8.RuneError && size <= 1 {
+			r = cp1252Rune(body[0])
+			converted++
+		}
+		out.WriteRune(r)
+		body = body[size:]
+	}
+	return out.Bytes(), converted
+}
+
+// charsetReader decodes the encodings declared in the XML header. A response
+// converted by ReadBody is valid UTF-8 already and isn't decoded again.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+		buf, err := ioutil.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		if utf8.Valid(buf) {
+			return bytes.NewBuffer(buf), nil
+		}
+		out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+		for _, b := range buf {
+			out.WriteRune(cp1252Rune(b))
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
+
+// NewDecoder returns a decoder for XML API data
+func NewDecoder(buf []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewBuffer(buf))
+	decoder.CharsetReader = charsetReader
+	return decoder
+}
@@ -0,0 +1,21 @@
+package ucsxml
+
+import "testing"
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      string
+		converted int
+	}{
+		{"Temperatur 45°C", "Temperatur 45°C", 0},
+		{"Temperatur 45\xb0C", "Temperatur 45°C", 1},
+		{"pr\xfcfen \x80 5\xe4", "prüfen € 5ä", 3},
+	}
+	for _, tt := range tests {
+		got, n := ToUTF8([]byte(tt.in))
+		if string(got) != tt.want || n != tt.converted {
+			t.Errorf("ToUTF8(%q) = %q, %d, want %q, %d", tt.in, got, n, tt.want, tt.converted)
+		}
+	}
+}
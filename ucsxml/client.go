@@ -0,0 +1,117 @@
+package ucsxml
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// Client sends the requests of a session to the XML API at URL, e.g.
+// https://ucs-a.example.com/nuova of UCS Manager and CIMC or
+// https://ucs-central.example.com/xmlIM/resource-mgr of UCS Central. The
+// HTTP client sets up TLS, proxies and timeouts.
+type Client struct {
+	HTTP   *http.Client
+	URL    string
+	Cookie string // of the session, set by Login
+}
+
+// NewClient returns a client of the XML API at url, http.DefaultClient if
+// httpClient is nil
+func NewClient(httpClient *http.Client, url string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{HTTP: httpClient, URL: url}
+}
+
+// Login logs in and sets the cookie of the session. A refused login is
+// returned as *APIError of the method aaaLogin.
+func (c *Client) Login(user, password string) (*AaaLoginResp, error) {
+	buf, err := BuildRequest(&AaaLogin{InName: user, InPassword: password})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Post(c.URL, "text/xml", bytes.NewBuffer(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ReadBody(resp)
+	Debugf(2, "http status code: %s\n", resp.Status)
+	Debugf(3, "login response: %s\n", body)
+	if err != nil {
+		return nil, err
+	}
+
+	loginResp := &AaaLoginResp{}
+	if err := NewDecoder(body).Decode(loginResp); err != nil {
+		return nil, &Error{ErrParse, err}
+	}
+	Debugf(3, "%#v\n", loginResp)
+	if loginResp.ErrorCode != 0 {
+		return loginResp, &APIError{Method: "aaaLogin", Code: strconv.Itoa(loginResp.ErrorCode), Descr: loginResp.ErrorDescr}
+	}
+	c.Cookie = loginResp.OutCookie
+	return loginResp, nil
+}
+
+// Logout ends the session
+func (c *Client) Logout() error {
+	buf, err := BuildRequest(&AaaLogout{InCookie: c.Cookie})
+	if err != nil {
+		return err
+	}
+	Debugf(3, "logout request: %s\n", buf)
+	resp, err := c.HTTP.Post(c.URL, "text/xml", bytes.NewBuffer(buf))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	Debugf(2, "logout respons: %s\n", body)
+	c.Cookie = ""
+	return nil
+}
+
+// Do sends a request and returns the response body. Errors reported by the
+// XML API are returned as *APIError.
+func (c *Client) Do(req interface{}) ([]byte, error) {
+	buf, err := BuildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	method, _, err := RequestMethod(buf)
+	if err != nil {
+		return nil, err
+	}
+	Debugf(3, "%s request:\n%s\n", method, buf)
+
+	resp, err := c.HTTP.Post(c.URL, "text/xml", bytes.NewBuffer(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ReadBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	Debugf(2, "%s respons: %s\n", method, body)
+
+	if _, err := DecodeEnvelope(body, method); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// ResolveClass returns the response of configResolveClass, the objects of
+// the class matching the filter, all with a nil filter
+func (c *Client) ResolveClass(classId string, hierarchical bool, filter *InFilter) ([]byte, error) {
+	return c.Do(&ConfigResolveClass{Cookie: c.Cookie, InHierarchical: strconv.FormatBool(hierarchical), ClassId: classId, InFilter: filter})
+}
+
+// ResolveDn returns the response of configResolveDn, the object of the dn
+func (c *Client) ResolveDn(dn string, hierarchical bool) ([]byte, error) {
+	return c.Do(&ConfigResolveDn{Cookie: c.Cookie, InHierarchical: strconv.FormatBool(hierarchical), Dn: dn})
+}
@@ -0,0 +1,78 @@
+package ucsxml
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeAPI answers every request method with the testdata file of the method
+func fakeAPI(t *testing.T, files map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		method, _, err := RequestMethod(body)
+		if err != nil {
+			t.Errorf("invalid request %s: %v", body, err)
+		}
+		w.Write(readTestdata(t, files[method]))
+	}))
+}
+
+func TestClient(t *testing.T) {
+	srv := fakeAPI(t, map[string]string{
+		"aaaLogin":           "ucsm-4.1-aaaLogin.xml",
+		"configResolveClass": "ucsm-3.2-configResolveClass-equipmentPsu.xml",
+		"aaaLogout":          "ucsm-4.1-aaaLogin.xml",
+	})
+	defer srv.Close()
+
+	c := NewClient(srv.Client(), srv.URL+"/nuova")
+	if _, err := c.Login("nagios", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c.Cookie, "1602751220/") {
+		t.Errorf("cookie %q", c.Cookie)
+	}
+	body, err := c.ResolveClass("equipmentPsu", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objects, err := ParseObjects(body, "equipmentPsu", []string{"operState"}, false); err != nil || len(objects) != 3 {
+		t.Errorf("%d objects, error %v, want 3 objects", len(objects), err)
+	}
+	if err := c.Logout(); err != nil || len(c.Cookie) > 0 {
+		t.Errorf("logout: %v, cookie %q", err, c.Cookie)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	srv := fakeAPI(t, map[string]string{
+		"aaaLogin":           "ucsm-4.1-aaaLogin-551.xml",
+		"configResolveClass": "ucsm-4.1-configResolveClass-552.xml",
+	})
+	defer srv.Close()
+
+	c := NewClient(srv.Client(), srv.URL+"/nuova")
+	_, err := c.Login("nagios", "wrong")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != "551" || ErrorClass(err) != ErrAuth {
+		t.Errorf("login: error %v, want XML API error 551", err)
+	}
+	_, err = c.ResolveClass("equipmentPsu", false, nil)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != "552" || ErrorClass(err) != ErrAuth {
+		t.Errorf("configResolveClass: error %v, want XML API error 552", err)
+	}
+}
+
+func TestLoginTruncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<aaaLogin cookie="" response="yes" outCookie="1602751220/8f2a6b31">`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.Client(), srv.URL+"/nuova")
+	if _, err := c.Login("nagios", "secret"); ErrorClass(err) != ErrNet || len(c.Cookie) > 0 {
+		t.Errorf("error %v, cookie %q, want a truncated response", err, c.Cookie)
+	}
+}
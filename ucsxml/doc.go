@@ -0,0 +1,30 @@
+// Package ucsxml is a client of the XML API of Cisco UCS Manager, UCS Central
+// and the CIMC of the C-Series rack servers: the login and logout, the query
+// methods configResolveClass and configResolveDn with property and composite
+// filters and the objects of a class with their attributes in a response.
+// It is the API client of the Nagios plugin check_cisco_ucs, for tools which
+// query UCS without running the plugin:
+//
+//	c := ucsxml.NewClient(httpClient, "https://ucs-a.example.com/nuova")
+//	if _, err := c.Login("monitor", secret); err != nil {
+//		return err
+//	}
+//	defer c.Logout()
+//	f, err := ucsxml.ParseFilter("wcard:dn:^sys/chassis-1/")
+//	if err != nil {
+//		return err
+//	}
+//	body, err := c.ResolveClass("equipmentPsu", false, f.InFilter("equipmentPsu"))
+//	if err != nil {
+//		return err
+//	}
+//	psus, err := ucsxml.ParseObjects(body, "equipmentPsu", []string{"dn", "operState"}, false)
+//
+// The responses come from devices which might be buggy or compromised, their
+// size, nesting, attributes and number of objects are limited. Errors of the
+// API, of truncated and of unparsable responses are classified by ErrorClass.
+package ucsxml
+
+// Debugf prints the debug messages of the package, the requests and
+// responses at level 3, e.g. log.Printf for all levels. Default: none.
+var Debugf = func(level int, format string, a ...interface{}) {}
@@ -0,0 +1,45 @@
+package ucsxml
+
+import "errors"
+
+// Classes of the errors of the package, see ErrorClass
+const (
+	ErrAuth  = "auth"  // login refused, session expired or invalid
+	ErrNet   = "net"   // response truncated
+	ErrAPI   = "api"   // other errors reported by the API, e.g. an unknown class
+	ErrParse = "parse" // response not parsable
+)
+
+// authErrorCodes are the XML API error codes of a refused login or session
+var authErrorCodes = map[string]bool{"551": true, "552": true, "553": true, "572": true}
+
+// Error is an error of a response which isn't an error of the XML API
+type Error struct {
+	Class string // ErrNet or ErrParse
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass returns the class of an error of the package, an empty string
+// for the errors of the HTTP client and the limits of the responses
+func ErrorClass(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if authErrorCodes[apiErr.Code] || apiErr.Method == "aaaLogin" {
+			return ErrAuth
+		}
+		return ErrAPI
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Class
+	}
+	return ""
+}
@@ -0,0 +1,305 @@
+package ucsxml
+
+// Filters of configResolveClass, evaluated by UCS Manager or CIMC. A property
+// filter is <type>:<property>:<value>, e.g. wcard:dn:^sys/chassis-1/, the
+// value of a wcard filter is a POSIX extended regex there, not a Go regex:
+// \d, \w, \s, non-capturing groups (?:...) and lazy repeats are translated by
+// WcardValue, flags like (?i), \b and Unicode classes can't be.
+//
+// Composite filters and(...), or(...) and not(...) combine property filters
+// and composite filters, sent as <and>, <or> and <not>:
+//
+//	and(wcard:dn:^sys/chassis-1.*,gt:ambientTempAvg:24)
+//	or(eq:operState:inoperable,not(eq:presence:equipped))
+//
+// and and or take two or more filters, not one. The filters are separated by
+// commas, commas inside parentheses or a bracket expression belong to the
+// value, e.g. wcard:dn:^sys/chassis-[1,2]/(psu|fan-module-1-[1,2])$, a comma
+// elsewhere in a value is escaped with a backslash. Spaces around the filters
+// are ignored.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// propertyTypes are the property filter types
+var propertyTypes = map[string]bool{
+	"eq": true, "ne": true, "gt": true, "ge": true, "lt": true, "le": true, "wcard": true, "anybit": true, "allbits": true,
+}
+
+// compositeTypes are the composite filter types, with their minimum number of filters
+var compositeTypes = map[string]int{"and": 2, "or": 2, "not": 1}
+
+// Filter is a property filter or a composite filter of filters
+type Filter struct {
+	Type     string // eq, ne, gt, ge, lt, le, wcard, anybit, allbits, and, or or not
+	Property string
+	Value    string
+	Filters  []Filter       // of a composite filter
+	re       *regexp.Regexp // of a wcard filter, nil if the value isn't a Go regex
+}
+
+// ParseFilter parses a property filter or a composite filter
+func ParseFilter(s string) (Filter, error) {
+	return parseFilter(s, false)
+}
+
+// parseFilter parses a filter, of a composite filter if nested: with escaped
+// commas
+func parseFilter(s string, nested bool) (Filter, error) {
+	typ := strings.SplitN(s, "(", 2)[0]
+	if min, ok := compositeTypes[typ]; ok && strings.HasSuffix(s, ")") {
+		f := Filter{Type: typ}
+		for _, arg := range splitFilters(s[len(typ)+1 : len(s)-1]) {
+			sub, err := parseFilter(arg, true)
+			if err != nil {
+				return Filter{}, err
+			}
+			f.Filters = append(f.Filters, sub)
+		}
+		if len(f.Filters) < min || f.Type == "not" && len(f.Filters) > 1 {
+			return Filter{}, fmt.Errorf("invalid composite filter %q, and(...) and or(...) need two or more filters, not(...) one", s)
+		}
+		return f, nil
+	}
+	// the value may contain colons, e.g. a MAC address
+	parts := strings.SplitN(s, ":", 3)
+	if !propertyTypes[parts[0]] || len(parts) < 3 {
+		return Filter{}, fmt.Errorf("invalid property filter %q, expected <type>:<property>:<value> with type eq, ne, gt, ge, lt, le, wcard, anybit or allbits", s)
+	}
+	f := Filter{Type: parts[0], Property: parts[1], Value: parts[2]}
+	if nested {
+		f.Value = strings.Replace(f.Value, `\,`, ",", -1)
+	}
+	if f.Type == "wcard" {
+		f.re, _ = regexp.Compile(f.Value)
+	}
+	return f, nil
+}
+
+// splitFilters splits the filters of a composite filter at the commas
+// outside of parentheses and bracket expressions
+func splitFilters(s string) []string {
+	var filters []string
+	var b strings.Builder
+	depth, inClass := 0, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			// escaped, e.g. a comma of a value, unescaped by parseFilter
+			b.WriteByte(c)
+			c = s[i+1]
+			i++
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+			// a ] right after [ or [^ is a literal
+			if strings.HasPrefix(s[i+1:], "^") {
+				b.WriteByte(c)
+				c = '^'
+				i++
+			}
+			if strings.HasPrefix(s[i+1:], "]") {
+				b.WriteByte(c)
+				c = ']'
+				i++
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			filters = append(filters, strings.TrimSpace(b.String()))
+			b.Reset()
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return append(filters, strings.TrimSpace(b.String()))
+}
+
+// Leaves returns the property filters of f
+func (f Filter) Leaves() []Filter {
+	if _, ok := compositeTypes[f.Type]; !ok {
+		return []Filter{f}
+	}
+	var leaves []Filter
+	for _, sub := range f.Filters {
+		leaves = append(leaves, sub.Leaves()...)
+	}
+	return leaves
+}
+
+// XMLFilter returns the filter element of the XML API for the class, wcard
+// values translated to POSIX
+func (f Filter) XMLFilter(class string) interface{} {
+	var filters []interface{}
+	for _, sub := range f.Filters {
+		filters = append(filters, sub.XMLFilter(class))
+	}
+	value := f.Value
+	if f.Type == "wcard" {
+		value, _ = WcardValue(value)
+	}
+	switch f.Type {
+	case "and":
+		return &And{Filters: filters}
+	case "or":
+		return &Or{Filters: filters}
+	case "not":
+		return &Not{Filters: filters}
+	case "eq":
+		return &Eq{Class: class, Property: f.Property, Value: value}
+	case "ne":
+		return &Ne{Class: class, Property: f.Property, Value: value}
+	case "gt":
+		return &Gt{Class: class, Property: f.Property, Value: value}
+	case "ge":
+		return &Ge{Class: class, Property: f.Property, Value: value}
+	case "lt":
+		return &Lt{Class: class, Property: f.Property, Value: value}
+	case "le":
+		return &Le{Class: class, Property: f.Property, Value: value}
+	case "wcard":
+		return &Wcard{Class: class, Property: f.Property, Value: value}
+	case "anybit":
+		return &Anybit{Class: class, Property: f.Property, Value: value}
+	default:
+		return &Allbits{Class: class, Property: f.Property, Value: value}
+	}
+}
+
+// InFilter returns the inFilter of configResolveClass for the class
+func (f Filter) InFilter(class string) *InFilter {
+	in := &InFilter{}
+	switch x := f.XMLFilter(class).(type) {
+	case *Eq:
+		in.Eq = x
+	case *Ne:
+		in.Ne = x
+	case *Gt:
+		in.Gt = x
+	case *Ge:
+		in.Ge = x
+	case *Lt:
+		in.Lt = x
+	case *Le:
+		in.Le = x
+	case *Wcard:
+		in.Wcard = x
+	case *Anybit:
+		in.Anybit = x
+	case *Allbits:
+		in.Allbits = x
+	case *And:
+		in.And = x
+	case *Or:
+		in.Or = x
+	case *Not:
+		in.Not = x
+	}
+	return in
+}
+
+// Match returns true if the object matches f, evaluated like the XML API
+// does for eq, ne and wcard with a Go regex (RE2), false for the other
+// property filters
+func (f Filter) Match(obj Object) bool {
+	switch f.Type {
+	case "and", "or":
+		for _, sub := range f.Filters {
+			if sub.Match(obj) != (f.Type == "and") {
+				return f.Type == "or"
+			}
+		}
+		return f.Type == "and"
+	case "not":
+		return !f.Filters[0].Match(obj)
+	}
+	v, ok := obj.Attrs[f.Property]
+	if f.Property == "dn" {
+		v, ok = obj.Dn, true
+	}
+	switch f.Type {
+	case "eq":
+		return ok && v == f.Value
+	case "ne":
+		return !ok || v != f.Value
+	case "wcard":
+		return ok && f.re != nil && f.re.MatchString(v)
+	}
+	return false
+}
+
+// perlClasses are the Perl character classes and their POSIX equivalents
+// outside and inside of a bracket expression, empty if there is none
+var perlClasses = map[byte][2]string{
+	'd': {"[0-9]", "0-9"},
+	'w': {"[A-Za-z0-9_]", "A-Za-z0-9_"},
+	's': {"[[:space:]]", "[:space:]"},
+	'D': {"[^0-9]", ""},
+	'W': {"[^A-Za-z0-9_]", ""},
+	'S': {"[^[:space:]]", ""},
+}
+
+// WcardValue returns the value of a wcard filter in the POSIX syntax of UCS
+func WcardValue(v string) (string, error) {
+	if _, err := regexp.CompilePOSIX(v); err == nil {
+		return v, nil
+	}
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '\\' && i+1 < len(v):
+			if p, ok := perlClasses[v[i+1]]; ok {
+				r := p[0]
+				if inClass {
+					r = p[1]
+				}
+				if len(r) == 0 {
+					return "", fmt.Errorf("\\%c in a bracket expression of %q has no POSIX equivalent", v[i+1], v)
+				}
+				b.WriteString(r)
+			} else {
+				b.WriteString(v[i : i+2])
+			}
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			b.WriteByte(c)
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+			// a ] right after [ or [^ is a literal
+			if strings.HasPrefix(v[i+1:], "^") {
+				b.WriteByte('^')
+				i++
+			}
+			if strings.HasPrefix(v[i+1:], "]") {
+				b.WriteByte(']')
+				i++
+			}
+		case strings.HasPrefix(v[i:], "(?:"):
+			b.WriteByte('(')
+			i += 2
+		case c == '?' && i > 0 && strings.IndexByte("*+?}", v[i-1]) >= 0 && !strings.HasSuffix(b.String(), "\\"+string(v[i-1])):
+			// lazy repeat, the filter matches or not either way
+		default:
+			b.WriteByte(c)
+		}
+	}
+	posix := b.String()
+	if _, err := regexp.CompilePOSIX(posix); err != nil {
+		return "", fmt.Errorf("wcard value %q isn't supported by UCS (POSIX extended regex)", v)
+	}
+	Debugf(2, "wcard value %q translated to %q\n", v, posix)
+	return posix, nil
+}
@@ -0,0 +1,50 @@
+package ucsxml
+
+import "testing"
+
+func TestWcardValue(t *testing.T) {
+	tests := []struct {
+		value, want string // want empty: error
+	}{
+		{`^sys/chassis-[1-3].*`, `^sys/chassis-[1-3].*`},
+		{`^sys/chassis-\d+/psu-[\d]`, `^sys/chassis-[0-9]+/psu-[0-9]`},
+		{`^Log\scapacity`, `^Log[[:space:]]capacity`},
+		{`(?:blade|rack-unit)-\w+`, `(blade|rack-unit)-[A-Za-z0-9_]+`},
+		{`^sys/chassis-\d+?/psu`, `^sys/chassis-[0-9]+/psu`},
+		{`a\*?`, `a\*?`},
+		{`[\D]`, ""},
+		{`(?i)psu`, ""},
+		{`\bpsu`, ""},
+	}
+	for _, tt := range tests {
+		got, err := WcardValue(tt.value)
+		switch {
+		case len(tt.want) == 0 && err == nil:
+			t.Errorf("%s: no error, got %s", tt.value, got)
+		case len(tt.want) > 0 && (err != nil || got != tt.want):
+			t.Errorf("%s: got %s, %v, want %s", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	f, _ := ParseFilter(`or(eq:operState:inoperable,not(wcard:dn:^sys/chassis-1/))`)
+	for _, tt := range []struct {
+		obj  Object
+		want bool
+	}{
+		{Object{Dn: "sys/chassis-1/psu-1", Attrs: map[string]string{"operState": "inoperable"}}, true},
+		{Object{Dn: "sys/chassis-1/psu-2", Attrs: map[string]string{"operState": "operable"}}, false},
+		{Object{Dn: "sys/chassis-2/psu-1", Attrs: map[string]string{"operState": "operable"}}, true},
+	} {
+		if got := f.Match(tt.obj); got != tt.want {
+			t.Errorf("Match(%s) = %v, want %v", tt.obj.Dn, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"and(eq:dn:a)", "not(eq:dn:a,eq:dn:b)", "or(eq:dn:a,foo)", "and()"} {
+		if _, err := ParseFilter(s); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package ucsxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Object is a managed object of a response with its dn and the requested
+// attributes
+type Object struct {
+	Dn    string
+	Attrs map[string]string // requested attributes found in the object
+	Keys  []string          // requested attributes in the order of the request
+}
+
+// ParseObjects returns every object of the class found in the data with its
+// dn and the requested attributes, matched case-insensitively with
+// ignoreCase and stored under the requested name. The data comes from devices
+// which might be buggy or compromised, documents exceeding the limits are
+// rejected.
+func ParseObjects(data []byte, class string, attributes []string, ignoreCase bool) (objects []Object, err error) {
+	decoder := NewDecoder(data)
+	depth := 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a decode error would silently reduce the number of objects
+			return nil, &Error{ErrParse, fmt.Errorf("XML decode error at byte %d: %v", decoder.InputOffset(), err)}
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+
+			depth++
+			if depth > MaxDepth {
+				return nil, fmt.Errorf("XML nesting deeper than %d elements at byte %d", MaxDepth, decoder.InputOffset())
+			}
+			if len(t.Attr) > MaxAttrs {
+				return nil, fmt.Errorf("element %.64s with more than %d attributes at byte %d", name, MaxAttrs, decoder.InputOffset())
+			}
+
+			if name == class {
+				if len(objects) >= MaxObjects {
+					return nil, fmt.Errorf("more than %d %s objects", MaxObjects, class)
+				}
+				obj := Object{Attrs: make(map[string]string), Keys: attributes}
+				for _, attr := range t.Attr {
+					if len(attr.Value) > MaxValueLen {
+						return nil, fmt.Errorf("attribute %.64s of %s longer than %d bytes", attr.Name.Local, class, MaxValueLen)
+					}
+					if attr.Name.Local == "dn" {
+						obj.Dn = attr.Value
+					}
+					if i := attrIndex(attr.Name.Local, attributes, ignoreCase); i > -1 {
+						obj.Attrs[attributes[i]] = attr.Value
+					}
+				}
+				objects = append(objects, obj)
+			}
+
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	return objects, nil
+}
+
+// attrIndex returns the index of the attribute name in the requested
+// attributes, or -1
+func attrIndex(name string, attributes []string, ignoreCase bool) int {
+	for i, a := range attributes {
+		if a == name || ignoreCase && strings.EqualFold(a, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Line returns the comma separated values of the requested attributes as
+// shown in the plugin output. Attributes missing at the end are left out.
+func (obj Object) Line() string {
+	last := -1
+	values := make([]string, len(obj.Keys))
+	for i, k := range obj.Keys {
+		if v, ok := obj.Attrs[k]; ok {
+			values[i] = v
+			last = i
+		}
+	}
+	return strings.Join(values[:last+1], ",")
+}
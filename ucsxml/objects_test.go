@@ -0,0 +1,136 @@
+package ucsxml
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseObjectsCaptured(t *testing.T) {
+	tests := []struct {
+		file       string
+		class      string
+		attributes []string
+		lines      []string
+	}{
+		{"ucsm-3.2-configResolveClass-equipmentPsu.xml", "equipmentPsu", []string{"id", "model", "operState"},
+			[]string{"1,UCS-PSU-6248UP-AC,operable", "2,UCS-PSU-6248UP-AC,operable", "4,,removed"}},
+		{"ucsm-4.1-configResolveClass-faultInst.xml", "faultInst", []string{"code", "severity", "ack"},
+			[]string{"F0461,info,no", "F0727,major,yes"}},
+		{"cimc-4.1-configResolveDn-computeRackUnit.xml", "computeRackUnit", []string{"operPower", "missing"},
+			[]string{"on"}},
+	}
+	for _, tt := range tests {
+		objects, err := ParseObjects(readTestdata(t, tt.file), tt.class, tt.attributes, false)
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if len(objects) != len(tt.lines) {
+			t.Errorf("%s: %d objects, want %d", tt.file, len(objects), len(tt.lines))
+			continue
+		}
+		for i, obj := range objects {
+			if obj.Line() != tt.lines[i] {
+				t.Errorf("%s: object %d: %q, want %q", tt.file, i, obj.Line(), tt.lines[i])
+			}
+			if len(obj.Dn) == 0 {
+				t.Errorf("%s: object %d without dn", tt.file, i)
+			}
+		}
+	}
+}
+
+func TestParseObjectsLimits(t *testing.T) {
+	var attrs strings.Builder
+	for i := 0; i <= MaxAttrs; i++ {
+		attrs.WriteString(" a" + strconv.Itoa(i) + `="1"`)
+	}
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"deep nesting", strings.Repeat("<a>", MaxDepth+1)},
+		{"huge attribute", `<faultInst dn="x" descr="` + strings.Repeat("A", MaxValueLen+1) + `"/>`},
+		{"many attributes", "<faultInst" + attrs.String() + "/>"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseObjects([]byte(tt.data), "faultInst", []string{"descr"}, false); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestParseObjectsManyAttributes(t *testing.T) {
+	// no limit of the number of attributes, missing ones at the end leave no trailing commas
+	var attrs, values []string
+	var data strings.Builder
+	data.WriteString(`<computeBlade dn="sys/chassis-1/blade-1"`)
+	for i := 1; i <= 14; i++ {
+		attrs = append(attrs, "a"+strconv.Itoa(i))
+		if i <= 12 {
+			values = append(values, strconv.Itoa(i))
+			fmt.Fprintf(&data, ` a%d="%d"`, i, i)
+		}
+	}
+	data.WriteString("/>")
+	objects, err := ParseObjects([]byte(data.String()), "computeBlade", attrs, false)
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("got %v, %v", objects, err)
+	}
+	if want := strings.Join(values, ","); objects[0].Line() != want {
+		t.Errorf("got %q, want %q", objects[0].Line(), want)
+	}
+}
+
+func TestParseObjectsCorrupt(t *testing.T) {
+	// corruption in the middle of the stream must not look like missing hardware
+	data := `<configResolveClass response="yes"><outConfigs><equipmentPsu dn="sys/psu-1" id="1"/><equipmentPsu dn="sys/psu-2" id=2/><equipmentPsu dn="sys/psu-3" id="3"/></outConfigs></configResolveClass>`
+	objects, err := ParseObjects([]byte(data), "equipmentPsu", []string{"id"}, false)
+	if err == nil {
+		t.Fatalf("no error, %d objects", len(objects))
+	}
+	if !strings.Contains(err.Error(), "at byte") {
+		t.Errorf("error without byte offset: %v", err)
+	}
+	if ErrorClass(err) != ErrParse {
+		t.Errorf("error class %q, want %q", ErrorClass(err), ErrParse)
+	}
+}
+
+func FuzzParseObjects(f *testing.F) {
+	for _, file := range []string{
+		"ucsm-3.2-configResolveClass-equipmentPsu.xml",
+		"ucsm-4.1-configResolveClass-faultInst.xml",
+		"cimc-4.1-configResolveDn-computeRackUnit.xml",
+	} {
+		buf, err := ioutil.ReadFile(filepath.Join("..", "testdata", file))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(buf), "faultInst")
+	}
+	f.Add(strings.Repeat("<a>", MaxDepth+1), "a")
+	f.Add("<faultInst dn=\"x\" descr=\"\xe4\xf6\xfc\"/>", "faultInst")
+	f.Add(`<a dn="1"><a dn="2"></a>`, "a")
+
+	f.Fuzz(func(t *testing.T, data string, class string) {
+		objects, err := ParseObjects([]byte(data), class, []string{"dn", "descr", "id"}, false)
+		if err != nil {
+			return
+		}
+		if n := strings.Count(data, "<"); len(objects) > n {
+			t.Errorf("%d objects from %d tags", len(objects), n)
+		}
+		for _, obj := range objects {
+			for k, v := range obj.Attrs {
+				if len(v) > MaxValueLen {
+					t.Errorf("attribute %s with %d bytes", k, len(v))
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package ucsxml
+
+// XML API requests and response envelopes. Requests are written with
+// self-closing tags for empty elements like the UCS Manager GUI does,
+// encoding/xml can't do that (https://github.com/golang/go/issues/21399).
+// Responses are checked against the envelope of the request method.
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type (
+	// Envelope is the root element of every XML API response
+	Envelope struct {
+		XMLName          xml.Name
+		Cookie           string `xml:"cookie,attr"`
+		Response         string `xml:"response,attr"`
+		ErrorCode        string `xml:"errorCode,attr"` // numeric or e.g. ERR-xml-parse-error
+		ErrorDescr       string `xml:"errorDescr,attr"`
+		InvocationResult string `xml:"invocationResult,attr"`
+		ClassId          string `xml:"classId,attr"`
+		Dn               string `xml:"dn,attr"`
+	}
+
+	// APIError is an error returned by the XML API
+	APIError struct {
+		Method string
+		Code   string
+		Descr  string
+	}
+)
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s error %s: %s", e.Method, e.Code, e.Descr)
+}
+
+// BuildRequest marshals a request, empty elements are written as
+// self-closing tags
+func BuildRequest(v interface{}) ([]byte, error) {
+	buf, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out     bytes.Buffer
+		pending *xml.StartElement // start tag not written yet, the element may be empty
+	)
+	writeStart := func(closed bool) {
+		out.WriteString("<" + pending.Name.Local)
+		for _, attr := range pending.Attr {
+			out.WriteString(" " + attr.Name.Local + `="`)
+			xml.EscapeText(&out, []byte(attr.Value))
+			out.WriteString(`"`)
+		}
+		if closed {
+			out.WriteString(" />")
+		} else {
+			out.WriteString(">")
+		}
+		pending = nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewBuffer(buf))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if pending != nil {
+				writeStart(false)
+			}
+			start := t.Copy()
+			pending = &start
+		case xml.EndElement:
+			if pending != nil {
+				writeStart(true)
+			} else {
+				out.WriteString("</" + t.Name.Local + ">")
+			}
+		case xml.CharData:
+			if pending != nil {
+				writeStart(false)
+			}
+			xml.EscapeText(&out, t)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// DecodeEnvelope checks the root element of the response to a request
+// method. XML API errors are returned as *APIError, the others as *Error.
+func DecodeEnvelope(body []byte, method string) (*Envelope, error) {
+	env := &Envelope{}
+	if err := NewDecoder(body).Decode(env); err != nil {
+		return nil, &Error{ErrParse, fmt.Errorf("%s: invalid response: %v", method, err)}
+	}
+	root := env.XMLName.Local
+	if len(env.ErrorCode) > 0 && env.ErrorCode != "0" {
+		return env, &APIError{Method: method, Code: env.ErrorCode, Descr: env.ErrorDescr}
+	}
+	if root != method {
+		return env, &Error{ErrAPI, fmt.Errorf("%s: unexpected response %s", method, root)}
+	}
+	if env.Response != "yes" {
+		return env, &Error{ErrAPI, fmt.Errorf("%s: response without response=\"yes\"", method)}
+	}
+	return env, nil
+}
+
+// RequestMethod returns the name and the attributes of the root element of
+// an XML API request, e.g. aaaLogin or configResolveClass
+func RequestMethod(body []byte) (string, map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewBuffer(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", nil, err
+		}
+		if elmt, ok := token.(xml.StartElement); ok {
+			attrs := make(map[string]string)
+			for _, attr := range elmt.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			return elmt.Name.Local, attrs, nil
+		}
+	}
+}
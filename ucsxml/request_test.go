@@ -0,0 +1,125 @@
+package ucsxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	buf, err := ioutil.ReadFile(filepath.Join("..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestBuildRequest(t *testing.T) {
+	tests := []struct {
+		req  interface{}
+		want string
+	}{
+		{
+			&AaaLogin{InName: "admin", InPassword: `a<b&"c`},
+			`<aaaLogin inName="admin" inPassword="a&lt;b&amp;&#34;c" />`,
+		},
+		{
+			&ConfigResolveClass{Cookie: "1/abc", InHierarchical: "false", ClassId: "equipmentPsu"},
+			`<configResolveClass cookie="1/abc" inHierarchical="false" classId="equipmentPsu" />`,
+		},
+		{
+			&ConfigResolveClass{Cookie: "1/abc", InHierarchical: "true", ClassId: "faultInst", InFilter: &InFilter{
+				Wcard: &Wcard{Class: "faultInst", Property: "descr", Value: "^Log capacity.*"},
+			}},
+			`<configResolveClass cookie="1/abc" inHierarchical="true" classId="faultInst"><inFilter><wcard class="faultInst" property="descr" value="^Log capacity.*" /></inFilter></configResolveClass>`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := BuildRequest(tt.req)
+		if err != nil {
+			t.Errorf("BuildRequest(%T): %v", tt.req, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("BuildRequest(%T)\n got: %s\nwant: %s", tt.req, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeEnvelope(t *testing.T) {
+	tests := []struct {
+		file    string
+		method  string
+		wantErr bool
+		code    string // expected APIError code
+	}{
+		{"ucsm-3.2-aaaLogin.xml", "aaaLogin", false, ""},
+		{"ucsm-4.1-aaaLogin.xml", "aaaLogin", false, ""},
+		{"ucsm-4.1-aaaLogin-551.xml", "aaaLogin", true, "551"},
+		{"ucsm-3.2-configResolveClass-equipmentPsu.xml", "configResolveClass", false, ""},
+		{"ucsm-4.1-configResolveClass-faultInst.xml", "configResolveClass", false, ""},
+		{"ucsm-4.1-configResolveClass-552.xml", "configResolveClass", true, "552"},
+		{"ucsm-4.1-error-xml-parse.xml", "configResolveClass", true, "ERR-xml-parse-error"},
+		{"cimc-4.1-configResolveDn-computeRackUnit.xml", "configResolveDn", false, ""},
+		{"cimc-4.1-configResolveDn-computeRackUnit.xml", "configResolveClass", true, ""},
+	}
+	for _, tt := range tests {
+		_, err := DecodeEnvelope(readTestdata(t, tt.file), tt.method)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: DecodeEnvelope(%s) error = %v, want error %v", tt.file, tt.method, err, tt.wantErr)
+			continue
+		}
+		if len(tt.code) > 0 {
+			apiErr, ok := err.(*APIError)
+			if !ok || apiErr.Code != tt.code {
+				t.Errorf("%s: error %v, want XML API error %s", tt.file, err, tt.code)
+			}
+		}
+	}
+
+	if _, err := DecodeEnvelope([]byte(`<configResolveClass response="yes"> <outConfigs>`), "configResolveClass"); err == nil {
+		t.Errorf("truncated response: no error")
+	}
+}
+
+func TestAaaLoginResp(t *testing.T) {
+	for _, file := range []string{"ucsm-3.2-aaaLogin.xml", "ucsm-4.1-aaaLogin.xml"} {
+		resp := &AaaLoginResp{}
+		if err := xml.Unmarshal(readTestdata(t, file), resp); err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if len(resp.OutCookie) == 0 || resp.OutRefreshPeriod != "600" || resp.ErrorCode != 0 {
+			t.Errorf("%s: unexpected login response %+v", file, resp)
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		file   string
+		method string
+		class  string
+	}{
+		{"ucsm-4.1-aaaLogin-551.xml", "aaaLogin", ErrAuth},
+		{"ucsm-4.1-configResolveClass-552.xml", "configResolveClass", ErrAuth},
+		{"ucsm-4.1-error-xml-parse.xml", "configResolveClass", ErrAPI},
+		{"cimc-4.1-configResolveDn-computeRackUnit.xml", "configResolveClass", ErrAPI},
+		{"ucsm-4.1-configResolveClass-faultInst.xml", "configResolveClass", ""},
+	}
+	for _, tt := range tests {
+		_, err := DecodeEnvelope(readTestdata(t, tt.file), tt.method)
+		if got := ErrorClass(err); got != tt.class {
+			t.Errorf("%s: class %q, want %q (%v)", tt.file, got, tt.class, err)
+		}
+	}
+	if _, err := DecodeEnvelope([]byte("<configResolveClass"), "configResolveClass"); ErrorClass(err) != ErrParse {
+		t.Errorf("truncated response: class %q, want %q", ErrorClass(err), ErrParse)
+	}
+	if ErrorClass(errors.New("connection refused")) != "" {
+		t.Errorf("error of the HTTP client classified")
+	}
+}
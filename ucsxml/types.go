@@ -0,0 +1,156 @@
+package ucsxml
+
+// Requests and responses of the XML API methods of the package. The filters
+// of InFilter are built by Filter.InFilter.
+
+import "encoding/xml"
+
+type (
+	AaaLogin struct {
+		XMLName    struct{} `xml:"aaaLogin"`
+		InName     string   `xml:"inName,attr"`
+		InPassword string   `xml:"inPassword,attr"`
+	}
+
+	AaaLoginResp struct {
+		XMLName          struct{} `xml:"aaaLogin"`
+		Cookie           string   `xml:"cookie,attr"`
+		Response         string   `xml:"response,attr"`
+		OutCookie        string   `xml:"outCookie,attr"`
+		OutRefreshPeriod string   `xml:"outRefreshPeriod,attr"`
+		OutPriv          string   `xml:"outPriv,attr"`
+		OutDomains       string   `xml:"outDomains,attr"`
+		OutChannel       string   `xml:"outChannel,attr"`
+		OutEvtChannel    string   `xml:"outEvtChannel,attr"`
+		OutSessionId     string   `xml:"outSessionId,attr"`
+		OutVersion       string   `xml:"outVersion,attr"`
+		ErrorCode        int      `xml:"errorCode,attr"`
+		ErrorDescr       string   `xml:"errorDescr,attr"`
+	}
+
+	ConfigResolveClass struct {
+		XMLName        struct{} `xml:"configResolveClass"`
+		Cookie         string   `xml:"cookie,attr"`
+		InHierarchical string   `xml:"inHierarchical,attr"`
+		ClassId        string   `xml:"classId,attr"`
+		InFilter       *InFilter
+	}
+
+	InFilter struct {
+		XMLName xml.Name `xml:"inFilter,omitempty"`
+		Eq      *Eq      `xml:"eq,omitempty"`
+		Ne      *Ne      `xml:"ne,omitempty"`
+		Gt      *Gt      `xml:"gt,omitempty"`
+		Ge      *Ge      `xml:"ge,omitempty"`
+		Lt      *Lt      `xml:"lt,omitempty"`
+		Le      *Le      `xml:"le,omitempty"`
+		Wcard   *Wcard   `xml:"wcard,omitempty"`
+		Anybit  *Anybit  `xml:"anybit,omitempty"`
+		Allbits *Allbits `xml:"allbits,omitempty"`
+		And     *And     `xml:"and,omitempty"`
+		Or      *Or      `xml:"or,omitempty"`
+		Not     *Not     `xml:"not,omitempty"`
+	}
+
+	// Equality Filter
+	Eq struct {
+		XMLName  struct{} `xml:"eq"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// Not Equal Filter
+	Ne struct {
+		XMLName  struct{} `xml:"ne"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// Greater Than Filter
+	Gt struct {
+		XMLName  struct{} `xml:"gt"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// Greater Than or Equal to Filter
+	Ge struct {
+		XMLName  struct{} `xml:"ge"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// Less Than Filter
+	Lt struct {
+		XMLName  struct{} `xml:"lt"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// Less Than or Equal to Filter
+	Le struct {
+		XMLName  struct{} `xml:"le"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// Wildcard Filter
+	Wcard struct {
+		XMLName  struct{} `xml:"wcard"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// Any Bits Filter
+	Anybit struct {
+		XMLName  struct{} `xml:"anybit"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// All Bits Filter
+	Allbits struct {
+		XMLName  struct{} `xml:"allbits"`
+		Class    string   `xml:"class,attr"`
+		Property string   `xml:"property,attr"`
+		Value    string   `xml:"value,attr"`
+	}
+
+	// And Composite Filter, the filters are property and composite filters
+	And struct {
+		XMLName struct{} `xml:"and"`
+		Filters []interface{}
+	}
+
+	// Or Composite Filter
+	Or struct {
+		XMLName struct{} `xml:"or"`
+		Filters []interface{}
+	}
+
+	// Not Composite Filter, a single filter
+	Not struct {
+		XMLName struct{} `xml:"not"`
+		Filters []interface{}
+	}
+
+	ConfigResolveDn struct {
+		XMLName        struct{} `xml:"configResolveDn"`
+		Cookie         string   `xml:"cookie,attr"`
+		InHierarchical string   `xml:"inHierarchical,attr"`
+		Dn             string   `xml:"dn,attr"`
+	}
+
+	AaaLogout struct {
+		XMLName  struct{} `xml:"aaaLogout"`
+		InCookie string   `xml:"inCookie,attr"`
+	}
+)
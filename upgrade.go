@@ -0,0 +1,48 @@
+package main
+
+// Flag -soft-during-upgrade: while an infrastructure firmware upgrade is
+// running, fabric interconnects reboot, IO modules are activated and paths
+// fail over, so hardware checks go CRIT in a predictable alert storm. A CRIT
+// check looks for the FSM of an upgrade and reports WARN instead.
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// upgradeIndicators are the FSM states of a running firmware upgrade
+var upgradeIndicators = []struct {
+	class string
+	attr  string
+	re    *regexp.Regexp
+}{
+	// UCS Manager and fabric interconnects
+	{"mgmtController", "fsmStatus", regexp.MustCompile(`(?i)^(update|activate)`)},
+	// IO modules
+	{"equipmentIOCard", "fsmStatus", regexp.MustCompile(`(?i)^(update|activate)`)},
+	// infrastructure firmware package, user acknowledgement of the fabric interconnect reboot pending
+	{"firmwareAck", "operState", regexp.MustCompile(`^waiting-for-user$`)},
+}
+
+// upgradeInProgress returns the objects indicating a running firmware upgrade
+func upgradeInProgress(client *http.Client, url, cookie string) ([]string, error) {
+	var found []string
+	for _, ind := range upgradeIndicators {
+		body, err := configRequest(client, url, &ucsxml.ConfigResolveClass{Cookie: cookie, InHierarchical: "false", ClassId: ind.class})
+		if err != nil {
+			return nil, err
+		}
+		objects, err := getXmlAttr(string(body), ind.class, []string{ind.attr})
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			if v := obj.Attrs[ind.attr]; ind.re.MatchString(v) {
+				found = append(found, obj.Dn+" "+ind.attr+" "+v)
+			}
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+// Response sanity checks of flag -validate. Firmware bugs and truncated
+// responses otherwise show up as a mysterious "0 of 0 ok".
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/mlueckert/check_cisco_ucs/ucsxml"
+)
+
+// validateResponse checks the structure of a configResolveClass or
+// configResolveDn response and returns the anomalies found and the
+// attribute presence statistics
+func validateResponse(body []byte, method, class string, attributes []string) (anomalies []string, stats []string) {
+	wrapper := "outConfigs"
+	if method == "configResolveDn" {
+		wrapper = "outConfig"
+	}
+
+	var (
+		depth      int
+		numObjects int
+		hasWrapper bool
+	)
+	present := make(map[string]int)
+
+	decoder := ucsxml.NewDecoder(body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("response not well-formed at byte %d: %v", decoder.InputOffset(), err))
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			attrs := make(map[string]string)
+			for _, attr := range t.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			switch {
+			case depth == 1:
+				if t.Name.Local != method {
+					anomalies = append(anomalies, fmt.Sprintf("root element %s, expected %s", t.Name.Local, method))
+				}
+				if attrs["response"] != "yes" {
+					anomalies = append(anomalies, "root element without response=\"yes\"")
+				}
+				if code, ok := attrs["errorCode"]; ok && code != "0" {
+					anomalies = append(anomalies, fmt.Sprintf("error %s: %s", code, attrs["errorDescr"]))
+				}
+				if method == "configResolveClass" && attrs["classId"] != class {
+					anomalies = append(anomalies, fmt.Sprintf("classId %q, expected %q", attrs["classId"], class))
+				}
+			case depth == 2 && t.Name.Local == wrapper:
+				hasWrapper = true
+			}
+			if t.Name.Local == class {
+				numObjects++
+				for name := range attrs {
+					if i := attrIndex(name, attributes); i > -1 {
+						present[attributes[i]]++
+					}
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if depth > 0 {
+		anomalies = append(anomalies, fmt.Sprintf("response truncated, %d elements not closed", depth))
+	}
+	if !hasWrapper {
+		anomalies = append(anomalies, fmt.Sprintf("no %s element in response", wrapper))
+	}
+
+	for _, a := range attributes {
+		if numObjects > 0 && present[a] == 0 {
+			anomalies = append(anomalies, fmt.Sprintf("attribute %s not found in any of %d %s objects", a, numObjects, class))
+		}
+		stats = append(stats, fmt.Sprintf("%s %d of %d", a, present[a], numObjects))
+	}
+	return anomalies, stats
+}